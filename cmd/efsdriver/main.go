@@ -1,17 +1,28 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 
 	cf_http "code.cloudfoundry.org/cfhttp"
+	"code.cloudfoundry.org/clock"
 	cf_debug_server "code.cloudfoundry.org/debugserver"
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
 	"code.cloudfoundry.org/dockerdriver/invoker"
+	"code.cloudfoundry.org/efsdriver/efsdrain"
+	"code.cloudfoundry.org/efsdriver/efshealth"
+	"code.cloudfoundry.org/efsdriver/efsmetrics"
 	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
 	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
@@ -42,6 +53,102 @@ var efsVolToolsAddress = flag.String(
 	"host:port to serve efs volume tools functions (for drivers colocated with the efs broker)",
 )
 
+var efsVolToolsJobTTL = flag.Duration(
+	"efsVolToolsJobTTL",
+	voltoolshttp.DefaultJobTTL,
+	"how long the results of asynchronous efs volume tools jobs are kept after they finish",
+)
+
+var efsVolToolsMaxJobs = flag.Int(
+	"efsVolToolsMaxJobs",
+	voltoolshttp.DefaultMaxJobs,
+	"how many asynchronous efs volume tools jobs may be pending or running at once",
+)
+
+var efsVolToolsStagingDir = flag.String(
+	"efsVolToolsStagingDir",
+	"/tmp/voltools",
+	"Path to directory where efs volume tools mount volumes temporarily (must not overlap mountDir)",
+)
+
+var efsVolToolsSweepInterval = flag.Duration(
+	"efsVolToolsSweepInterval",
+	voltoolslocal.DefaultSweepInterval,
+	"how often to look for temporary efs volume tools mounts left behind by a previous run",
+)
+
+var efsVolToolsCertFile = flag.String(
+	"efsVolToolsCertFile",
+	"",
+	"the public key file the efs volume tools server presents for ssl authentication",
+)
+
+var efsVolToolsKeyFile = flag.String(
+	"efsVolToolsKeyFile",
+	"",
+	"the private key file the efs volume tools server uses for ssl authentication",
+)
+
+var efsVolToolsCAFile = flag.String(
+	"efsVolToolsCAFile",
+	"",
+	"the certificate authority public key file used to verify efs volume tools clients",
+)
+
+var efsVolToolsTokenSecretFile = flag.String(
+	"efsVolToolsTokenSecretFile",
+	"",
+	"file holding the shared secret that signs the bearer tokens efs volume tools clients present (required with efsVolToolsAddr)",
+)
+
+var metricsAddress = flag.String(
+	"metricsAddr",
+	"",
+	"host:port to serve prometheus metrics on (disabled when empty)",
+)
+
+var healthAddress = flag.String(
+	"healthAddr",
+	"",
+	"host:port to serve the /healthz liveness and /readyz readiness endpoints on (disabled when empty)",
+)
+
+var statsdAddress = flag.String(
+	"statsdAddr",
+	"",
+	"host:port of a local statsd agent to send metrics to over udp (disabled when empty)",
+)
+
+var statsdPrefix = flag.String(
+	"statsdPrefix",
+	"efsdriver",
+	"prefix of the names of the metrics sent to statsdAddr",
+)
+
+var statsdTags = flag.String(
+	"statsdTags",
+	"",
+	"comma separated name:value tags added to every metric sent to statsdAddr",
+)
+
+var tracingEndpoint = flag.String(
+	"tracingEndpoint",
+	"",
+	"URL of an OpenTelemetry collector to export traces to over OTLP/HTTP, e.g. http://127.0.0.1:4318 (disabled when empty)",
+)
+
+var tracingServiceName = flag.String(
+	"tracingServiceName",
+	"efsdriver",
+	"service name traces exported to tracingEndpoint are reported under",
+)
+
+var drainGracePeriod = flag.Duration(
+	"drainGracePeriod",
+	efsdrain.DefaultGracePeriod,
+	"how long to wait on shutdown for mounts, unmounts and efs volume tools operations in flight to finish",
+)
+
 var driversPath = flag.String(
 	"driversPath",
 	"",
@@ -54,6 +161,12 @@ var transport = flag.String(
 	"Transport protocol to transmit HTTP over",
 )
 
+var stateDir = flag.String(
+	"stateDir",
+	"",
+	"directory to keep the mount journal in, replayed at startup to recover mounts cut short by a crash (disabled when empty)",
+)
+
 var mountDir = flag.String(
 	"mountDir",
 	"/tmp/volumes",
@@ -125,7 +238,55 @@ func main() {
 	logger.Info("start", lager.Data{"availability-zone": availabilityZone})
 	defer logger.Info("end")
 
-	mounter := efsmounter.NewEfsMounter(invoker.NewRealInvoker(), fsType, mountOptions, *availabilityZone)
+	var tracer *efstracing.Tracer
+	if *tracingEndpoint != "" {
+		exporter, err := efstracing.NewOTLPExporter(*tracingEndpoint, *tracingServiceName, &http.Client{Timeout: efstracing.ExportTimeout})
+		if err != nil {
+			logger.Fatal("tracing-configuration-failed", err)
+		}
+		tracer = efstracing.NewTracer(clock.NewClock(), exporter)
+		efstracing.SetTracer(tracer)
+	}
+
+	var journal *efsmounter.Journal
+	if *stateDir != "" {
+		var err error
+		journal, err = openMountJournal()
+		if err != nil {
+			logger.Fatal("mount-journal-configuration-failed", err)
+		}
+	}
+
+	mountRecords := efsmounter.NewRecords()
+	mounter := efsmounter.NewEfsMounterWithRecords(invoker.NewRealInvoker(), fsType, mountOptions, *availabilityZone, mountRecords, journal)
+	if journal != nil {
+		replayMountJournal(logger, journal, mounter)
+	}
+	reconcileMounts(logger, mountRecords)
+
+	var emitters []efsmetrics.Emitter
+	if *statsdAddress != "" {
+		emitter, err := newStatsdEmitter(logger)
+		if err != nil {
+			logger.Fatal("statsd-configuration-failed", err)
+		}
+		emitters = append(emitters, emitter)
+	}
+
+	// the volume tools get a mounter of their own, so that their short-lived
+	// mounts stay out of the active mounts
+	volToolsMounter := mounter
+	var metrics *efsmetrics.Metrics
+	if *metricsAddress != "" || len(emitters) > 0 {
+		metrics = efsmetrics.New(clock.NewClock(), emitters...)
+		metrics.AdoptMounts(mountRecords.All())
+		mounter = metrics.Mounter(mounter)
+		volToolsMounter = metrics.VolToolsMounter(volToolsMounter)
+	}
+
+	drainer := efsdrain.New(clock.NewClock())
+	mounter = drainer.Mounter(mounter)
+	volToolsMounter = drainer.Mounter(volToolsMounter)
 
 	client := volumedriver.NewVolumeDriver(
 		logger,
@@ -138,26 +299,74 @@ func main() {
 		oshelper.NewOsHelper(),
 	)
 
-	efsvoltools := voltoolslocal.NewEfsVolToolsLocal(
+	if *efsVolToolsAddress != "" {
+		if err := voltoolslocal.ValidateStagingRoot(*efsVolToolsStagingDir, *mountDir); err != nil {
+			logger.Fatal("efs-vol-tools-staging-configuration-failed", err)
+		}
+	}
+
+	localVolTools := voltoolslocal.NewEfsVolToolsLocal(
 		&osshim.OsShim{},
 		&filepathshim.FilepathShim{},
 		&ioutilshim.IoutilShim{},
-		*mountDir,
-		mounter,
+		*efsVolToolsStagingDir,
+		volToolsMounter,
+		voltoolslocal.NewNfs4ACLBackend(invoker.NewRealInvoker()),
 	)
 
+	var volTools efsvoltools.VolTools = localVolTools
+	if metrics != nil {
+		volTools = metrics.VolTools(volTools)
+	}
+	volTools = drainer.VolTools(volTools)
+
+	var specFile string
 	if *transport == "tcp" {
-		localDriverServer = createEfsDriverServer(logger, client, efsvoltools, *atAddress, *driversPath, false, *efsVolToolsAddress, false)
+		localDriverServer = createEfsDriverServer(logger, client, volTools, drainer, *atAddress, *driversPath, false, *efsVolToolsAddress, false)
+		specFile = specFilePath(*driversPath, false)
 	} else if *transport == "tcp-json" {
-		localDriverServer = createEfsDriverServer(logger, client, efsvoltools, *atAddress, *driversPath, true, *efsVolToolsAddress, *uniqueVolumeIds)
+		localDriverServer = createEfsDriverServer(logger, client, volTools, drainer, *atAddress, *driversPath, true, *efsVolToolsAddress, *uniqueVolumeIds)
+		specFile = specFilePath(*driversPath, true)
 	} else {
-		localDriverServer = createEfsDriverUnixServer(logger, client, *atAddress)
+		localDriverServer = createEfsDriverUnixServer(logger, client, drainer, *atAddress)
 	}
 
 	servers := grouper.Members{
 		{Name: "localdriver-server", Runner: localDriverServer},
 	}
 
+	if *efsVolToolsAddress != "" {
+		servers = append(servers, grouper.Member{
+			Name:   "efs-voltools-mount-sweeper",
+			Runner: voltoolslocal.NewSweeper(logger, localVolTools, clock.NewClock(), *efsVolToolsSweepInterval),
+		})
+	}
+
+	if *metricsAddress != "" {
+		servers = append(servers, grouper.Member{Name: "metrics-server", Runner: http_server.New(*metricsAddress, metrics.Handler())})
+	}
+
+	if tracer != nil {
+		servers = append(servers, grouper.Member{
+			Name:   "trace-flusher",
+			Runner: efstracing.NewFlusher(logger, tracer, clock.NewClock(), efstracing.DefaultFlushInterval),
+		})
+	}
+
+	if *healthAddress != "" {
+		servers = append(servers, grouper.Member{
+			Name:   "health-server",
+			Runner: http_server.New(*healthAddress, newHealthChecker(specFile, drainer).Handler()),
+		})
+	}
+
+	// last, so that it is stopped first and the servers only stop once the
+	// work in flight has drained
+	servers = append(servers, grouper.Member{
+		Name:   "drainer",
+		Runner: efsdrain.NewRunner(logger, drainer, clock.NewClock(), *drainGracePeriod),
+	})
+
 	if dbgAddr := cf_debug_server.DebugAddress(flag.CommandLine); dbgAddr != "" {
 		servers = append(grouper.Members{
 			{Name: "debug-server", Runner: cf_debug_server.Runner(dbgAddr, logTap)},
@@ -167,7 +376,7 @@ func main() {
 	process := ifrit.Invoke(processRunnerFor(servers))
 	logger.Info("started")
 
-	untilTerminated(logger, process)
+	untilTerminated(logger, process, specFile)
 }
 
 func exitOnFailure(logger lager.Logger, err error) {
@@ -177,16 +386,36 @@ func exitOnFailure(logger lager.Logger, err error) {
 	}
 }
 
-func untilTerminated(logger lager.Logger, process ifrit.Process) {
+func untilTerminated(logger lager.Logger, process ifrit.Process, specFile string) {
 	err := <-process.Wait()
+	removeSpecFile(logger, specFile)
 	exitOnFailure(logger, err)
 }
 
+// removeSpecFile stops advertising the driver once it has exited.
+func removeSpecFile(logger lager.Logger, specFile string) {
+	if specFile == "" {
+		return
+	}
+	logger.Info("removing-spec-file", lager.Data{"location": specFile})
+	if err := os.Remove(specFile); err != nil && !os.IsNotExist(err) {
+		logger.Error("failed-removing-spec-file", err)
+	}
+}
+
+func specFilePath(driversPath string, jsonSpec bool) string {
+	extension := "spec"
+	if jsonSpec {
+		extension = "json"
+	}
+	return filepath.Join(driversPath, "efsdriver."+extension)
+}
+
 func processRunnerFor(servers grouper.Members) ifrit.Runner {
 	return sigmon.New(grouper.NewOrdered(os.Interrupt, servers))
 }
 
-func createEfsDriverServer(logger lager.Logger, client dockerdriver.Driver, efsvoltools efsvoltools.VolTools, atAddress, driversPath string, jsonSpec bool, efsToolsAddress string, uniqueVolumeIds bool) ifrit.Runner {
+func createEfsDriverServer(logger lager.Logger, client dockerdriver.Driver, efsvoltools efsvoltools.VolTools, drainer *efsdrain.Drainer, atAddress, driversPath string, jsonSpec bool, efsToolsAddress string, uniqueVolumeIds bool) ifrit.Runner {
 	advertisedUrl := "http://" + atAddress
 	logger.Info("writing-spec-file", lager.Data{"location": driversPath, "name": "efsdriver", "address": advertisedUrl, "unique-volume-ids": uniqueVolumeIds})
 	if jsonSpec {
@@ -215,6 +444,7 @@ func createEfsDriverServer(logger lager.Logger, client dockerdriver.Driver, efsv
 
 	handler, err := driverhttp.NewHandler(logger, client)
 	exitOnFailure(logger, err)
+	handler = drainer.Handler(handler)
 
 	var server ifrit.Runner
 	if *requireSSL {
@@ -228,19 +458,208 @@ func createEfsDriverServer(logger lager.Logger, client dockerdriver.Driver, efsv
 	}
 
 	if efsToolsAddress != "" {
-		efsToolsHandler, err := voltoolshttp.NewHandler(logger, efsvoltools)
+		verifier, err := newEfsVolToolsTokenVerifier()
+		if err != nil {
+			logger.Fatal("efs-vol-tools-auth-configuration-failed", err)
+		}
+
+		jobs := voltoolshttp.NewJobStoreWithContext(drainer.Context(), clock.NewClock(), *efsVolToolsJobTTL, *efsVolToolsMaxJobs)
+		efsToolsHandler, err := voltoolshttp.NewHandlerWithJobStore(logger, efsvoltools, verifier, jobs)
 		exitOnFailure(logger, err)
-		efsServer := http_server.New(efsToolsAddress, efsToolsHandler)
+		efsToolsHandler = drainer.Handler(efsToolsHandler)
+
+		var efsServer ifrit.Runner
+		if efsVolToolsUsesTLS() {
+			tlsConfig, err := newEfsVolToolsTLSConfig()
+			if err != nil {
+				logger.Fatal("efs-vol-tools-tls-configuration-failed", err)
+			}
+			efsServer = http_server.NewTLSServer(efsToolsAddress, efsToolsHandler, tlsConfig)
+		} else {
+			efsServer = http_server.New(efsToolsAddress, efsToolsHandler)
+		}
 		server = grouper.NewParallel(os.Interrupt, grouper.Members{{Name: "dockerdriver", Runner: server}, {Name: "efstools", Runner: efsServer}})
 	}
 
 	return server
 }
 
-func createEfsDriverUnixServer(logger lager.Logger, client dockerdriver.Driver, atAddress string) ifrit.Runner {
+func efsVolToolsUsesTLS() bool {
+	return *requireSSL || *efsVolToolsCertFile != "" || *efsVolToolsKeyFile != "" || *efsVolToolsCAFile != ""
+}
+
+// newEfsVolToolsTLSConfig builds the configuration of the volume tools
+// server, which always requires clients to present a certificate signed by
+// efsVolToolsCAFile.
+func newEfsVolToolsTLSConfig() (*tls.Config, error) {
+	if *efsVolToolsCertFile == "" || *efsVolToolsKeyFile == "" || *efsVolToolsCAFile == "" {
+		return nil, errors.New("efsVolToolsCertFile, efsVolToolsKeyFile and efsVolToolsCAFile are all required to serve efs volume tools over ssl")
+	}
+
+	tlsConfig, err := cf_http.NewTLSConfig(*efsVolToolsCertFile, *efsVolToolsKeyFile, *efsVolToolsCAFile)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	return tlsConfig, nil
+}
+
+// newHealthChecker makes readiness depend on what serving needs: the spec
+// file the driver is discovered through, somewhere to mount, the mount tools,
+// and the TLS files of the servers, none of which is used over a unix socket.
+// The driver is no longer ready once it starts draining.
+func newHealthChecker(specFile string, drainer *efsdrain.Drainer) *efshealth.Checker {
+	checker := efshealth.NewChecker()
+	if specFile != "" {
+		checker.Add("spec-file", efshealth.FileExists(specFile))
+		if *requireSSL {
+			checker.Add("driver-tls", efshealth.TLSFiles(*certFile, *keyFile, *caFile))
+		}
+		if *efsVolToolsAddress != "" && efsVolToolsUsesTLS() {
+			checker.Add("efs-voltools-tls", efshealth.TLSFiles(*efsVolToolsCertFile, *efsVolToolsKeyFile, *efsVolToolsCAFile))
+		}
+	}
+	checker.Add("mount-dir", efshealth.WritableDir(*mountDir))
+	for _, binary := range []string{"mount", "umount", "mountpoint"} {
+		checker.Add(binary+"-binary", efshealth.Executable(binary))
+	}
+	checker.Add("drain", func() error {
+		if drainer.Draining() {
+			return efsdrain.ErrShuttingDown
+		}
+		return nil
+	})
+	return checker
+}
+
+func openMountJournal() (*efsmounter.Journal, error) {
+	if err := os.MkdirAll(*stateDir, 0700); err != nil {
+		return nil, err
+	}
+	return efsmounter.OpenJournal(filepath.Join(*stateDir, efsmounter.JournalFile))
+}
+
+// replayMountJournal rolls back the mounts and finishes the unmounts that a
+// crash of the previous run left incomplete.
+func replayMountJournal(logger lager.Logger, journal *efsmounter.Journal, mounter efsmounter.Mounter) {
+	logger = logger.Session("replay-mount-journal", lager.Data{"state-dir": *stateDir})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	env := driverhttp.NewHttpDriverEnv(logger, context.Background())
+	outcomes, skipped, err := efsmounter.Replay(env, journal, mounter, isMounted)
+	if skipped > 0 {
+		logger.Info("skipped-unreadable-entries", lager.Data{"skipped": skipped})
+	}
+	for _, outcome := range outcomes {
+		data := lager.Data{"op": outcome.Entry.Op, "target": outcome.Entry.Target, "action": outcome.Action}
+		if outcome.Err != nil {
+			logger.Error("failed-replaying-operation", outcome.Err, data)
+		} else {
+			logger.Info("replayed-operation", data)
+		}
+	}
+	if err != nil {
+		logger.Error("failed-replaying-journal", err)
+	}
+}
+
+// isMounted reports whether target is in the mount table, or whether it may
+// be when the table cannot be read.
+func isMounted(target string) bool {
+	entries, err := readMountTable()
+	if err != nil {
+		return true
+	}
+	for _, entry := range entries {
+		if filepath.Clean(entry.Target) == filepath.Clean(target) {
+			return true
+		}
+	}
+	return false
+}
+
+func readMountTable() ([]efsmounter.MountEntry, error) {
+	table, err := os.Open(efsmounter.MountTablePath)
+	if err != nil {
+		return nil, err
+	}
+	defer table.Close()
+	return efsmounter.ParseMountTable(table)
+}
+
+// reconcileMounts adopts the file systems a previous run of the driver left
+// mounted under mountDir into records, and reports the mountpoints and mounts
+// it cannot account for. Failing to read either is logged and leaves records
+// empty, as it was before reconciliation.
+func reconcileMounts(logger lager.Logger, records *efsmounter.Records) {
+	logger = logger.Session("reconcile-mounts", lager.Data{"mount-dir": *mountDir})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	dir, err := filepath.Abs(*mountDir)
+	if err != nil {
+		logger.Error("failed-resolving-mount-dir", err)
+		return
+	}
+
+	entries, err := readMountTable()
+	if err != nil {
+		logger.Error("failed-reading-mount-table", err)
+		return
+	}
+
+	// os.ReadDir does not stat the entries, which would hang on a mount
+	// whose server has gone away
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		logger.Error("failed-listing-mount-dir", err)
+		return
+	}
+	var mountpoints []string
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			mountpoints = append(mountpoints, dirEntry.Name())
+		}
+	}
+
+	report := efsmounter.Reconcile(entries, dir, mountpoints, records)
+	for _, record := range report.Adopted {
+		logger.Info("adopted-mount", lager.Data{"target": record.Target, "source": record.Source, "options": record.Options})
+	}
+	for _, target := range report.Orphaned {
+		logger.Info("orphaned-mountpoint", lager.Data{"target": target})
+	}
+	for _, inconsistency := range report.Inconsistent {
+		logger.Info("inconsistent-mount", lager.Data{"target": inconsistency.Target, "reason": inconsistency.Reason})
+	}
+	logger.Info("reconciled", lager.Data{"adopted": len(report.Adopted), "orphaned": len(report.Orphaned), "inconsistent": len(report.Inconsistent)})
+}
+
+func newStatsdEmitter(logger lager.Logger) (*efsmetrics.StatsdEmitter, error) {
+	tags, err := efsmetrics.ParseTags(*statsdTags)
+	if err != nil {
+		return nil, err
+	}
+	return efsmetrics.NewStatsdEmitter(logger, *statsdAddress, *statsdPrefix, tags)
+}
+
+func newEfsVolToolsTokenVerifier() (*voltoolshttp.TokenVerifier, error) {
+	if *efsVolToolsTokenSecretFile == "" {
+		return nil, errors.New("efsVolToolsTokenSecretFile is required to serve efs volume tools")
+	}
+
+	secret, err := ioutil.ReadFile(*efsVolToolsTokenSecretFile)
+	if err != nil {
+		return nil, err
+	}
+	return voltoolshttp.NewTokenVerifier(bytes.TrimSpace(secret), clock.NewClock())
+}
+
+func createEfsDriverUnixServer(logger lager.Logger, client dockerdriver.Driver, drainer *efsdrain.Drainer, atAddress string) ifrit.Runner {
 	handler, err := driverhttp.NewHandler(logger, client)
 	exitOnFailure(logger, err)
-	return http_server.NewUnixServer(atAddress, handler)
+	return http_server.NewUnixServer(atAddress, drainer.Handler(handler))
 }
 
 func newLogger() (lager.Logger, *lager.ReconfigurableSink) {
@@ -3,6 +3,7 @@ package main_test
 import (
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,6 +11,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/gexec"
 )
 
@@ -69,6 +71,20 @@ var _ = Describe("Main", func() {
 				}`))
 		})
 
+		It("drains and removes the spec file when interrupted", func() {
+			specFile := filepath.Join(dir, "efsdriver.json")
+			Eventually(func() error {
+				_, err := os.Stat(specFile)
+				return err
+			}, 5).ShouldNot(HaveOccurred())
+			Eventually(session.Out, 5).Should(gbytes.Say("started"))
+
+			session.Interrupt()
+			Eventually(session, 5).Should(gexec.Exit(0))
+			Expect(session.Out).To(gbytes.Say(`drained.*"unfinished":0`))
+			Expect(specFile).NotTo(BeAnExistingFile())
+		})
+
 		Context("with unique volume IDs enabled", func() {
 			BeforeEach(func() {
 				command.Args = append(command.Args, "-uniqueVolumeIds")
@@ -94,5 +110,151 @@ var _ = Describe("Main", func() {
 
 			})
 		})
+
+		Context("with a mountpoint left behind by a previous run", func() {
+			var mountDir string
+
+			BeforeEach(func() {
+				mountDir = filepath.Join(dir, "volumes")
+				Expect(os.MkdirAll(filepath.Join(mountDir, "some-volume"), 0755)).To(Succeed())
+				command.Args = append(command.Args, "-mountDir="+mountDir)
+			})
+
+			It("reports it as orphaned at startup", func() {
+				Eventually(session.Out, 5).Should(gbytes.Say("orphaned-mountpoint.*some-volume"))
+				Eventually(session.Out, 5).Should(gbytes.Say(`reconciled.*"orphaned":1`))
+			})
+		})
+
+		Context("with an unmount left incomplete in the mount journal", func() {
+			var stateDir string
+
+			BeforeEach(func() {
+				stateDir = filepath.Join(dir, "state")
+				Expect(os.MkdirAll(stateDir, 0700)).To(Succeed())
+				Expect(ioutil.WriteFile(
+					filepath.Join(stateDir, "mount-journal.jsonl"),
+					[]byte(`{"id":"1","op":"unmount","phase":"intent","target":"/not/mounted"}`+"\n"),
+					0600,
+				)).To(Succeed())
+				command.Args = append(command.Args, "-stateDir="+stateDir)
+			})
+
+			It("finishes it at startup and empties the journal", func() {
+				Eventually(session.Out, 5).Should(gbytes.Say(`replayed-operation.*"action":"finished"`))
+				Eventually(func() ([]byte, error) {
+					return ioutil.ReadFile(filepath.Join(stateDir, "mount-journal.jsonl"))
+				}, 5).Should(BeEmpty())
+			})
+		})
+
+		Context("with health endpoints enabled", func() {
+			BeforeEach(func() {
+				command.Args = append(command.Args, "-healthAddr=127.0.0.1:9754", "-mountDir="+filepath.Join(dir, "volumes"))
+			})
+
+			It("reports liveness and the readiness of every component", func() {
+				Eventually(func() (int, error) {
+					response, err := http.Get("http://127.0.0.1:9754/healthz")
+					if err != nil {
+						return 0, err
+					}
+					response.Body.Close()
+					return response.StatusCode, nil
+				}, 5).Should(Equal(http.StatusOK))
+
+				response, err := http.Get("http://127.0.0.1:9754/readyz")
+				Expect(err).NotTo(HaveOccurred())
+				defer response.Body.Close()
+				body, err := ioutil.ReadAll(response.Body)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(body)).To(ContainSubstring(`"spec-file":{"status":"ok"}`))
+				Expect(string(body)).To(ContainSubstring(`"mount-dir":{"status":"ok"}`))
+				Expect(string(body)).To(ContainSubstring(`"mountpoint-binary":`))
+			})
+		})
+
+		Context("with metrics enabled", func() {
+			BeforeEach(func() {
+				command.Args = append(command.Args, "-metricsAddr=127.0.0.1:9753")
+			})
+
+			It("serves prometheus metrics", func() {
+				Eventually(func() (string, error) {
+					response, err := http.Get("http://127.0.0.1:9753/metrics")
+					if err != nil {
+						return "", err
+					}
+					defer response.Body.Close()
+					body, err := ioutil.ReadAll(response.Body)
+					return string(body), err
+				}, 5).Should(ContainSubstring("efsdriver_active_mounts 0"))
+			})
+		})
+
+		Context("with malformed statsd tags", func() {
+			BeforeEach(func() {
+				command.Args = append(command.Args, "-statsdAddr=127.0.0.1:8125", "-statsdTags=deployment")
+			})
+
+			It("refuses to start", func() {
+				Eventually(session, 5).Should(gexec.Exit())
+				Expect(session.ExitCode()).NotTo(Equal(0))
+				Expect(session.Out).To(gbytes.Say("statsd-configuration-failed"))
+			})
+		})
+
+		Context("with a malformed tracing endpoint", func() {
+			BeforeEach(func() {
+				command.Args = append(command.Args, "-tracingEndpoint=127.0.0.1:4318")
+			})
+
+			It("refuses to start", func() {
+				Eventually(session, 5).Should(gexec.Exit())
+				Expect(session.ExitCode()).NotTo(Equal(0))
+				Expect(session.Out).To(gbytes.Say("tracing-configuration-failed"))
+			})
+		})
+
+		Context("with efs volume tools enabled", func() {
+			BeforeEach(func() {
+				command.Args = append(command.Args, "-listenAddr=127.0.0.1:9751")
+				command.Args = append(command.Args, "-efsVolToolsAddr=127.0.0.1:9752")
+			})
+
+			It("refuses to start without a token secret", func() {
+				Eventually(session, 5).Should(gexec.Exit())
+				Expect(session.ExitCode()).NotTo(Equal(0))
+				Expect(session.Out).To(gbytes.Say("efs-vol-tools-auth-configuration-failed"))
+			})
+
+			Context("and a staging directory inside the mount directory", func() {
+				BeforeEach(func() {
+					command.Args = append(command.Args, "-mountDir="+dir)
+					command.Args = append(command.Args, "-efsVolToolsStagingDir="+filepath.Join(dir, "voltools"))
+				})
+
+				It("refuses to start", func() {
+					Eventually(session, 5).Should(gexec.Exit())
+					Expect(session.ExitCode()).NotTo(Equal(0))
+					Expect(session.Out).To(gbytes.Say("efs-vol-tools-staging-configuration-failed"))
+				})
+			})
+
+			Context("and ssl only partially configured", func() {
+				BeforeEach(func() {
+					secretFile := filepath.Join(dir, "token-secret")
+					Expect(ioutil.WriteFile(secretFile, []byte("0123456789abcdef0123456789abcdef\n"), 0600)).To(Succeed())
+					command.Args = append(command.Args, "-efsVolToolsTokenSecretFile="+secretFile)
+					command.Args = append(command.Args, "-efsVolToolsCertFile=/some/cert.pem")
+				})
+
+				It("refuses to start instead of serving volume tools in plaintext", func() {
+					Eventually(session, 5).Should(gexec.Exit())
+					Expect(session.ExitCode()).NotTo(Equal(0))
+					Expect(session.Out).To(gbytes.Say("efs-vol-tools-tls-configuration-failed"))
+				})
+			})
+		})
 	})
 })
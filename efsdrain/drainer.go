@@ -0,0 +1,160 @@
+package efsdrain
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// DefaultGracePeriod is how long the driver waits for work in flight when it
+// shuts down.
+const DefaultGracePeriod = 30 * time.Second
+
+// ErrShuttingDown is the error new requests are refused with while draining.
+var ErrShuttingDown = errors.New("efsdriver is shutting down")
+
+// Operation is a piece of work the Drainer is waiting for.
+type Operation struct {
+	Name    string
+	Detail  string
+	Started time.Time
+}
+
+// Drainer keeps track of the work in flight, so that shutting down can wait
+// for it. Wrap the handlers that accept work with Handler, and the mounter and
+// volume tools that carry it out with Mounter and VolTools; work already
+// accepted may start more of it while draining, asynchronous volume tools
+// jobs in particular, so only the handlers refuse anything.
+type Drainer struct {
+	clock  clock.Clock
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lock     sync.Mutex
+	draining bool
+	next     uint64
+	inFlight map[uint64]Operation
+	idle     chan struct{}
+	isIdle   bool
+}
+
+func New(clock clock.Clock) *Drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Drainer{
+		clock:    clock,
+		ctx:      ctx,
+		cancel:   cancel,
+		inFlight: map[uint64]Operation{},
+		idle:     make(chan struct{}),
+	}
+}
+
+// Context is cancelled when Drain gives up waiting, for work that can be
+// cancelled to derive its context from.
+func (d *Drainer) Context() context.Context {
+	return d.ctx
+}
+
+// Draining reports whether Drain has been called.
+func (d *Drainer) Draining() bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.draining
+}
+
+// InFlight returns the operations that have not finished yet, oldest first.
+func (d *Drainer) InFlight() []Operation {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	operations := make([]Operation, 0, len(d.inFlight))
+	for _, operation := range d.inFlight {
+		operations = append(operations, operation)
+	}
+	sort.Slice(operations, func(i, j int) bool { return operations[i].Started.Before(operations[j].Started) })
+	return operations
+}
+
+// Drain makes the handlers refuse new requests, and waits up to grace for the
+// operations in flight to finish. It then cancels Context, and returns the
+// operations that did not finish.
+func (d *Drainer) Drain(grace time.Duration) []Operation {
+	d.lock.Lock()
+	d.draining = true
+	d.checkIdle()
+	d.lock.Unlock()
+
+	timer := d.clock.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-d.idle:
+		return nil
+	case <-timer.C():
+		d.cancel()
+		return d.InFlight()
+	}
+}
+
+// begin tracks an operation until the returned function is called.
+func (d *Drainer) begin(name, detail string) func() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.track(name, detail)
+}
+
+// admit is begin for new work, which it refuses while draining.
+func (d *Drainer) admit(name, detail string) (func(), bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.draining {
+		return nil, false
+	}
+	return d.track(name, detail), true
+}
+
+func (d *Drainer) track(name, detail string) func() {
+	id := d.next
+	d.next++
+	d.inFlight[id] = Operation{Name: name, Detail: detail, Started: d.clock.Now()}
+
+	return func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+		delete(d.inFlight, id)
+		d.checkIdle()
+	}
+}
+
+func (d *Drainer) checkIdle() {
+	if d.draining && len(d.inFlight) == 0 && !d.isIdle {
+		d.isIdle = true
+		close(d.idle)
+	}
+}
+
+// Handler tracks the requests handler serves, and refuses new ones with 503
+// Service Unavailable once draining. The refusal is an error response both
+// the driver and the volume tools clients understand.
+func (d *Drainer) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		done, ok := d.admit("request", req.Method+" "+req.URL.Path)
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Connection", "close")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(struct {
+				Err       string
+				Retryable bool
+			}{ErrShuttingDown.Error(), true})
+			return
+		}
+
+		defer done()
+		handler.ServeHTTP(w, req)
+	})
+}
@@ -0,0 +1,172 @@
+package efsdrain_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdrain"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Drainer", func() {
+	var (
+		fakeClock   *fakeclock.FakeClock
+		drainer     *efsdrain.Drainer
+		env         dockerdriver.Env
+		fakeMounter *efsdriverfakes.FakeMounter
+		release     chan struct{}
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		drainer = efsdrain.New(fakeClock)
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("drainer"), context.TODO())
+
+		// The stubs block on their own test's channel, since they can still be
+		// running when the next test replaces release.
+		release = make(chan struct{})
+		release := release
+		fakeMounter = &efsdriverfakes.FakeMounter{}
+		fakeMounter.MountStub = func(dockerdriver.Env, string, string, map[string]interface{}) error {
+			<-release
+			return nil
+		}
+	})
+
+	// mountInFlight starts a mount that runs until release is closed.
+	mountInFlight := func() {
+		go drainer.Mounter(fakeMounter).Mount(env, "10.0.0.1:/", "/volumes/a", nil)
+		Eventually(drainer.InFlight).Should(HaveLen(1))
+	}
+
+	drain := func(grace time.Duration) chan []efsdrain.Operation {
+		unfinished := make(chan []efsdrain.Operation, 1)
+		go func() { unfinished <- drainer.Drain(grace) }()
+		return unfinished
+	}
+
+	It("returns at once when nothing is in flight", func() {
+		Expect(drainer.Drain(time.Minute)).To(BeEmpty())
+		Expect(drainer.Draining()).To(BeTrue())
+		Expect(drainer.Context().Err()).NotTo(HaveOccurred())
+	})
+
+	It("waits for the mounts in flight to finish", func() {
+		mountInFlight()
+		Expect(drainer.InFlight()[0]).To(Equal(efsdrain.Operation{Name: "mount", Detail: "/volumes/a", Started: fakeClock.Now()}))
+
+		unfinished := drain(time.Minute)
+		Consistently(unfinished).ShouldNot(Receive())
+
+		close(release)
+		Eventually(unfinished).Should(Receive(BeEmpty()))
+	})
+
+	It("gives up after the grace period and returns what did not finish", func() {
+		mountInFlight()
+		defer close(release)
+
+		unfinished := drain(time.Minute)
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+
+		var operations []efsdrain.Operation
+		Eventually(unfinished).Should(Receive(&operations))
+		Expect(operations).To(HaveLen(1))
+		Expect(operations[0].Name).To(Equal("mount"))
+		Expect(drainer.Context().Err()).To(Equal(context.Canceled))
+	})
+
+	It("tracks volume tools operations by route", func() {
+		release := release
+		fakeVolTools := &efsdriverfakes.FakeVolTools{}
+		fakeVolTools.OpenPermsStub = func(dockerdriver.Env, efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
+			<-release
+			return efsvoltools.ErrorResponse{}
+		}
+		defer close(release)
+
+		go drainer.VolTools(fakeVolTools).OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+		Eventually(drainer.InFlight).Should(ConsistOf(efsdrain.Operation{Name: efsvoltools.OpenPermsRoute, Detail: "some-volume", Started: fakeClock.Now()}))
+	})
+
+	Context("#Handler", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			release := release
+			server = httptest.NewServer(drainer.Handler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path == "/slow" {
+					<-release
+				}
+				w.WriteHeader(http.StatusOK)
+			})))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("serves requests until draining, and refuses new ones after", func() {
+			response, err := http.Get(server.URL + "/fast")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(http.StatusOK))
+
+			drainer.Drain(0)
+
+			response, err = http.Get(server.URL + "/fast")
+			Expect(err).NotTo(HaveOccurred())
+			defer response.Body.Close()
+			Expect(response.StatusCode).To(Equal(http.StatusServiceUnavailable))
+
+			var refusal efsvoltools.ErrorResponse
+			Expect(json.NewDecoder(response.Body).Decode(&refusal)).To(Succeed())
+			Expect(refusal.Err).To(Equal(efsdrain.ErrShuttingDown.Error()))
+			Expect(refusal.Retryable).To(BeTrue())
+		})
+
+		It("lets requests already being served finish", func() {
+			responses := make(chan int, 1)
+			go func() {
+				defer GinkgoRecover()
+				response, err := http.Get(server.URL + "/slow")
+				Expect(err).NotTo(HaveOccurred())
+				responses <- response.StatusCode
+			}()
+			Eventually(drainer.InFlight).Should(ConsistOf(efsdrain.Operation{Name: "request", Detail: "GET /slow", Started: fakeClock.Now()}))
+
+			unfinished := drain(time.Minute)
+			close(release)
+			Eventually(unfinished).Should(Receive(BeEmpty()))
+			Eventually(responses).Should(Receive(Equal(http.StatusOK)))
+		})
+	})
+
+	Context("#NewRunner", func() {
+		It("drains when signalled, and logs what did not finish", func() {
+			mountInFlight()
+			defer close(release)
+
+			logger := lagertest.NewTestLogger("drain")
+			process := ifrit.Invoke(efsdrain.NewRunner(logger, drainer, fakeClock, time.Minute))
+			process.Signal(os.Interrupt)
+
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(logger.Buffer()).To(gbytes.Say(`unfinished-operation.*"detail":"/volumes/a".*"operation":"mount"`))
+			Expect(logger.Buffer()).To(gbytes.Say(`drained.*"unfinished":1`))
+		})
+	})
+})
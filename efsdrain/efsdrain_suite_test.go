@@ -0,0 +1,13 @@
+package efsdrain_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEfsDrain(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EFS Drain Suite")
+}
@@ -0,0 +1,34 @@
+package efsdrain
+
+import (
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+)
+
+type drainedMounter struct {
+	mounter efsmounter.Mounter
+	drainer *Drainer
+}
+
+// Mounter tracks the mounts and unmounts of mounter while they run.
+func (d *Drainer) Mounter(mounter efsmounter.Mounter) efsmounter.Mounter {
+	return &drainedMounter{mounter: mounter, drainer: d}
+}
+
+func (d *drainedMounter) Mount(env dockerdriver.Env, source string, target string, opts map[string]interface{}) error {
+	defer d.drainer.begin("mount", target)()
+	return d.mounter.Mount(env, source, target, opts)
+}
+
+func (d *drainedMounter) Unmount(env dockerdriver.Env, target string) error {
+	defer d.drainer.begin("unmount", target)()
+	return d.mounter.Unmount(env, target)
+}
+
+func (d *drainedMounter) Check(env dockerdriver.Env, name, mountPoint string) bool {
+	return d.mounter.Check(env, name, mountPoint)
+}
+
+func (d *drainedMounter) Purge(env dockerdriver.Env, path string) {
+	d.mounter.Purge(env, path)
+}
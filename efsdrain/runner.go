@@ -0,0 +1,36 @@
+package efsdrain
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// NewRunner drains drainer when signalled to stop, waiting up to grace, and
+// logs the operations that did not finish. Run it as the last member of an
+// ordered group, so that it is stopped, and the draining done, before the
+// servers are.
+func NewRunner(logger lager.Logger, drainer *Drainer, clock clock.Clock, grace time.Duration) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		close(ready)
+		<-signals
+
+		logger := logger.Session("drain", lager.Data{"grace-period": grace.String()})
+		logger.Info("start")
+		defer logger.Info("end")
+
+		unfinished := drainer.Drain(grace)
+		for _, operation := range unfinished {
+			logger.Info("unfinished-operation", lager.Data{
+				"operation":   operation.Name,
+				"detail":      operation.Detail,
+				"running-for": clock.Since(operation.Started).String(),
+			})
+		}
+		logger.Info("drained", lager.Data{"unfinished": len(unfinished)})
+		return nil
+	})
+}
@@ -0,0 +1,69 @@
+package efsdrain
+
+import (
+	"fmt"
+	"io"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+type drainedVolTools struct {
+	tools   efsvoltools.VolTools
+	drainer *Drainer
+}
+
+// VolTools tracks every operation of tools while it runs, named after its
+// route. Capabilities is not an operation and is passed straight through.
+func (d *Drainer) VolTools(tools efsvoltools.VolTools) efsvoltools.VolTools {
+	return &drainedVolTools{tools: tools, drainer: d}
+}
+
+func (d *drainedVolTools) OpenPerms(env dockerdriver.Env, request efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
+	defer d.drainer.begin(efsvoltools.OpenPermsRoute, request.Name)()
+	return d.tools.OpenPerms(env, request)
+}
+
+func (d *drainedVolTools) BatchOpenPerms(env dockerdriver.Env, request efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse {
+	defer d.drainer.begin(efsvoltools.BatchOpenPermsRoute, fmt.Sprintf("%d entries", len(request.Entries)))()
+	return d.tools.BatchOpenPerms(env, request)
+}
+
+func (d *drainedVolTools) RepairPerms(env dockerdriver.Env, request efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+	defer d.drainer.begin(efsvoltools.RepairPermsRoute, request.Name)()
+	return d.tools.RepairPerms(env, request)
+}
+
+func (d *drainedVolTools) Export(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+	defer d.drainer.begin(efsvoltools.ExportRoute, request.Name)()
+	return d.tools.Export(env, request, w)
+}
+
+func (d *drainedVolTools) Import(env dockerdriver.Env, request efsvoltools.ImportRequest, r io.Reader) efsvoltools.ErrorResponse {
+	defer d.drainer.begin(efsvoltools.ImportRoute, request.Name)()
+	return d.tools.Import(env, request, r)
+}
+
+func (d *drainedVolTools) CloneDirectory(env dockerdriver.Env, request efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse {
+	defer d.drainer.begin(efsvoltools.CloneRoute, request.Source.Name+" -> "+request.Destination.Name)()
+	return d.tools.CloneDirectory(env, request)
+}
+
+func (d *drainedVolTools) ListDirectories(env dockerdriver.Env, request efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse {
+	defer d.drainer.begin(efsvoltools.ListRoute, request.Name)()
+	return d.tools.ListDirectories(env, request)
+}
+
+func (d *drainedVolTools) GetACL(env dockerdriver.Env, request efsvoltools.GetACLRequest) efsvoltools.GetACLResponse {
+	defer d.drainer.begin(efsvoltools.GetACLRoute, request.Name)()
+	return d.tools.GetACL(env, request)
+}
+
+func (d *drainedVolTools) SetACL(env dockerdriver.Env, request efsvoltools.SetACLRequest) efsvoltools.ErrorResponse {
+	defer d.drainer.begin(efsvoltools.SetACLRoute, request.Name)()
+	return d.tools.SetACL(env, request)
+}
+
+func (d *drainedVolTools) Capabilities(env dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+	return d.tools.Capabilities(env)
+}
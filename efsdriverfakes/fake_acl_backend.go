@@ -0,0 +1,201 @@
+// Code generated by counterfeiter. DO NOT EDIT.
+package efsdriverfakes
+
+import (
+	sync "sync"
+
+	dockerdriver "code.cloudfoundry.org/dockerdriver"
+	efsvoltools "code.cloudfoundry.org/efsdriver/efsvoltools"
+	voltoolslocal "code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+)
+
+type FakeACLBackend struct {
+	GetACLStub        func(dockerdriver.Env, string) ([]efsvoltools.ACE, error)
+	getACLMutex       sync.RWMutex
+	getACLArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 string
+	}
+	getACLReturns struct {
+		result1 []efsvoltools.ACE
+		result2 error
+	}
+	getACLReturnsOnCall map[int]struct {
+		result1 []efsvoltools.ACE
+		result2 error
+	}
+	SetACLStub        func(dockerdriver.Env, string, []efsvoltools.ACE) error
+	setACLMutex       sync.RWMutex
+	setACLArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 string
+		arg3 []efsvoltools.ACE
+	}
+	setACLReturns struct {
+		result1 error
+	}
+	setACLReturnsOnCall map[int]struct {
+		result1 error
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeACLBackend) GetACL(arg1 dockerdriver.Env, arg2 string) ([]efsvoltools.ACE, error) {
+	fake.getACLMutex.Lock()
+	ret, specificReturn := fake.getACLReturnsOnCall[len(fake.getACLArgsForCall)]
+	fake.getACLArgsForCall = append(fake.getACLArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("GetACL", []interface{}{arg1, arg2})
+	fake.getACLMutex.Unlock()
+	if fake.GetACLStub != nil {
+		return fake.GetACLStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getACLReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeACLBackend) GetACLCallCount() int {
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	return len(fake.getACLArgsForCall)
+}
+
+func (fake *FakeACLBackend) GetACLCalls(stub func(dockerdriver.Env, string) ([]efsvoltools.ACE, error)) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = stub
+}
+
+func (fake *FakeACLBackend) GetACLArgsForCall(i int) (dockerdriver.Env, string) {
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	argsForCall := fake.getACLArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeACLBackend) GetACLReturns(result1 []efsvoltools.ACE, result2 error) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = nil
+	fake.getACLReturns = struct {
+		result1 []efsvoltools.ACE
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeACLBackend) GetACLReturnsOnCall(i int, result1 []efsvoltools.ACE, result2 error) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = nil
+	if fake.getACLReturnsOnCall == nil {
+		fake.getACLReturnsOnCall = make(map[int]struct {
+			result1 []efsvoltools.ACE
+			result2 error
+		})
+	}
+	fake.getACLReturnsOnCall[i] = struct {
+		result1 []efsvoltools.ACE
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeACLBackend) SetACL(arg1 dockerdriver.Env, arg2 string, arg3 []efsvoltools.ACE) error {
+	var arg3Copy []efsvoltools.ACE
+	if arg3 != nil {
+		arg3Copy = make([]efsvoltools.ACE, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.setACLMutex.Lock()
+	ret, specificReturn := fake.setACLReturnsOnCall[len(fake.setACLArgsForCall)]
+	fake.setACLArgsForCall = append(fake.setACLArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 string
+		arg3 []efsvoltools.ACE
+	}{arg1, arg2, arg3Copy})
+	fake.recordInvocation("SetACL", []interface{}{arg1, arg2, arg3Copy})
+	fake.setACLMutex.Unlock()
+	if fake.SetACLStub != nil {
+		return fake.SetACLStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.setACLReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeACLBackend) SetACLCallCount() int {
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
+	return len(fake.setACLArgsForCall)
+}
+
+func (fake *FakeACLBackend) SetACLCalls(stub func(dockerdriver.Env, string, []efsvoltools.ACE) error) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = stub
+}
+
+func (fake *FakeACLBackend) SetACLArgsForCall(i int) (dockerdriver.Env, string, []efsvoltools.ACE) {
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
+	argsForCall := fake.setACLArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeACLBackend) SetACLReturns(result1 error) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = nil
+	fake.setACLReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeACLBackend) SetACLReturnsOnCall(i int, result1 error) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = nil
+	if fake.setACLReturnsOnCall == nil {
+		fake.setACLReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.setACLReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeACLBackend) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
+}
+
+func (fake *FakeACLBackend) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ voltoolslocal.ACLBackend = new(FakeACLBackend)
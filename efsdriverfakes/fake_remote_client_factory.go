@@ -1,39 +1,63 @@
-// This file was generated by counterfeiter
+// Code generated by counterfeiter. DO NOT EDIT.
 package efsdriverfakes
 
 import (
-	"sync"
+	sync "sync"
 
-	"code.cloudfoundry.org/efsdriver/efsvoltools"
-	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
+	dockerdriver "code.cloudfoundry.org/dockerdriver"
+	efsvoltools "code.cloudfoundry.org/efsdriver/efsvoltools"
+	voltoolshttp "code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
 )
 
 type FakeEfsRemoteClientFactory struct {
-	NewRemoteClientStub        func(url string) (efsvoltools.VolTools, error)
+	NewRemoteClientStub        func(string) (efsvoltools.VolTools, error)
 	newRemoteClientMutex       sync.RWMutex
 	newRemoteClientArgsForCall []struct {
-		url string
+		arg1 string
 	}
 	newRemoteClientReturns struct {
 		result1 efsvoltools.VolTools
 		result2 error
 	}
+	newRemoteClientReturnsOnCall map[int]struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}
+	NewRemoteClientWithCredentialsStub        func(string, *dockerdriver.TLSConfig, voltoolshttp.TokenSource) (efsvoltools.VolTools, error)
+	newRemoteClientWithCredentialsMutex       sync.RWMutex
+	newRemoteClientWithCredentialsArgsForCall []struct {
+		arg1 string
+		arg2 *dockerdriver.TLSConfig
+		arg3 voltoolshttp.TokenSource
+	}
+	newRemoteClientWithCredentialsReturns struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}
+	newRemoteClientWithCredentialsReturnsOnCall map[int]struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeEfsRemoteClientFactory) NewRemoteClient(url string) (efsvoltools.VolTools, error) {
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClient(arg1 string) (efsvoltools.VolTools, error) {
 	fake.newRemoteClientMutex.Lock()
+	ret, specificReturn := fake.newRemoteClientReturnsOnCall[len(fake.newRemoteClientArgsForCall)]
 	fake.newRemoteClientArgsForCall = append(fake.newRemoteClientArgsForCall, struct {
-		url string
-	}{url})
-	fake.recordInvocation("NewRemoteClient", []interface{}{url})
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("NewRemoteClient", []interface{}{arg1})
 	fake.newRemoteClientMutex.Unlock()
 	if fake.NewRemoteClientStub != nil {
-		return fake.NewRemoteClientStub(url)
-	} else {
-		return fake.newRemoteClientReturns.result1, fake.newRemoteClientReturns.result2
+		return fake.NewRemoteClientStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
 	}
+	fakeReturns := fake.newRemoteClientReturns
+	return fakeReturns.result1, fakeReturns.result2
 }
 
 func (fake *FakeEfsRemoteClientFactory) NewRemoteClientCallCount() int {
@@ -42,13 +66,22 @@ func (fake *FakeEfsRemoteClientFactory) NewRemoteClientCallCount() int {
 	return len(fake.newRemoteClientArgsForCall)
 }
 
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientCalls(stub func(string) (efsvoltools.VolTools, error)) {
+	fake.newRemoteClientMutex.Lock()
+	defer fake.newRemoteClientMutex.Unlock()
+	fake.NewRemoteClientStub = stub
+}
+
 func (fake *FakeEfsRemoteClientFactory) NewRemoteClientArgsForCall(i int) string {
 	fake.newRemoteClientMutex.RLock()
 	defer fake.newRemoteClientMutex.RUnlock()
-	return fake.newRemoteClientArgsForCall[i].url
+	argsForCall := fake.newRemoteClientArgsForCall[i]
+	return argsForCall.arg1
 }
 
 func (fake *FakeEfsRemoteClientFactory) NewRemoteClientReturns(result1 efsvoltools.VolTools, result2 error) {
+	fake.newRemoteClientMutex.Lock()
+	defer fake.newRemoteClientMutex.Unlock()
 	fake.NewRemoteClientStub = nil
 	fake.newRemoteClientReturns = struct {
 		result1 efsvoltools.VolTools
@@ -56,12 +89,99 @@ func (fake *FakeEfsRemoteClientFactory) NewRemoteClientReturns(result1 efsvoltoo
 	}{result1, result2}
 }
 
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientReturnsOnCall(i int, result1 efsvoltools.VolTools, result2 error) {
+	fake.newRemoteClientMutex.Lock()
+	defer fake.newRemoteClientMutex.Unlock()
+	fake.NewRemoteClientStub = nil
+	if fake.newRemoteClientReturnsOnCall == nil {
+		fake.newRemoteClientReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.VolTools
+			result2 error
+		})
+	}
+	fake.newRemoteClientReturnsOnCall[i] = struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentials(arg1 string, arg2 *dockerdriver.TLSConfig, arg3 voltoolshttp.TokenSource) (efsvoltools.VolTools, error) {
+	fake.newRemoteClientWithCredentialsMutex.Lock()
+	ret, specificReturn := fake.newRemoteClientWithCredentialsReturnsOnCall[len(fake.newRemoteClientWithCredentialsArgsForCall)]
+	fake.newRemoteClientWithCredentialsArgsForCall = append(fake.newRemoteClientWithCredentialsArgsForCall, struct {
+		arg1 string
+		arg2 *dockerdriver.TLSConfig
+		arg3 voltoolshttp.TokenSource
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("NewRemoteClientWithCredentials", []interface{}{arg1, arg2, arg3})
+	fake.newRemoteClientWithCredentialsMutex.Unlock()
+	if fake.NewRemoteClientWithCredentialsStub != nil {
+		return fake.NewRemoteClientWithCredentialsStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.newRemoteClientWithCredentialsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentialsCallCount() int {
+	fake.newRemoteClientWithCredentialsMutex.RLock()
+	defer fake.newRemoteClientWithCredentialsMutex.RUnlock()
+	return len(fake.newRemoteClientWithCredentialsArgsForCall)
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentialsCalls(stub func(string, *dockerdriver.TLSConfig, voltoolshttp.TokenSource) (efsvoltools.VolTools, error)) {
+	fake.newRemoteClientWithCredentialsMutex.Lock()
+	defer fake.newRemoteClientWithCredentialsMutex.Unlock()
+	fake.NewRemoteClientWithCredentialsStub = stub
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentialsArgsForCall(i int) (string, *dockerdriver.TLSConfig, voltoolshttp.TokenSource) {
+	fake.newRemoteClientWithCredentialsMutex.RLock()
+	defer fake.newRemoteClientWithCredentialsMutex.RUnlock()
+	argsForCall := fake.newRemoteClientWithCredentialsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentialsReturns(result1 efsvoltools.VolTools, result2 error) {
+	fake.newRemoteClientWithCredentialsMutex.Lock()
+	defer fake.newRemoteClientWithCredentialsMutex.Unlock()
+	fake.NewRemoteClientWithCredentialsStub = nil
+	fake.newRemoteClientWithCredentialsReturns = struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeEfsRemoteClientFactory) NewRemoteClientWithCredentialsReturnsOnCall(i int, result1 efsvoltools.VolTools, result2 error) {
+	fake.newRemoteClientWithCredentialsMutex.Lock()
+	defer fake.newRemoteClientWithCredentialsMutex.Unlock()
+	fake.NewRemoteClientWithCredentialsStub = nil
+	if fake.newRemoteClientWithCredentialsReturnsOnCall == nil {
+		fake.newRemoteClientWithCredentialsReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.VolTools
+			result2 error
+		})
+	}
+	fake.newRemoteClientWithCredentialsReturnsOnCall[i] = struct {
+		result1 efsvoltools.VolTools
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeEfsRemoteClientFactory) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.newRemoteClientMutex.RLock()
 	defer fake.newRemoteClientMutex.RUnlock()
-	return fake.invocations
+	fake.newRemoteClientWithCredentialsMutex.RLock()
+	defer fake.newRemoteClientWithCredentialsMutex.RUnlock()
+	copiedInvocations := map[string][][]interface{}{}
+	for key, value := range fake.invocations {
+		copiedInvocations[key] = value
+	}
+	return copiedInvocations
 }
 
 func (fake *FakeEfsRemoteClientFactory) recordInvocation(key string, args []interface{}) {
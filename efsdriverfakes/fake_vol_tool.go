@@ -2,6 +2,7 @@
 package efsdriverfakes
 
 import (
+	io "io"
 	sync "sync"
 
 	dockerdriver "code.cloudfoundry.org/dockerdriver"
@@ -9,6 +10,91 @@ import (
 )
 
 type FakeVolTools struct {
+	BatchOpenPermsStub        func(dockerdriver.Env, efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse
+	batchOpenPermsMutex       sync.RWMutex
+	batchOpenPermsArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.BatchOpenPermsRequest
+	}
+	batchOpenPermsReturns struct {
+		result1 efsvoltools.BatchOpenPermsResponse
+	}
+	batchOpenPermsReturnsOnCall map[int]struct {
+		result1 efsvoltools.BatchOpenPermsResponse
+	}
+	CapabilitiesStub        func(dockerdriver.Env) efsvoltools.CapabilitiesResponse
+	capabilitiesMutex       sync.RWMutex
+	capabilitiesArgsForCall []struct {
+		arg1 dockerdriver.Env
+	}
+	capabilitiesReturns struct {
+		result1 efsvoltools.CapabilitiesResponse
+	}
+	capabilitiesReturnsOnCall map[int]struct {
+		result1 efsvoltools.CapabilitiesResponse
+	}
+	CloneDirectoryStub        func(dockerdriver.Env, efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse
+	cloneDirectoryMutex       sync.RWMutex
+	cloneDirectoryArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.CloneDirectoryRequest
+	}
+	cloneDirectoryReturns struct {
+		result1 efsvoltools.CloneDirectoryResponse
+	}
+	cloneDirectoryReturnsOnCall map[int]struct {
+		result1 efsvoltools.CloneDirectoryResponse
+	}
+	ExportStub        func(dockerdriver.Env, efsvoltools.ExportRequest, io.Writer) efsvoltools.ErrorResponse
+	exportMutex       sync.RWMutex
+	exportArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ExportRequest
+		arg3 io.Writer
+	}
+	exportReturns struct {
+		result1 efsvoltools.ErrorResponse
+	}
+	exportReturnsOnCall map[int]struct {
+		result1 efsvoltools.ErrorResponse
+	}
+	GetACLStub        func(dockerdriver.Env, efsvoltools.GetACLRequest) efsvoltools.GetACLResponse
+	getACLMutex       sync.RWMutex
+	getACLArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.GetACLRequest
+	}
+	getACLReturns struct {
+		result1 efsvoltools.GetACLResponse
+	}
+	getACLReturnsOnCall map[int]struct {
+		result1 efsvoltools.GetACLResponse
+	}
+	ImportStub        func(dockerdriver.Env, efsvoltools.ImportRequest, io.Reader) efsvoltools.ErrorResponse
+	importMutex       sync.RWMutex
+	importArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ImportRequest
+		arg3 io.Reader
+	}
+	importReturns struct {
+		result1 efsvoltools.ErrorResponse
+	}
+	importReturnsOnCall map[int]struct {
+		result1 efsvoltools.ErrorResponse
+	}
+	ListDirectoriesStub        func(dockerdriver.Env, efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse
+	listDirectoriesMutex       sync.RWMutex
+	listDirectoriesArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ListDirectoriesRequest
+	}
+	listDirectoriesReturns struct {
+		result1 efsvoltools.ListDirectoriesResponse
+	}
+	listDirectoriesReturnsOnCall map[int]struct {
+		result1 efsvoltools.ListDirectoriesResponse
+	}
 	OpenPermsStub        func(dockerdriver.Env, efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse
 	openPermsMutex       sync.RWMutex
 	openPermsArgsForCall []struct {
@@ -21,10 +107,462 @@ type FakeVolTools struct {
 	openPermsReturnsOnCall map[int]struct {
 		result1 efsvoltools.ErrorResponse
 	}
+	RepairPermsStub        func(dockerdriver.Env, efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse
+	repairPermsMutex       sync.RWMutex
+	repairPermsArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.RepairPermsRequest
+	}
+	repairPermsReturns struct {
+		result1 efsvoltools.RepairPermsResponse
+	}
+	repairPermsReturnsOnCall map[int]struct {
+		result1 efsvoltools.RepairPermsResponse
+	}
+	SetACLStub        func(dockerdriver.Env, efsvoltools.SetACLRequest) efsvoltools.ErrorResponse
+	setACLMutex       sync.RWMutex
+	setACLArgsForCall []struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.SetACLRequest
+	}
+	setACLReturns struct {
+		result1 efsvoltools.ErrorResponse
+	}
+	setACLReturnsOnCall map[int]struct {
+		result1 efsvoltools.ErrorResponse
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeVolTools) BatchOpenPerms(arg1 dockerdriver.Env, arg2 efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse {
+	fake.batchOpenPermsMutex.Lock()
+	ret, specificReturn := fake.batchOpenPermsReturnsOnCall[len(fake.batchOpenPermsArgsForCall)]
+	fake.batchOpenPermsArgsForCall = append(fake.batchOpenPermsArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.BatchOpenPermsRequest
+	}{arg1, arg2})
+	fake.recordInvocation("BatchOpenPerms", []interface{}{arg1, arg2})
+	fake.batchOpenPermsMutex.Unlock()
+	if fake.BatchOpenPermsStub != nil {
+		return fake.BatchOpenPermsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.batchOpenPermsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) BatchOpenPermsCallCount() int {
+	fake.batchOpenPermsMutex.RLock()
+	defer fake.batchOpenPermsMutex.RUnlock()
+	return len(fake.batchOpenPermsArgsForCall)
+}
+
+func (fake *FakeVolTools) BatchOpenPermsCalls(stub func(dockerdriver.Env, efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse) {
+	fake.batchOpenPermsMutex.Lock()
+	defer fake.batchOpenPermsMutex.Unlock()
+	fake.BatchOpenPermsStub = stub
+}
+
+func (fake *FakeVolTools) BatchOpenPermsArgsForCall(i int) (dockerdriver.Env, efsvoltools.BatchOpenPermsRequest) {
+	fake.batchOpenPermsMutex.RLock()
+	defer fake.batchOpenPermsMutex.RUnlock()
+	argsForCall := fake.batchOpenPermsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) BatchOpenPermsReturns(result1 efsvoltools.BatchOpenPermsResponse) {
+	fake.batchOpenPermsMutex.Lock()
+	defer fake.batchOpenPermsMutex.Unlock()
+	fake.BatchOpenPermsStub = nil
+	fake.batchOpenPermsReturns = struct {
+		result1 efsvoltools.BatchOpenPermsResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) BatchOpenPermsReturnsOnCall(i int, result1 efsvoltools.BatchOpenPermsResponse) {
+	fake.batchOpenPermsMutex.Lock()
+	defer fake.batchOpenPermsMutex.Unlock()
+	fake.BatchOpenPermsStub = nil
+	if fake.batchOpenPermsReturnsOnCall == nil {
+		fake.batchOpenPermsReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.BatchOpenPermsResponse
+		})
+	}
+	fake.batchOpenPermsReturnsOnCall[i] = struct {
+		result1 efsvoltools.BatchOpenPermsResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) Capabilities(arg1 dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+	fake.capabilitiesMutex.Lock()
+	ret, specificReturn := fake.capabilitiesReturnsOnCall[len(fake.capabilitiesArgsForCall)]
+	fake.capabilitiesArgsForCall = append(fake.capabilitiesArgsForCall, struct {
+		arg1 dockerdriver.Env
+	}{arg1})
+	fake.recordInvocation("Capabilities", []interface{}{arg1})
+	fake.capabilitiesMutex.Unlock()
+	if fake.CapabilitiesStub != nil {
+		return fake.CapabilitiesStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.capabilitiesReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) CapabilitiesCallCount() int {
+	fake.capabilitiesMutex.RLock()
+	defer fake.capabilitiesMutex.RUnlock()
+	return len(fake.capabilitiesArgsForCall)
+}
+
+func (fake *FakeVolTools) CapabilitiesCalls(stub func(dockerdriver.Env) efsvoltools.CapabilitiesResponse) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = stub
+}
+
+func (fake *FakeVolTools) CapabilitiesArgsForCall(i int) dockerdriver.Env {
+	fake.capabilitiesMutex.RLock()
+	defer fake.capabilitiesMutex.RUnlock()
+	argsForCall := fake.capabilitiesArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeVolTools) CapabilitiesReturns(result1 efsvoltools.CapabilitiesResponse) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = nil
+	fake.capabilitiesReturns = struct {
+		result1 efsvoltools.CapabilitiesResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) CapabilitiesReturnsOnCall(i int, result1 efsvoltools.CapabilitiesResponse) {
+	fake.capabilitiesMutex.Lock()
+	defer fake.capabilitiesMutex.Unlock()
+	fake.CapabilitiesStub = nil
+	if fake.capabilitiesReturnsOnCall == nil {
+		fake.capabilitiesReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.CapabilitiesResponse
+		})
+	}
+	fake.capabilitiesReturnsOnCall[i] = struct {
+		result1 efsvoltools.CapabilitiesResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) CloneDirectory(arg1 dockerdriver.Env, arg2 efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse {
+	fake.cloneDirectoryMutex.Lock()
+	ret, specificReturn := fake.cloneDirectoryReturnsOnCall[len(fake.cloneDirectoryArgsForCall)]
+	fake.cloneDirectoryArgsForCall = append(fake.cloneDirectoryArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.CloneDirectoryRequest
+	}{arg1, arg2})
+	fake.recordInvocation("CloneDirectory", []interface{}{arg1, arg2})
+	fake.cloneDirectoryMutex.Unlock()
+	if fake.CloneDirectoryStub != nil {
+		return fake.CloneDirectoryStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.cloneDirectoryReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) CloneDirectoryCallCount() int {
+	fake.cloneDirectoryMutex.RLock()
+	defer fake.cloneDirectoryMutex.RUnlock()
+	return len(fake.cloneDirectoryArgsForCall)
+}
+
+func (fake *FakeVolTools) CloneDirectoryCalls(stub func(dockerdriver.Env, efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse) {
+	fake.cloneDirectoryMutex.Lock()
+	defer fake.cloneDirectoryMutex.Unlock()
+	fake.CloneDirectoryStub = stub
+}
+
+func (fake *FakeVolTools) CloneDirectoryArgsForCall(i int) (dockerdriver.Env, efsvoltools.CloneDirectoryRequest) {
+	fake.cloneDirectoryMutex.RLock()
+	defer fake.cloneDirectoryMutex.RUnlock()
+	argsForCall := fake.cloneDirectoryArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) CloneDirectoryReturns(result1 efsvoltools.CloneDirectoryResponse) {
+	fake.cloneDirectoryMutex.Lock()
+	defer fake.cloneDirectoryMutex.Unlock()
+	fake.CloneDirectoryStub = nil
+	fake.cloneDirectoryReturns = struct {
+		result1 efsvoltools.CloneDirectoryResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) CloneDirectoryReturnsOnCall(i int, result1 efsvoltools.CloneDirectoryResponse) {
+	fake.cloneDirectoryMutex.Lock()
+	defer fake.cloneDirectoryMutex.Unlock()
+	fake.CloneDirectoryStub = nil
+	if fake.cloneDirectoryReturnsOnCall == nil {
+		fake.cloneDirectoryReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.CloneDirectoryResponse
+		})
+	}
+	fake.cloneDirectoryReturnsOnCall[i] = struct {
+		result1 efsvoltools.CloneDirectoryResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) Export(arg1 dockerdriver.Env, arg2 efsvoltools.ExportRequest, arg3 io.Writer) efsvoltools.ErrorResponse {
+	fake.exportMutex.Lock()
+	ret, specificReturn := fake.exportReturnsOnCall[len(fake.exportArgsForCall)]
+	fake.exportArgsForCall = append(fake.exportArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ExportRequest
+		arg3 io.Writer
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Export", []interface{}{arg1, arg2, arg3})
+	fake.exportMutex.Unlock()
+	if fake.ExportStub != nil {
+		return fake.ExportStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.exportReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) ExportCallCount() int {
+	fake.exportMutex.RLock()
+	defer fake.exportMutex.RUnlock()
+	return len(fake.exportArgsForCall)
+}
+
+func (fake *FakeVolTools) ExportCalls(stub func(dockerdriver.Env, efsvoltools.ExportRequest, io.Writer) efsvoltools.ErrorResponse) {
+	fake.exportMutex.Lock()
+	defer fake.exportMutex.Unlock()
+	fake.ExportStub = stub
+}
+
+func (fake *FakeVolTools) ExportArgsForCall(i int) (dockerdriver.Env, efsvoltools.ExportRequest, io.Writer) {
+	fake.exportMutex.RLock()
+	defer fake.exportMutex.RUnlock()
+	argsForCall := fake.exportArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeVolTools) ExportReturns(result1 efsvoltools.ErrorResponse) {
+	fake.exportMutex.Lock()
+	defer fake.exportMutex.Unlock()
+	fake.ExportStub = nil
+	fake.exportReturns = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) ExportReturnsOnCall(i int, result1 efsvoltools.ErrorResponse) {
+	fake.exportMutex.Lock()
+	defer fake.exportMutex.Unlock()
+	fake.ExportStub = nil
+	if fake.exportReturnsOnCall == nil {
+		fake.exportReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.ErrorResponse
+		})
+	}
+	fake.exportReturnsOnCall[i] = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) GetACL(arg1 dockerdriver.Env, arg2 efsvoltools.GetACLRequest) efsvoltools.GetACLResponse {
+	fake.getACLMutex.Lock()
+	ret, specificReturn := fake.getACLReturnsOnCall[len(fake.getACLArgsForCall)]
+	fake.getACLArgsForCall = append(fake.getACLArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.GetACLRequest
+	}{arg1, arg2})
+	fake.recordInvocation("GetACL", []interface{}{arg1, arg2})
+	fake.getACLMutex.Unlock()
+	if fake.GetACLStub != nil {
+		return fake.GetACLStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.getACLReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) GetACLCallCount() int {
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	return len(fake.getACLArgsForCall)
+}
+
+func (fake *FakeVolTools) GetACLCalls(stub func(dockerdriver.Env, efsvoltools.GetACLRequest) efsvoltools.GetACLResponse) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = stub
+}
+
+func (fake *FakeVolTools) GetACLArgsForCall(i int) (dockerdriver.Env, efsvoltools.GetACLRequest) {
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	argsForCall := fake.getACLArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) GetACLReturns(result1 efsvoltools.GetACLResponse) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = nil
+	fake.getACLReturns = struct {
+		result1 efsvoltools.GetACLResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) GetACLReturnsOnCall(i int, result1 efsvoltools.GetACLResponse) {
+	fake.getACLMutex.Lock()
+	defer fake.getACLMutex.Unlock()
+	fake.GetACLStub = nil
+	if fake.getACLReturnsOnCall == nil {
+		fake.getACLReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.GetACLResponse
+		})
+	}
+	fake.getACLReturnsOnCall[i] = struct {
+		result1 efsvoltools.GetACLResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) Import(arg1 dockerdriver.Env, arg2 efsvoltools.ImportRequest, arg3 io.Reader) efsvoltools.ErrorResponse {
+	fake.importMutex.Lock()
+	ret, specificReturn := fake.importReturnsOnCall[len(fake.importArgsForCall)]
+	fake.importArgsForCall = append(fake.importArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ImportRequest
+		arg3 io.Reader
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Import", []interface{}{arg1, arg2, arg3})
+	fake.importMutex.Unlock()
+	if fake.ImportStub != nil {
+		return fake.ImportStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.importReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) ImportCallCount() int {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	return len(fake.importArgsForCall)
+}
+
+func (fake *FakeVolTools) ImportCalls(stub func(dockerdriver.Env, efsvoltools.ImportRequest, io.Reader) efsvoltools.ErrorResponse) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = stub
+}
+
+func (fake *FakeVolTools) ImportArgsForCall(i int) (dockerdriver.Env, efsvoltools.ImportRequest, io.Reader) {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	argsForCall := fake.importArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeVolTools) ImportReturns(result1 efsvoltools.ErrorResponse) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	fake.importReturns = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) ImportReturnsOnCall(i int, result1 efsvoltools.ErrorResponse) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	if fake.importReturnsOnCall == nil {
+		fake.importReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.ErrorResponse
+		})
+	}
+	fake.importReturnsOnCall[i] = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) ListDirectories(arg1 dockerdriver.Env, arg2 efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse {
+	fake.listDirectoriesMutex.Lock()
+	ret, specificReturn := fake.listDirectoriesReturnsOnCall[len(fake.listDirectoriesArgsForCall)]
+	fake.listDirectoriesArgsForCall = append(fake.listDirectoriesArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.ListDirectoriesRequest
+	}{arg1, arg2})
+	fake.recordInvocation("ListDirectories", []interface{}{arg1, arg2})
+	fake.listDirectoriesMutex.Unlock()
+	if fake.ListDirectoriesStub != nil {
+		return fake.ListDirectoriesStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.listDirectoriesReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) ListDirectoriesCallCount() int {
+	fake.listDirectoriesMutex.RLock()
+	defer fake.listDirectoriesMutex.RUnlock()
+	return len(fake.listDirectoriesArgsForCall)
+}
+
+func (fake *FakeVolTools) ListDirectoriesCalls(stub func(dockerdriver.Env, efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse) {
+	fake.listDirectoriesMutex.Lock()
+	defer fake.listDirectoriesMutex.Unlock()
+	fake.ListDirectoriesStub = stub
+}
+
+func (fake *FakeVolTools) ListDirectoriesArgsForCall(i int) (dockerdriver.Env, efsvoltools.ListDirectoriesRequest) {
+	fake.listDirectoriesMutex.RLock()
+	defer fake.listDirectoriesMutex.RUnlock()
+	argsForCall := fake.listDirectoriesArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) ListDirectoriesReturns(result1 efsvoltools.ListDirectoriesResponse) {
+	fake.listDirectoriesMutex.Lock()
+	defer fake.listDirectoriesMutex.Unlock()
+	fake.ListDirectoriesStub = nil
+	fake.listDirectoriesReturns = struct {
+		result1 efsvoltools.ListDirectoriesResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) ListDirectoriesReturnsOnCall(i int, result1 efsvoltools.ListDirectoriesResponse) {
+	fake.listDirectoriesMutex.Lock()
+	defer fake.listDirectoriesMutex.Unlock()
+	fake.ListDirectoriesStub = nil
+	if fake.listDirectoriesReturnsOnCall == nil {
+		fake.listDirectoriesReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.ListDirectoriesResponse
+		})
+	}
+	fake.listDirectoriesReturnsOnCall[i] = struct {
+		result1 efsvoltools.ListDirectoriesResponse
+	}{result1}
+}
+
 func (fake *FakeVolTools) OpenPerms(arg1 dockerdriver.Env, arg2 efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
 	fake.openPermsMutex.Lock()
 	ret, specificReturn := fake.openPermsReturnsOnCall[len(fake.openPermsArgsForCall)]
@@ -86,11 +624,151 @@ func (fake *FakeVolTools) OpenPermsReturnsOnCall(i int, result1 efsvoltools.Erro
 	}{result1}
 }
 
+func (fake *FakeVolTools) RepairPerms(arg1 dockerdriver.Env, arg2 efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+	fake.repairPermsMutex.Lock()
+	ret, specificReturn := fake.repairPermsReturnsOnCall[len(fake.repairPermsArgsForCall)]
+	fake.repairPermsArgsForCall = append(fake.repairPermsArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.RepairPermsRequest
+	}{arg1, arg2})
+	fake.recordInvocation("RepairPerms", []interface{}{arg1, arg2})
+	fake.repairPermsMutex.Unlock()
+	if fake.RepairPermsStub != nil {
+		return fake.RepairPermsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.repairPermsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) RepairPermsCallCount() int {
+	fake.repairPermsMutex.RLock()
+	defer fake.repairPermsMutex.RUnlock()
+	return len(fake.repairPermsArgsForCall)
+}
+
+func (fake *FakeVolTools) RepairPermsCalls(stub func(dockerdriver.Env, efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse) {
+	fake.repairPermsMutex.Lock()
+	defer fake.repairPermsMutex.Unlock()
+	fake.RepairPermsStub = stub
+}
+
+func (fake *FakeVolTools) RepairPermsArgsForCall(i int) (dockerdriver.Env, efsvoltools.RepairPermsRequest) {
+	fake.repairPermsMutex.RLock()
+	defer fake.repairPermsMutex.RUnlock()
+	argsForCall := fake.repairPermsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) RepairPermsReturns(result1 efsvoltools.RepairPermsResponse) {
+	fake.repairPermsMutex.Lock()
+	defer fake.repairPermsMutex.Unlock()
+	fake.RepairPermsStub = nil
+	fake.repairPermsReturns = struct {
+		result1 efsvoltools.RepairPermsResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) RepairPermsReturnsOnCall(i int, result1 efsvoltools.RepairPermsResponse) {
+	fake.repairPermsMutex.Lock()
+	defer fake.repairPermsMutex.Unlock()
+	fake.RepairPermsStub = nil
+	if fake.repairPermsReturnsOnCall == nil {
+		fake.repairPermsReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.RepairPermsResponse
+		})
+	}
+	fake.repairPermsReturnsOnCall[i] = struct {
+		result1 efsvoltools.RepairPermsResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) SetACL(arg1 dockerdriver.Env, arg2 efsvoltools.SetACLRequest) efsvoltools.ErrorResponse {
+	fake.setACLMutex.Lock()
+	ret, specificReturn := fake.setACLReturnsOnCall[len(fake.setACLArgsForCall)]
+	fake.setACLArgsForCall = append(fake.setACLArgsForCall, struct {
+		arg1 dockerdriver.Env
+		arg2 efsvoltools.SetACLRequest
+	}{arg1, arg2})
+	fake.recordInvocation("SetACL", []interface{}{arg1, arg2})
+	fake.setACLMutex.Unlock()
+	if fake.SetACLStub != nil {
+		return fake.SetACLStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.setACLReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeVolTools) SetACLCallCount() int {
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
+	return len(fake.setACLArgsForCall)
+}
+
+func (fake *FakeVolTools) SetACLCalls(stub func(dockerdriver.Env, efsvoltools.SetACLRequest) efsvoltools.ErrorResponse) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = stub
+}
+
+func (fake *FakeVolTools) SetACLArgsForCall(i int) (dockerdriver.Env, efsvoltools.SetACLRequest) {
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
+	argsForCall := fake.setACLArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeVolTools) SetACLReturns(result1 efsvoltools.ErrorResponse) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = nil
+	fake.setACLReturns = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
+func (fake *FakeVolTools) SetACLReturnsOnCall(i int, result1 efsvoltools.ErrorResponse) {
+	fake.setACLMutex.Lock()
+	defer fake.setACLMutex.Unlock()
+	fake.SetACLStub = nil
+	if fake.setACLReturnsOnCall == nil {
+		fake.setACLReturnsOnCall = make(map[int]struct {
+			result1 efsvoltools.ErrorResponse
+		})
+	}
+	fake.setACLReturnsOnCall[i] = struct {
+		result1 efsvoltools.ErrorResponse
+	}{result1}
+}
+
 func (fake *FakeVolTools) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.batchOpenPermsMutex.RLock()
+	defer fake.batchOpenPermsMutex.RUnlock()
+	fake.capabilitiesMutex.RLock()
+	defer fake.capabilitiesMutex.RUnlock()
+	fake.cloneDirectoryMutex.RLock()
+	defer fake.cloneDirectoryMutex.RUnlock()
+	fake.exportMutex.RLock()
+	defer fake.exportMutex.RUnlock()
+	fake.getACLMutex.RLock()
+	defer fake.getACLMutex.RUnlock()
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	fake.listDirectoriesMutex.RLock()
+	defer fake.listDirectoriesMutex.RUnlock()
 	fake.openPermsMutex.RLock()
 	defer fake.openPermsMutex.RUnlock()
+	fake.repairPermsMutex.RLock()
+	defer fake.repairPermsMutex.RUnlock()
+	fake.setACLMutex.RLock()
+	defer fake.setACLMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,51 @@
+package efshealth
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	cf_http "code.cloudfoundry.org/cfhttp"
+)
+
+// FileExists checks that path exists, as the spec file the driver is
+// discovered through must.
+func FileExists(path string) Check {
+	return func() error {
+		_, err := os.Stat(path)
+		return err
+	}
+}
+
+// WritableDir checks that files can be created in dir, creating dir first if
+// need be, as the driver does before mounting into it.
+func WritableDir(dir string) Check {
+	return func() error {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		probe, err := ioutil.TempFile(dir, ".readyz-")
+		if err != nil {
+			return err
+		}
+		probe.Close()
+		return os.Remove(probe.Name())
+	}
+}
+
+// Executable checks that the program name is on the PATH.
+func Executable(name string) Check {
+	return func() error {
+		_, err := exec.LookPath(name)
+		return err
+	}
+}
+
+// TLSFiles checks that the certificate, key and certificate authority files
+// load, the way the servers load them.
+func TLSFiles(certFile, keyFile, caFile string) Check {
+	return func() error {
+		_, err := cf_http.NewTLSConfig(certFile, keyFile, caFile)
+		return err
+	}
+}
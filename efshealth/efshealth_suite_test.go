@@ -0,0 +1,13 @@
+package efshealth_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEfsHealth(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EFS Health Suite")
+}
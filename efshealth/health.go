@@ -0,0 +1,105 @@
+package efshealth
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Routes served by Handler.
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Statuses reported for the process and its components.
+const (
+	StatusOK       = "ok"
+	StatusReady    = "ready"
+	StatusNotReady = "not ready"
+	StatusFailing  = "failing"
+)
+
+// Check reports why a component is not ready, or nil if it is.
+type Check func() error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker decides whether the driver is ready to serve, from the checks of
+// its components.
+type Checker struct {
+	lock   sync.Mutex
+	checks []namedCheck
+}
+
+func NewChecker() *Checker {
+	return &Checker{}
+}
+
+// Add makes readiness depend on check, reported under name.
+func (c *Checker) Add(name string, check Check) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+type ComponentStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
+
+// Ready runs every check. The driver is ready when they all pass.
+func (c *Checker) Ready() (bool, Report) {
+	c.lock.Lock()
+	checks := append([]namedCheck{}, c.checks...)
+	c.lock.Unlock()
+
+	ready := true
+	report := Report{Status: StatusReady, Components: map[string]ComponentStatus{}}
+	for _, check := range checks {
+		status := ComponentStatus{Status: StatusOK}
+		if err := check.check(); err != nil {
+			ready = false
+			status = ComponentStatus{Status: StatusFailing, Error: err.Error()}
+		}
+		report.Components[check.name] = status
+	}
+	if !ready {
+		report.Status = StatusNotReady
+	}
+	return ready, report
+}
+
+// Handler serves LivenessPath, which succeeds for as long as the process can
+// answer, and ReadinessPath, which fails with 503 Service Unavailable unless
+// every check passes. Both describe the outcome in JSON.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, http.StatusOK, Report{Status: StatusOK})
+	})
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, req *http.Request) {
+		ready, report := c.Ready()
+		code := http.StatusOK
+		if !ready {
+			code = http.StatusServiceUnavailable
+		}
+		writeReport(w, code, report)
+	})
+	return mux
+}
+
+func writeReport(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,115 @@
+package efshealth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/efsdriver/efshealth"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	var (
+		checker *efshealth.Checker
+		server  *httptest.Server
+	)
+
+	BeforeEach(func() {
+		checker = efshealth.NewChecker()
+		server = httptest.NewServer(checker.Handler())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	get := func(path string) (int, efshealth.Report) {
+		response, err := http.Get(server.URL + path)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		Expect(response.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var report efshealth.Report
+		Expect(json.NewDecoder(response.Body).Decode(&report)).To(Succeed())
+		return response.StatusCode, report
+	}
+
+	It("is ready when every check passes", func() {
+		checker.Add("first", func() error { return nil })
+		checker.Add("second", func() error { return nil })
+
+		code, report := get(efshealth.ReadinessPath)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report).To(Equal(efshealth.Report{
+			Status: efshealth.StatusReady,
+			Components: map[string]efshealth.ComponentStatus{
+				"first":  {Status: efshealth.StatusOK},
+				"second": {Status: efshealth.StatusOK},
+			},
+		}))
+	})
+
+	It("is not ready, but still alive, when a check fails", func() {
+		checker.Add("first", func() error { return nil })
+		checker.Add("second", func() error { return errors.New("gone missing") })
+
+		code, report := get(efshealth.ReadinessPath)
+		Expect(code).To(Equal(http.StatusServiceUnavailable))
+		Expect(report.Status).To(Equal(efshealth.StatusNotReady))
+		Expect(report.Components["first"]).To(Equal(efshealth.ComponentStatus{Status: efshealth.StatusOK}))
+		Expect(report.Components["second"]).To(Equal(efshealth.ComponentStatus{Status: efshealth.StatusFailing, Error: "gone missing"}))
+
+		code, report = get(efshealth.LivenessPath)
+		Expect(code).To(Equal(http.StatusOK))
+		Expect(report.Status).To(Equal(efshealth.StatusOK))
+	})
+
+	Context("checks", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "health")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("finds files", func() {
+			Expect(efshealth.FileExists(dir)()).To(Succeed())
+			Expect(efshealth.FileExists(filepath.Join(dir, "efsdriver.json"))()).NotTo(Succeed())
+		})
+
+		It("creates the directory and leaves nothing behind when it is writable", func() {
+			mountDir := filepath.Join(dir, "volumes")
+			Expect(efshealth.WritableDir(mountDir)()).To(Succeed())
+
+			entries, err := ioutil.ReadDir(mountDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("fails for directories that cannot be written to", func() {
+			file := filepath.Join(dir, "file")
+			Expect(ioutil.WriteFile(file, nil, 0644)).To(Succeed())
+			Expect(efshealth.WritableDir(file)()).NotTo(Succeed())
+		})
+
+		It("looks for executables on the PATH", func() {
+			Expect(efshealth.Executable("sh")()).To(Succeed())
+			Expect(efshealth.Executable("no-such-binary")()).NotTo(Succeed())
+		})
+
+		It("fails for TLS files that do not load", func() {
+			Expect(efshealth.TLSFiles(filepath.Join(dir, "cert"), filepath.Join(dir, "key"), "")()).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package efsmetrics_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEfsMetrics(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EFS Metrics Suite")
+}
@@ -0,0 +1,190 @@
+package efsmetrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+// Results and error classes used as label values. Voltools failures are
+// classed by their efsvoltools.ErrorCode where the operation reports one.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	ErrorClassNone         = "none"
+	ErrorClassTimeout      = "timeout"
+	ErrorClassCancelled    = "cancelled"
+	ErrorClassNotMounted   = "not_mounted"
+	ErrorClassFailed       = "failed"
+	ErrorClassUnclassified = "unclassified"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the latency
+// histograms. They reach further than usual because voltools operations walk
+// whole file systems.
+var DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// Metrics instruments the mounter and the volume tools. Wrap them with
+// Mounter, VolToolsMounter and VolTools, and serve Handler to Prometheus.
+// Every observation is also passed to the emitters, if any.
+type Metrics struct {
+	registry *Registry
+	clock    clock.Clock
+	emitters []Emitter
+
+	mountOperations    *CounterVec
+	mountDurations     *HistogramVec
+	volToolsOperations *CounterVec
+	volToolsDurations  *HistogramVec
+	activeMounts       *GaugeVec
+	inFlight           *GaugeVec
+
+	mountsLock sync.Mutex
+	mounts     map[string]bool
+}
+
+func New(clock clock.Clock, emitters ...Emitter) *Metrics {
+	registry := NewRegistry()
+	m := &Metrics{
+		registry: registry,
+		clock:    clock,
+		emitters: emitters,
+
+		mountOperations: registry.NewCounterVec("efsdriver_mount_operations_total",
+			"Mount, unmount and check operations, by result and error class.", "operation", "result", "error_class"),
+		mountDurations: registry.NewHistogramVec("efsdriver_mount_operation_duration_seconds",
+			"How long mount, unmount and check operations took.", DurationBuckets, "operation", "result"),
+		volToolsOperations: registry.NewCounterVec("efsdriver_voltools_operations_total",
+			"Volume tools operations, by result and error class.", "operation", "result", "error_class"),
+		volToolsDurations: registry.NewHistogramVec("efsdriver_voltools_operation_duration_seconds",
+			"How long volume tools operations took.", DurationBuckets, "operation", "result"),
+		activeMounts: registry.NewGaugeVec("efsdriver_active_mounts",
+			"File systems this process has mounted and not yet unmounted."),
+		inFlight: registry.NewGaugeVec("efsdriver_operations_in_flight",
+			"Mount and volume tools operations currently running.", "operation"),
+
+		mounts: map[string]bool{},
+	}
+	m.activeMounts.Set(0)
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return m.registry.Handler()
+}
+
+// start marks operation as in flight and returns a function that takes it out
+// again and reports how long it ran.
+func (m *Metrics) start(operation string) func() time.Duration {
+	m.emitInFlight(operation, m.inFlight.Add(1, operation))
+	started := m.clock.Now()
+	return func() time.Duration {
+		m.emitInFlight(operation, m.inFlight.Add(-1, operation))
+		return m.clock.Since(started)
+	}
+}
+
+func (m *Metrics) emitInFlight(operation string, inFlight float64) {
+	for _, emitter := range m.emitters {
+		emitter.Gauge("operations_in_flight", inFlight, map[string]string{"operation": operation})
+	}
+}
+
+func (m *Metrics) observeMount(operation string, duration time.Duration, errorClass string) {
+	result := resultOf(errorClass)
+	m.mountOperations.Inc(operation, result, errorClass)
+	m.mountDurations.Observe(duration.Seconds(), operation, result)
+	m.emitOperation("mount", operation, duration, result, errorClass)
+}
+
+func (m *Metrics) observeVolTools(operation string, duration time.Duration, errorClass string) {
+	result := resultOf(errorClass)
+	m.volToolsOperations.Inc(operation, result, errorClass)
+	m.volToolsDurations.Observe(duration.Seconds(), operation, result)
+	m.emitOperation("voltools", operation, duration, result, errorClass)
+}
+
+func (m *Metrics) emitOperation(kind, operation string, duration time.Duration, result, errorClass string) {
+	for _, emitter := range m.emitters {
+		emitter.Count(kind+".operations", map[string]string{"operation": operation, "result": result, "error_class": errorClass})
+		emitter.Timing(kind+".duration", duration, map[string]string{"operation": operation, "result": result})
+	}
+}
+
+// AdoptMounts counts the file systems in records as active mounts. It is for
+// mounts made before the mounter was instrumented, such as the ones adopted
+// when the driver starts.
+func (m *Metrics) AdoptMounts(records []efsmounter.MountRecord) {
+	m.mountsLock.Lock()
+	defer m.mountsLock.Unlock()
+	for _, record := range records {
+		m.mounts[record.Target] = true
+	}
+	m.reportActiveMounts()
+}
+
+func (m *Metrics) mounted(target string, mounted bool) {
+	m.mountsLock.Lock()
+	defer m.mountsLock.Unlock()
+	if mounted {
+		m.mounts[target] = true
+	} else {
+		delete(m.mounts, target)
+	}
+	m.reportActiveMounts()
+}
+
+// reportActiveMounts must be called with the mountsLock held.
+func (m *Metrics) reportActiveMounts() {
+	m.activeMounts.Set(float64(len(m.mounts)))
+	for _, emitter := range m.emitters {
+		emitter.Gauge("active_mounts", float64(len(m.mounts)), nil)
+	}
+}
+
+func resultOf(errorClass string) string {
+	if errorClass == ErrorClassNone {
+		return ResultSuccess
+	}
+	return ResultFailure
+}
+
+// errorClass classes the error of an operation run under env, telling
+// timeouts and cancellations apart from other failures.
+func errorClass(env dockerdriver.Env, err error) string {
+	if err == nil {
+		return ErrorClassNone
+	}
+	ctxErr := env.Context().Err()
+	switch {
+	case errors.Is(err, context.DeadlineExceeded) || ctxErr == context.DeadlineExceeded:
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled) || ctxErr == context.Canceled:
+		return ErrorClassCancelled
+	}
+	return ErrorClassFailed
+}
+
+// responseErrorClass classes a voltools response by its error code, when it
+// has one.
+func responseErrorClass(env dockerdriver.Env, err string, code efsvoltools.ErrorCode) string {
+	switch {
+	case err == "":
+		return ErrorClassNone
+	case code != "":
+		return string(code)
+	case env.Context().Err() == context.DeadlineExceeded:
+		return ErrorClassTimeout
+	case env.Context().Err() == context.Canceled:
+		return ErrorClassCancelled
+	}
+	return ErrorClassUnclassified
+}
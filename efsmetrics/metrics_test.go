@@ -0,0 +1,150 @@
+package efsmetrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsmetrics"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Metrics", func() {
+	var (
+		env       dockerdriver.Env
+		fakeClock *fakeclock.FakeClock
+		metrics   *efsmetrics.Metrics
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("metrics"), context.TODO())
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		metrics = efsmetrics.New(fakeClock)
+	})
+
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		return recorder.Body.String()
+	}
+
+	Context("when instrumenting the mounter", func() {
+		var fakeMounter *efsdriverfakes.FakeMounter
+
+		BeforeEach(func() {
+			fakeMounter = &efsdriverfakes.FakeMounter{}
+			fakeMounter.MountStub = func(dockerdriver.Env, string, string, map[string]interface{}) error {
+				fakeClock.Increment(300 * time.Millisecond)
+				return nil
+			}
+		})
+
+		It("counts and times mounts and tracks active mounts", func() {
+			mounter := metrics.Mounter(fakeMounter)
+			Expect(mounter.Mount(env, "10.0.0.1:/", "/mnt/a", nil)).To(Succeed())
+			Expect(mounter.Mount(env, "10.0.0.1:/", "/mnt/b", nil)).To(Succeed())
+			Expect(mounter.Unmount(env, "/mnt/a")).To(Succeed())
+
+			Expect(fakeMounter.MountCallCount()).To(Equal(2))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="mount",result="success",error_class="none"} 2`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="unmount",result="success",error_class="none"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operation_duration_seconds_bucket{operation="mount",result="success",le="0.25"} 0`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operation_duration_seconds_bucket{operation="mount",result="success",le="0.5"} 2`))
+			Expect(scrape()).To(ContainSubstring("efsdriver_active_mounts 1\n"))
+		})
+
+		It("counts adopted mounts as active until they are unmounted", func() {
+			metrics.AdoptMounts([]efsmounter.MountRecord{
+				{Target: "/mnt/a", Source: "10.0.0.1:/"},
+				{Target: "/mnt/b", Source: "10.0.0.1:/"},
+			})
+			Expect(scrape()).To(ContainSubstring("efsdriver_active_mounts 2\n"))
+
+			mounter := metrics.Mounter(fakeMounter)
+			Expect(mounter.Unmount(env, "/mnt/a")).To(Succeed())
+			Expect(scrape()).To(ContainSubstring("efsdriver_active_mounts 1\n"))
+		})
+
+		It("leaves the mounts of the volume tools out of the active mounts", func() {
+			mounter := metrics.VolToolsMounter(fakeMounter)
+			Expect(mounter.Mount(env, "10.0.0.1:/", "/staging/a", nil)).To(Succeed())
+
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="mount",result="success",error_class="none"} 1`))
+			Expect(scrape()).To(ContainSubstring("efsdriver_active_mounts 0\n"))
+		})
+
+		It("classes failures", func() {
+			fakeMounter.MountReturns(errors.New("mount.nfs: access denied"))
+			fakeMounter.MountStub = nil
+			fakeMounter.CheckReturns(false)
+			expired, cancel := context.WithDeadline(context.TODO(), time.Now().Add(-time.Second))
+			defer cancel()
+
+			mounter := metrics.Mounter(fakeMounter)
+			Expect(mounter.Mount(env, "10.0.0.1:/", "/mnt/a", nil)).NotTo(Succeed())
+			Expect(mounter.Mount(driverhttp.EnvWithContext(expired, env), "10.0.0.1:/", "/mnt/a", nil)).NotTo(Succeed())
+			Expect(mounter.Check(env, "a", "/mnt/a")).To(BeFalse())
+
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="mount",result="failure",error_class="failed"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="mount",result="failure",error_class="timeout"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_mount_operations_total{operation="check",result="failure",error_class="not_mounted"} 1`))
+			Expect(scrape()).To(ContainSubstring("efsdriver_active_mounts 0\n"))
+		})
+	})
+
+	Context("when instrumenting the volume tools", func() {
+		var (
+			fakeVolTools *efsdriverfakes.FakeVolTools
+			volTools     efsvoltools.VolTools
+		)
+
+		BeforeEach(func() {
+			fakeVolTools = &efsdriverfakes.FakeVolTools{}
+			volTools = metrics.VolTools(fakeVolTools)
+		})
+
+		It("labels operations by route, result and error code", func() {
+			fakeVolTools.OpenPermsReturnsOnCall(1, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeMountFailed, "badness"))
+			fakeVolTools.ListDirectoriesReturns(efsvoltools.ListDirectoriesResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeNotFound, "badness")})
+			fakeVolTools.GetACLReturns(efsvoltools.GetACLResponse{ErrorResponse: efsvoltools.ErrorResponse{Err: "badness"}})
+
+			volTools.OpenPerms(env, efsvoltools.OpenPermsRequest{})
+			volTools.OpenPerms(env, efsvoltools.OpenPermsRequest{})
+			volTools.ListDirectories(env, efsvoltools.ListDirectoriesRequest{})
+			volTools.GetACL(env, efsvoltools.GetACLRequest{})
+
+			Expect(scrape()).To(ContainSubstring(`efsdriver_voltools_operations_total{operation="openPerms",result="success",error_class="none"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_voltools_operations_total{operation="openPerms",result="failure",error_class="mount_failed"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_voltools_operations_total{operation="listDirectories",result="failure",error_class="not_found"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_voltools_operations_total{operation="getACL",result="failure",error_class="unclassified"} 1`))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_voltools_operation_duration_seconds_count{operation="openPerms",result="success"} 1`))
+		})
+
+		It("reports operations in flight", func() {
+			fakeVolTools.RepairPermsStub = func(dockerdriver.Env, efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+				Expect(scrape()).To(ContainSubstring(`efsdriver_operations_in_flight{operation="repairPerms"} 1`))
+				return efsvoltools.RepairPermsResponse{}
+			}
+
+			volTools.RepairPerms(env, efsvoltools.RepairPermsRequest{})
+			Expect(fakeVolTools.RepairPermsCallCount()).To(Equal(1))
+			Expect(scrape()).To(ContainSubstring(`efsdriver_operations_in_flight{operation="repairPerms"} 0`))
+		})
+
+		It("passes capabilities through without counting them", func() {
+			fakeVolTools.CapabilitiesReturns(efsvoltools.CapabilitiesResponse{APIVersion: efsvoltools.APIVersion})
+
+			Expect(volTools.Capabilities(env).APIVersion).To(Equal(efsvoltools.APIVersion))
+			Expect(scrape()).NotTo(ContainSubstring("capabilities"))
+		})
+	})
+})
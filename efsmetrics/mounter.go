@@ -0,0 +1,60 @@
+package efsmetrics
+
+import (
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+)
+
+type instrumentedMounter struct {
+	mounter     efsmounter.Mounter
+	metrics     *Metrics
+	trackMounts bool
+}
+
+// Mounter counts and times the operations of mounter, and keeps track of the
+// file systems it has mounted.
+func (m *Metrics) Mounter(mounter efsmounter.Mounter) efsmounter.Mounter {
+	return &instrumentedMounter{mounter: mounter, metrics: m, trackMounts: true}
+}
+
+// VolToolsMounter counts and times the operations of mounter like Mounter,
+// but leaves its file systems out of the active mounts. The volume tools only
+// mount a file system for as long as one of their operations runs.
+func (m *Metrics) VolToolsMounter(mounter efsmounter.Mounter) efsmounter.Mounter {
+	return &instrumentedMounter{mounter: mounter, metrics: m}
+}
+
+func (i *instrumentedMounter) Mount(env dockerdriver.Env, source string, target string, opts map[string]interface{}) error {
+	done := i.metrics.start("mount")
+	err := i.mounter.Mount(env, source, target, opts)
+	i.metrics.observeMount("mount", done(), errorClass(env, err))
+	if err == nil && i.trackMounts {
+		i.metrics.mounted(target, true)
+	}
+	return err
+}
+
+func (i *instrumentedMounter) Unmount(env dockerdriver.Env, target string) error {
+	done := i.metrics.start("unmount")
+	err := i.mounter.Unmount(env, target)
+	i.metrics.observeMount("unmount", done(), errorClass(env, err))
+	if err == nil && i.trackMounts {
+		i.metrics.mounted(target, false)
+	}
+	return err
+}
+
+func (i *instrumentedMounter) Check(env dockerdriver.Env, name, mountPoint string) bool {
+	done := i.metrics.start("check")
+	mounted := i.mounter.Check(env, name, mountPoint)
+	class := ErrorClassNone
+	if !mounted {
+		class = ErrorClassNotMounted
+	}
+	i.metrics.observeMount("check", done(), class)
+	return mounted
+}
+
+func (i *instrumentedMounter) Purge(env dockerdriver.Env, path string) {
+	i.mounter.Purge(env, path)
+}
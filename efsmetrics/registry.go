@@ -0,0 +1,256 @@
+package efsmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Registry holds metric families and writes them out in the Prometheus text
+// exposition format.
+type Registry struct {
+	lock     sync.Mutex
+	families []family
+}
+
+type family interface {
+	write(w io.Writer)
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(f family) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.families = append(r.families, f)
+}
+
+// Write writes every family registered so far, in the order they were
+// registered.
+func (r *Registry) Write(w io.Writer) {
+	r.lock.Lock()
+	families := append([]family{}, r.families...)
+	r.lock.Unlock()
+
+	buffered := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(buffered)
+	}
+	buffered.Flush()
+}
+
+// Handler serves the registry to a Prometheus scraper.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// vec is what every family has in common: a name, help text and one series
+// for every combination of label values seen.
+type vec struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+
+	lock   sync.Mutex
+	series map[string]interface{}
+	values map[string][]string
+}
+
+func newVec(name, help, kind string, labels []string) vec {
+	return vec{name: name, help: help, kind: kind, labels: labels, series: map[string]interface{}{}, values: map[string][]string{}}
+}
+
+// get returns the series for labelValues, creating it with create the first
+// time. Callers must hold v.lock.
+func (v *vec) get(labelValues []string, create func() interface{}) interface{} {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metric %s takes %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	series, ok := v.series[key]
+	if !ok {
+		series = create()
+		v.series[key] = series
+		v.values[key] = append([]string{}, labelValues...)
+	}
+	return series
+}
+
+// each calls fn for every series, ordered by label values so the output is
+// stable. Callers must hold v.lock.
+func (v *vec) each(fn func(labelValues []string, series interface{})) {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fn(v.values[key], v.series[key])
+	}
+}
+
+func (v *vec) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", v.name, escapeHelp(v.help), v.name, v.kind)
+}
+
+// CounterVec counts events, partitioned by its labels.
+type CounterVec struct {
+	vec
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(delta float64, labelValues ...string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	value := c.get(labelValues, func() interface{} { return new(float64) }).(*float64)
+	*value += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.writeHeader(w)
+	c.each(func(labelValues []string, series interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", c.name, formatLabels(c.labels, labelValues), formatValue(*series.(*float64)))
+	})
+}
+
+// GaugeVec holds values that go up and down, partitioned by its labels.
+type GaugeVec struct {
+	vec
+}
+
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+func (g *GaugeVec) Set(value float64, labelValues ...string) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	*g.get(labelValues, func() interface{} { return new(float64) }).(*float64) = value
+}
+
+// Add changes the value of the gauge by delta and returns the new value.
+func (g *GaugeVec) Add(delta float64, labelValues ...string) float64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	value := g.get(labelValues, func() interface{} { return new(float64) }).(*float64)
+	*value += delta
+	return *value
+}
+
+func (g *GaugeVec) write(w io.Writer) {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.writeHeader(w)
+	g.each(func(labelValues []string, series interface{}) {
+		fmt.Fprintf(w, "%s%s %s\n", g.name, formatLabels(g.labels, labelValues), formatValue(*series.(*float64)))
+	})
+}
+
+// HistogramVec counts observations, such as latencies, into cumulative
+// buckets, partitioned by its labels.
+type HistogramVec struct {
+	vec
+	buckets []float64
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	sorted := append([]float64{}, buckets...)
+	sort.Float64s(sorted)
+	h := &HistogramVec{vec: newVec(name, help, "histogram", labels), buckets: sorted}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	series := h.get(labelValues, func() interface{} { return &histogram{counts: make([]uint64, len(h.buckets))} }).(*histogram)
+	for i, bound := range h.buckets {
+		if value <= bound {
+			series.counts[i]++
+		}
+	}
+	series.count++
+	series.sum += value
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.writeHeader(w)
+	bucketLabels := append(append([]string{}, h.labels...), "le")
+	h.each(func(labelValues []string, s interface{}) {
+		series := s.(*histogram)
+		for i, bound := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, append(append([]string{}, labelValues...), formatValue(bound))), series.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, formatLabels(bucketLabels, append(append([]string{}, labelValues...), "+Inf")), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.name, formatLabels(h.labels, labelValues), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, formatLabels(h.labels, labelValues), series.count)
+	})
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + `="` + escapeLabelValue(values[i]) + `"`
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
@@ -0,0 +1,78 @@
+package efsmetrics_test
+
+import (
+	"bytes"
+	"net/http/httptest"
+
+	"code.cloudfoundry.org/efsdriver/efsmetrics"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var registry *efsmetrics.Registry
+
+	BeforeEach(func() {
+		registry = efsmetrics.NewRegistry()
+	})
+
+	output := func() string {
+		buffer := &bytes.Buffer{}
+		registry.Write(buffer)
+		return buffer.String()
+	}
+
+	It("writes counters and gauges in the text exposition format", func() {
+		counter := registry.NewCounterVec("some_total", "Some things.", "kind")
+		counter.Inc("b")
+		counter.Add(2, "a")
+		counter.Inc("b")
+		gauge := registry.NewGaugeVec("some_gauge", "A level.")
+		gauge.Set(3)
+		gauge.Add(-1)
+
+		Expect(output()).To(Equal(`# HELP some_total Some things.
+# TYPE some_total counter
+some_total{kind="a"} 2
+some_total{kind="b"} 2
+# HELP some_gauge A level.
+# TYPE some_gauge gauge
+some_gauge 2
+`))
+	})
+
+	It("writes histograms with cumulative buckets", func() {
+		histogram := registry.NewHistogramVec("some_seconds", "Some durations.", []float64{1, 0.5}, "op")
+		histogram.Observe(0.25, "x")
+		histogram.Observe(0.75, "x")
+		histogram.Observe(2, "x")
+
+		Expect(output()).To(Equal(`# HELP some_seconds Some durations.
+# TYPE some_seconds histogram
+some_seconds_bucket{op="x",le="0.5"} 1
+some_seconds_bucket{op="x",le="1"} 2
+some_seconds_bucket{op="x",le="+Inf"} 3
+some_seconds_sum{op="x"} 3
+some_seconds_count{op="x"} 3
+`))
+	})
+
+	It("escapes label values", func() {
+		registry.NewCounterVec("some_total", "Some things.", "path").Inc("a\"b\\c\nd")
+		Expect(output()).To(ContainSubstring(`some_total{path="a\"b\\c\nd"} 1`))
+	})
+
+	It("refuses the wrong number of label values", func() {
+		counter := registry.NewCounterVec("some_total", "Some things.", "kind")
+		Expect(func() { counter.Inc() }).To(Panic())
+	})
+
+	It("serves the metrics over HTTP", func() {
+		registry.NewCounterVec("some_total", "Some things.").Inc()
+
+		recorder := httptest.NewRecorder()
+		registry.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(recorder.Header().Get("Content-Type")).To(HavePrefix("text/plain; version=0.0.4"))
+		Expect(recorder.Body.String()).To(ContainSubstring("some_total 1\n"))
+	})
+})
@@ -0,0 +1,120 @@
+package efsmetrics
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// Emitter receives every observation Metrics makes, for sending on to a
+// system that is not scraped.
+type Emitter interface {
+	Count(name string, tags map[string]string)
+	Timing(name string, duration time.Duration, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+}
+
+// StatsdEmitter sends observations as StatsD datagrams over UDP, one datagram
+// each, to an agent such as the statsd injector on the local cell. Tags are
+// sent in the DogStatsD "|#name:value" form, the emitter's own tags first.
+// Datagrams that cannot be sent are dropped.
+type StatsdEmitter struct {
+	logger lager.Logger
+	conn   net.Conn
+	prefix string
+	tags   string
+}
+
+func NewStatsdEmitter(logger lager.Logger, address, prefix string, tags map[string]string) (*StatsdEmitter, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+
+	return &StatsdEmitter{
+		logger: logger.Session("statsd-emitter", lager.Data{"address": address}),
+		conn:   conn,
+		prefix: prefix,
+		tags:   formatTags(tags),
+	}, nil
+}
+
+func (e *StatsdEmitter) Count(name string, tags map[string]string) {
+	e.send(name, "1", "c", tags)
+}
+
+func (e *StatsdEmitter) Timing(name string, duration time.Duration, tags map[string]string) {
+	e.send(name, strconv.FormatFloat(duration.Seconds()*1000, 'f', -1, 64), "ms", tags)
+}
+
+func (e *StatsdEmitter) Gauge(name string, value float64, tags map[string]string) {
+	if value < 0 {
+		// a leading sign makes StatsD apply the value as a delta
+		value = 0
+	}
+	e.send(name, strconv.FormatFloat(value, 'f', -1, 64), "g", tags)
+}
+
+func (e *StatsdEmitter) Close() error {
+	return e.conn.Close()
+}
+
+func (e *StatsdEmitter) send(name, value, kind string, tags map[string]string) {
+	datagram := e.prefix + sanitize(name) + ":" + value + "|" + kind
+	allTags := e.tags
+	if extra := formatTags(tags); extra != "" {
+		if allTags != "" {
+			allTags += ","
+		}
+		allTags += extra
+	}
+	if allTags != "" {
+		datagram += "|#" + allTags
+	}
+
+	if _, err := e.conn.Write([]byte(datagram)); err != nil {
+		e.logger.Debug("send-failed", lager.Data{"err": err.Error()})
+	}
+}
+
+// ParseTags reads tags given as a comma separated list of name:value pairs.
+func ParseTags(value string) (map[string]string, error) {
+	tags := map[string]string{}
+	if value == "" {
+		return tags, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid tag '%s': expected name:value", pair)
+		}
+		tags[parts[0]] = parts[1]
+	}
+	return tags, nil
+}
+
+func formatTags(tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = sanitize(name) + ":" + sanitize(tags[name])
+	}
+	return strings.Join(pairs, ",")
+}
+
+// sanitize replaces the characters that delimit the parts of a datagram.
+var sanitize = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", ",", "_", "\n", "_").Replace
@@ -0,0 +1,89 @@
+package efsmetrics_test
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsmetrics"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StatsdEmitter", func() {
+	var (
+		listener net.PacketConn
+		emitter  *efsmetrics.StatsdEmitter
+	)
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.ListenPacket("udp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		emitter, err = efsmetrics.NewStatsdEmitter(lagertest.NewTestLogger("statsd"), listener.LocalAddr().String(), "efsdriver", map[string]string{"deployment": "cf", "az": "z1"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		emitter.Close()
+		listener.Close()
+	})
+
+	receive := func() string {
+		Expect(listener.SetReadDeadline(time.Now().Add(time.Second))).To(Succeed())
+		buffer := make([]byte, 1024)
+		n, _, err := listener.ReadFrom(buffer)
+		Expect(err).NotTo(HaveOccurred())
+		return string(buffer[:n])
+	}
+
+	It("sends counts, timings and gauges with the prefix and tags", func() {
+		emitter.Count("mount.operations", map[string]string{"result": "success"})
+		Expect(receive()).To(Equal("efsdriver.mount.operations:1|c|#az:z1,deployment:cf,result:success"))
+
+		emitter.Timing("mount.duration", 1500*time.Microsecond, nil)
+		Expect(receive()).To(Equal("efsdriver.mount.duration:1.5|ms|#az:z1,deployment:cf"))
+
+		emitter.Gauge("active_mounts", 3, nil)
+		Expect(receive()).To(Equal("efsdriver.active_mounts:3|g|#az:z1,deployment:cf"))
+	})
+
+	It("keeps delimiters out of names and tags", func() {
+		emitter.Count("some|name", map[string]string{"path": "a:b,c#d"})
+		Expect(receive()).To(Equal("efsdriver.some_name:1|c|#az:z1,deployment:cf,path:a_b_c_d"))
+	})
+
+	It("receives the observations of Metrics", func() {
+		fakeClock := fakeclock.NewFakeClock(time.Now())
+		metrics := efsmetrics.New(fakeClock, emitter)
+		fakeVolTools := &efsdriverfakes.FakeVolTools{}
+		fakeVolTools.OpenPermsReturns(efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeChmodFailed, "badness"))
+
+		env := driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("statsd"), context.TODO())
+		metrics.VolTools(fakeVolTools).OpenPerms(env, efsvoltools.OpenPermsRequest{})
+
+		Expect(receive()).To(Equal("efsdriver.operations_in_flight:1|g|#az:z1,deployment:cf,operation:openPerms"))
+		Expect(receive()).To(Equal("efsdriver.operations_in_flight:0|g|#az:z1,deployment:cf,operation:openPerms"))
+		Expect(receive()).To(Equal("efsdriver.voltools.operations:1|c|#az:z1,deployment:cf,error_class:chmod_failed,operation:openPerms,result:failure"))
+		Expect(receive()).To(Equal("efsdriver.voltools.duration:0|ms|#az:z1,deployment:cf,operation:openPerms,result:failure"))
+	})
+
+	Context("#ParseTags", func() {
+		It("reads name:value pairs", func() {
+			tags, err := efsmetrics.ParseTags("deployment:cf,job:cell")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tags).To(Equal(map[string]string{"deployment": "cf", "job": "cell"}))
+		})
+
+		It("refuses malformed pairs", func() {
+			_, err := efsmetrics.ParseTags("deployment")
+			Expect(err).To(MatchError(ContainSubstring("expected name:value")))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package efsmetrics
+
+import (
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+type instrumentedVolTools struct {
+	tools   efsvoltools.VolTools
+	metrics *Metrics
+}
+
+// VolTools counts and times every operation of tools, labelled with its route
+// name. Capabilities is not an operation and is passed straight through.
+func (m *Metrics) VolTools(tools efsvoltools.VolTools) efsvoltools.VolTools {
+	return &instrumentedVolTools{tools: tools, metrics: m}
+}
+
+func (i *instrumentedVolTools) observe(env dockerdriver.Env, operation string, done func() time.Duration, err string, code efsvoltools.ErrorCode) {
+	i.metrics.observeVolTools(operation, done(), responseErrorClass(env, err, code))
+}
+
+func (i *instrumentedVolTools) OpenPerms(env dockerdriver.Env, request efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
+	done := i.metrics.start(efsvoltools.OpenPermsRoute)
+	response := i.tools.OpenPerms(env, request)
+	i.observe(env, efsvoltools.OpenPermsRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) BatchOpenPerms(env dockerdriver.Env, request efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse {
+	done := i.metrics.start(efsvoltools.BatchOpenPermsRoute)
+	response := i.tools.BatchOpenPerms(env, request)
+	i.observe(env, efsvoltools.BatchOpenPermsRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) RepairPerms(env dockerdriver.Env, request efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+	done := i.metrics.start(efsvoltools.RepairPermsRoute)
+	response := i.tools.RepairPerms(env, request)
+	i.observe(env, efsvoltools.RepairPermsRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) Export(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+	done := i.metrics.start(efsvoltools.ExportRoute)
+	response := i.tools.Export(env, request, w)
+	i.observe(env, efsvoltools.ExportRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) Import(env dockerdriver.Env, request efsvoltools.ImportRequest, r io.Reader) efsvoltools.ErrorResponse {
+	done := i.metrics.start(efsvoltools.ImportRoute)
+	response := i.tools.Import(env, request, r)
+	i.observe(env, efsvoltools.ImportRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) CloneDirectory(env dockerdriver.Env, request efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse {
+	done := i.metrics.start(efsvoltools.CloneRoute)
+	response := i.tools.CloneDirectory(env, request)
+	i.observe(env, efsvoltools.CloneRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) ListDirectories(env dockerdriver.Env, request efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse {
+	done := i.metrics.start(efsvoltools.ListRoute)
+	response := i.tools.ListDirectories(env, request)
+	i.observe(env, efsvoltools.ListRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) GetACL(env dockerdriver.Env, request efsvoltools.GetACLRequest) efsvoltools.GetACLResponse {
+	done := i.metrics.start(efsvoltools.GetACLRoute)
+	response := i.tools.GetACL(env, request)
+	i.observe(env, efsvoltools.GetACLRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) SetACL(env dockerdriver.Env, request efsvoltools.SetACLRequest) efsvoltools.ErrorResponse {
+	done := i.metrics.start(efsvoltools.SetACLRoute)
+	response := i.tools.SetACL(env, request)
+	i.observe(env, efsvoltools.SetACLRoute, done, response.Err, response.Code)
+	return response
+}
+
+func (i *instrumentedVolTools) Capabilities(env dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+	return i.tools.Capabilities(env)
+}
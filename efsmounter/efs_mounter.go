@@ -3,11 +3,15 @@ package efsmounter
 import (
 	"context"
 	"fmt"
+	"net"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
 	"code.cloudfoundry.org/dockerdriver/invoker"
+	"code.cloudfoundry.org/efsdriver/efstracing"
+	"code.cloudfoundry.org/lager"
 )
 
 //go:generate counterfeiter -o ../efsdriverfakes/fake_mounter.go . Mounter
@@ -23,13 +27,28 @@ type efsMounter struct {
 	fstype      string
 	defaultOpts string
 	awsAZ       string
+	records     *Records
+	journal     *Journal
 }
 
 func NewEfsMounter(invoker invoker.Invoker, fstype, defaultOpts string, awsAZ string) Mounter {
-	return &efsMounter{invoker, fstype, defaultOpts, awsAZ}
+	return NewEfsMounterWithRecords(invoker, fstype, defaultOpts, awsAZ, NewRecords(), nil)
 }
 
-func (m *efsMounter) Mount(env dockerdriver.Env, source string, target string, opts map[string]interface{}) error {
+// NewEfsMounterWithRecords keeps a record of every file system the mounter
+// mounts in records, until it is unmounted again. Every mount and unmount is
+// also written to journal, unless it is nil.
+func NewEfsMounterWithRecords(invoker invoker.Invoker, fstype, defaultOpts string, awsAZ string, records *Records, journal *Journal) Mounter {
+	return &efsMounter{invoker, fstype, defaultOpts, awsAZ, records, journal}
+}
+
+func (m *efsMounter) Mount(env dockerdriver.Env, source string, target string, opts map[string]interface{}) (err error) {
+	ctx, span := efstracing.Start(env.Context(), "efsmounter.mount", efstracing.String("efs.target", target))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	azMap, mapOk := opts["az-map"].(map[string]interface{})
 	if mapOk {
 		if mapSource, ok := azMap[m.awsAZ].(string); ok {
@@ -37,13 +56,80 @@ func (m *efsMounter) Mount(env dockerdriver.Env, source string, target string, o
 		}
 	}
 
-	_, err := m.invoker.Invoke(env, "mount", []string{"-t", m.fstype, "-o", m.defaultOpts, source, target})
-	return err
+	source, err = ParseSource(source)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(efstracing.String("efs.source", source))
+
+	if span != nil {
+		traceLookup(ctx, source)
+	}
+
+	id, err := m.journal.Begin(OpMount, target, source, m.defaultOpts)
+	if err != nil {
+		return fmt.Errorf("failed journaling mount: %s", err.Error())
+	}
+
+	_, err = m.invoker.Invoke(env, "mount", []string{"-t", m.fstype, "-o", m.defaultOpts, source, target})
+	m.endJournaled(env, id, OpMount, target, err)
+	if err != nil {
+		return err
+	}
+
+	m.records.Put(MountRecord{Target: target, Source: source, Options: m.defaultOpts})
+	return nil
+}
+
+// traceLookup resolves the host name in source, if it has one, in a span of
+// its own. mount.nfs resolves the name again, normally from the resolver's
+// cache, so that the span shows how much of mounting went on DNS.
+func traceLookup(ctx context.Context, source string) {
+	host := strings.Trim(source[:strings.Index(source, ":/")], "[]")
+	if net.ParseIP(host) != nil {
+		return
+	}
+
+	ctx, span := efstracing.Start(ctx, "efsmounter.dns", efstracing.String("net.host.name", host))
+	defer span.End()
+
+	addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+	span.RecordError(err)
+	span.SetAttributes(efstracing.Int("net.host.addresses", int64(len(addresses))))
+}
+
+func (m *efsMounter) Unmount(env dockerdriver.Env, target string) (err error) {
+	_, span := efstracing.Start(env.Context(), "efsmounter.unmount", efstracing.String("efs.target", target))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	// what was mounted is journaled with the unmount, for whoever has to
+	// finish it after a crash
+	record, _ := m.records.Get(target)
+	id, err := m.journal.Begin(OpUnmount, target, record.Source, record.Options)
+	if err != nil {
+		return fmt.Errorf("failed journaling unmount: %s", err.Error())
+	}
+
+	_, err = m.invoker.Invoke(env, "umount", []string{target})
+	m.endJournaled(env, id, OpUnmount, target, err)
+	if err != nil {
+		return err
+	}
+
+	m.records.Remove(target)
+	return nil
 }
 
-func (m *efsMounter) Unmount(env dockerdriver.Env, target string) error {
-	_, err := m.invoker.Invoke(env, "umount", []string{target})
-	return err
+// endJournaled records the outcome of an operation. The operation has
+// already happened, so failing to record it is only logged; the operation
+// then looks incomplete to Replay at the next start.
+func (m *efsMounter) endJournaled(env dockerdriver.Env, id, op, target string, opErr error) {
+	if err := m.journal.End(id, op, target, opErr); err != nil {
+		env.Logger().Error("failed-journaling-outcome", err, lager.Data{"op": op, "target": target})
+	}
 }
 
 func (m *efsMounter) Check(env dockerdriver.Env, name, mountPoint string) bool {
@@ -56,11 +142,24 @@ func (m *efsMounter) Check(env dockerdriver.Env, name, mountPoint string) bool {
 		// Note: Created volumes (with no mounts) will be removed
 		//       since VolumeInfo.Mountpoint will be an empty string
 		env.Logger().Info(fmt.Sprintf("unable to verify volume %s (%s)", name, err.Error()))
+		m.dropRecord(env, mountPoint, ctx.Err())
 		return false
 	}
 	return true
 }
 
+// dropRecord forgets the record of a mount that mountpoint found gone, such
+// as one unmounted behind the driver's back. A check that timed out says
+// nothing either way, so the record is kept.
+func (m *efsMounter) dropRecord(env dockerdriver.Env, target string, ctxErr error) {
+	record, ok := m.records.Get(target)
+	if !ok || ctxErr != nil {
+		return
+	}
+	env.Logger().Info("dropped-stale-mount-record", lager.Data{"target": target, "source": record.Source})
+	m.records.Remove(target)
+}
+
 func (m *efsMounter) Purge(env dockerdriver.Env, path string) {
 	return
 }
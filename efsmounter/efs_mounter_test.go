@@ -3,11 +3,17 @@ package efsmounter_test
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/dockerdriverfakes"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
 	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/volumedriver"
@@ -45,7 +51,7 @@ var _ = Describe("EfsMounter", func() {
 		Context("when mount succeeds", func() {
 			JustBeforeEach(func() {
 				fakeInvoker.InvokeReturns(nil, nil)
-				err = subject.Mount(env, "source", "target", opts)
+				err = subject.Mount(env, "fs-1234.efs.my-region.amazonaws.com:/", "target", opts)
 			})
 
 			It("should return without error", func() {
@@ -59,27 +65,27 @@ var _ = Describe("EfsMounter", func() {
 				Expect(args[1]).To(Equal("my-fs"))
 				Expect(args[2]).To(Equal("-o"))
 				Expect(args[3]).To(Equal("my-mount-options"))
-				Expect(args[4]).To(Equal("source"))
+				Expect(args[4]).To(Equal("fs-1234.efs.my-region.amazonaws.com:/"))
 				Expect(args[5]).To(Equal("target"))
 			})
 			Context("when there is a matching AZ in the opts", func() {
 				BeforeEach(func() {
-					opts["az-map"] = map[string]interface{}{"my-az": "my-source", "other-az": "other-source"}
+					opts["az-map"] = map[string]interface{}{"my-az": "10.0.0.1:/", "other-az": "10.0.1.1:/"}
 				})
 				It("should use the source for matching AZ", func() {
 					_, cmd, args := fakeInvoker.InvokeArgsForCall(0)
 					Expect(cmd).To(Equal("mount"))
-					Expect(args[4]).To(Equal("my-source"))
+					Expect(args[4]).To(Equal("10.0.0.1:/"))
 				})
 			})
 			Context("when there is no matching AZ in the opts", func() {
 				BeforeEach(func() {
-					opts["az-map"] = map[string]interface{}{"not-my-az": "not-my-source", "other-az": "other-source"}
+					opts["az-map"] = map[string]interface{}{"not-my-az": "10.0.2.1:/", "other-az": "10.0.1.1:/"}
 				})
 				It("should use the regular source", func() {
 					_, cmd, args := fakeInvoker.InvokeArgsForCall(0)
 					Expect(cmd).To(Equal("mount"))
-					Expect(args[4]).To(Equal("source"))
+					Expect(args[4]).To(Equal("fs-1234.efs.my-region.amazonaws.com:/"))
 				})
 			})
 		})
@@ -88,7 +94,7 @@ var _ = Describe("EfsMounter", func() {
 			BeforeEach(func() {
 				fakeInvoker.InvokeReturns([]byte("error"), fmt.Errorf("error"))
 
-				err = subject.Mount(env, "source", "target", opts)
+				err = subject.Mount(env, "fs-1234.efs.my-region.amazonaws.com:/", "target", opts)
 			})
 
 			It("should return without error", func() {
@@ -96,11 +102,185 @@ var _ = Describe("EfsMounter", func() {
 			})
 		})
 
+		Context("when the source is not a valid NFS source", func() {
+			BeforeEach(func() {
+				err = subject.Mount(env, "evil;rm -rf /:/", "target", opts)
+			})
+
+			It("should refuse it without mounting", func() {
+				Expect(err).To(MatchError(ContainSubstring("invalid NFS server address")))
+				Expect(fakeInvoker.InvokeCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the source is an IPv6 address", func() {
+			BeforeEach(func() {
+				err = subject.Mount(env, "[FD00:0::1]:/", "target", opts)
+			})
+
+			It("should mount it in normal form", func() {
+				Expect(err).NotTo(HaveOccurred())
+				_, _, args := fakeInvoker.InvokeArgsForCall(0)
+				Expect(args[4]).To(Equal("[fd00::1]:/"))
+			})
+		})
+
 		Context("when mount is cancelled", func() {
 			// TODO: when we pick up the lager.Context
 		})
 	})
 
+	Context("when keeping records", func() {
+		var records *efsmounter.Records
+
+		BeforeEach(func() {
+			records = efsmounter.NewRecords()
+			subject = efsmounter.NewEfsMounterWithRecords(fakeInvoker, "my-fs", "my-mount-options", "my-az", records, nil)
+		})
+
+		It("should record successful mounts until they are unmounted", func() {
+			Expect(subject.Mount(env, "10.0.0.1:/", "/volumes/a", opts)).To(Succeed())
+			Expect(records.All()).To(Equal([]efsmounter.MountRecord{{Target: "/volumes/a", Source: "10.0.0.1:/", Options: "my-mount-options"}}))
+
+			Expect(subject.Unmount(env, "/volumes/a")).To(Succeed())
+			Expect(records.All()).To(BeEmpty())
+		})
+
+		It("should forget mounts that are found to be gone", func() {
+			Expect(subject.Mount(env, "10.0.0.1:/", "/volumes/a", opts)).To(Succeed())
+			Expect(subject.Check(env, "a", "/volumes/a")).To(BeTrue())
+			Expect(records.All()).To(HaveLen(1))
+
+			fakeInvoker.InvokeReturns(nil, fmt.Errorf("not a mountpoint"))
+			Expect(subject.Check(env, "a", "/volumes/a")).To(BeFalse())
+			Expect(records.All()).To(BeEmpty())
+		})
+
+		It("should not record failed mounts, nor forget mounts that failed to unmount", func() {
+			records.Put(efsmounter.MountRecord{Target: "/volumes/a", Source: "10.0.0.1:/"})
+			fakeInvoker.InvokeReturns(nil, fmt.Errorf("device busy"))
+
+			Expect(subject.Mount(env, "10.0.0.2:/", "/volumes/b", opts)).NotTo(Succeed())
+			Expect(subject.Unmount(env, "/volumes/a")).NotTo(Succeed())
+
+			_, ok := records.Get("/volumes/b")
+			Expect(ok).To(BeFalse())
+			_, ok = records.Get("/volumes/a")
+			Expect(ok).To(BeTrue())
+		})
+	})
+
+	Context("when journaling", func() {
+		var (
+			dir     string
+			journal *efsmounter.Journal
+		)
+
+		BeforeEach(func() {
+			dir, err = ioutil.TempDir("", "efs-mounter-journal")
+			Expect(err).NotTo(HaveOccurred())
+
+			journal, err = efsmounter.OpenJournal(filepath.Join(dir, efsmounter.JournalFile))
+			Expect(err).NotTo(HaveOccurred())
+			subject = efsmounter.NewEfsMounterWithRecords(fakeInvoker, "my-fs", "my-mount-options", "my-az", efsmounter.NewRecords(), journal)
+		})
+
+		AfterEach(func() {
+			journal.Close()
+			os.RemoveAll(dir)
+		})
+
+		It("should journal the intent and the outcome of every operation", func() {
+			Expect(subject.Mount(env, "10.0.0.1:/", "/volumes/a", opts)).To(Succeed())
+			fakeInvoker.InvokeReturns(nil, fmt.Errorf("device busy"))
+			Expect(subject.Unmount(env, "/volumes/a")).NotTo(Succeed())
+
+			entries, skipped, err := journal.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(skipped).To(BeZero())
+			Expect(entries).To(HaveLen(4))
+			Expect(entries[0].Op).To(Equal(efsmounter.OpMount))
+			Expect(entries[0].Phase).To(Equal(efsmounter.PhaseIntent))
+			Expect(entries[0].Source).To(Equal("10.0.0.1:/"))
+			Expect(entries[0].Target).To(Equal("/volumes/a"))
+			Expect(entries[1].ID).To(Equal(entries[0].ID))
+			Expect(entries[1].Phase).To(Equal(efsmounter.PhaseCompleted))
+			Expect(entries[2].Op).To(Equal(efsmounter.OpUnmount))
+			Expect(entries[2].Phase).To(Equal(efsmounter.PhaseIntent))
+			Expect(entries[2].Source).To(Equal("10.0.0.1:/"))
+			Expect(entries[2].Options).To(Equal("my-mount-options"))
+			Expect(entries[3].ID).To(Equal(entries[2].ID))
+			Expect(entries[3].Phase).To(Equal(efsmounter.PhaseFailed))
+			Expect(entries[3].Error).To(Equal("device busy"))
+			Expect(efsmounter.Incomplete(entries)).To(BeEmpty())
+		})
+
+		It("should not mount when the intent cannot be journaled", func() {
+			Expect(journal.Close()).To(Succeed())
+
+			err := subject.Mount(env, "10.0.0.1:/", "/volumes/a", opts)
+			Expect(err).To(MatchError(ContainSubstring("failed journaling mount")))
+			Expect(fakeInvoker.InvokeCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when tracing is enabled", func() {
+		var fakeExporter *efsdriverfakes.FakeExporter
+		var tracer *efstracing.Tracer
+
+		BeforeEach(func() {
+			fakeExporter = &efsdriverfakes.FakeExporter{}
+			tracer = efstracing.NewTracer(clock.NewClock(), fakeExporter)
+			efstracing.SetTracer(tracer)
+		})
+
+		AfterEach(func() {
+			efstracing.SetTracer(nil)
+		})
+
+		exported := func() []efstracing.SpanData {
+			_, _, err := tracer.Flush(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			if fakeExporter.ExportCallCount() == 0 {
+				return nil
+			}
+			_, spans := fakeExporter.ExportArgsForCall(0)
+			return spans
+		}
+
+		It("should trace the name lookup within the mount", func() {
+			Expect(subject.Mount(env, "localhost:/", "target", opts)).To(Succeed())
+
+			spans := exported()
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Name).To(Equal("efsmounter.dns"))
+			Expect(spans[0].Attributes).To(ContainElement(efstracing.String("net.host.name", "localhost")))
+			Expect(spans[1].Name).To(Equal("efsmounter.mount"))
+			Expect(spans[1].Attributes).To(ContainElement(efstracing.String("efs.source", "localhost:/")))
+			Expect(spans[0].Parent).To(Equal(spans[1].SpanContext.SpanID))
+		})
+
+		It("should not look up IP addresses", func() {
+			Expect(subject.Mount(env, "10.0.0.1:/", "target", opts)).To(Succeed())
+
+			spans := exported()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Name).To(Equal("efsmounter.mount"))
+		})
+
+		It("should mark failed mounts and unmounts", func() {
+			fakeInvoker.InvokeReturns(nil, fmt.Errorf("access denied"))
+			Expect(subject.Mount(env, "10.0.0.1:/", "target", opts)).NotTo(Succeed())
+			Expect(subject.Unmount(env, "target")).NotTo(Succeed())
+
+			spans := exported()
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Failed).To(BeTrue())
+			Expect(spans[1].Name).To(Equal("efsmounter.unmount"))
+			Expect(spans[1].StatusMessage).To(Equal("access denied"))
+		})
+	})
+
 	Context("#Unmount", func() {
 		Context("when mount succeeds", func() {
 
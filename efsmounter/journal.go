@@ -0,0 +1,284 @@
+package efsmounter
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalFile is the name of the mount journal in the driver's state
+// directory.
+const JournalFile = "mount-journal.jsonl"
+
+const (
+	OpMount   = "mount"
+	OpUnmount = "unmount"
+
+	PhaseIntent    = "intent"
+	PhaseCompleted = "completed"
+	PhaseFailed    = "failed"
+)
+
+// JournalEntry is one line of the journal. Every operation has an intent
+// entry written before it starts, and a completed or failed entry with the
+// same ID once it is over.
+type JournalEntry struct {
+	ID      string    `json:"id"`
+	Op      string    `json:"op"`
+	Phase   string    `json:"phase"`
+	Target  string    `json:"target"`
+	Source  string    `json:"source,omitempty"`
+	Options string    `json:"options,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// DefaultMaxJournalSize is how large the journal may grow before it is
+// compacted down to the operations still in progress.
+const DefaultMaxJournalSize = 1 << 20
+
+// Journal is an append-only log of mount operations, so that operations cut
+// short by a crash can be found again at startup. Every entry is synced to
+// disk before the write returns. The methods of a nil Journal do nothing.
+type Journal struct {
+	path    string
+	maxSize int64
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+	open []JournalEntry
+}
+
+// OpenJournal opens the journal at path for appending, creating it if need
+// be.
+func OpenJournal(path string) (*Journal, error) {
+	return OpenJournalWithMaxSize(path, DefaultMaxJournalSize)
+}
+
+// OpenJournalWithMaxSize opens the journal like OpenJournal, compacting it
+// whenever it grows past maxSize bytes.
+func OpenJournalWithMaxSize(path string, maxSize int64) (*Journal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// make sure the journal itself survives a crash, not just what is in it
+	if err := syncDir(filepath.Dir(path)); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	journal := &Journal{path: path, maxSize: maxSize, file: file}
+	entries, _, err := journal.Entries()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	journal.size = info.Size()
+	journal.open = Incomplete(entries)
+	return journal, nil
+}
+
+// Begin records the intent to carry out op on target, and returns the ID to
+// pass to End. An operation whose intent could not be recorded must not be
+// carried out.
+func (j *Journal) Begin(op, target, source, options string) (string, error) {
+	if j == nil {
+		return "", nil
+	}
+	id, err := newOperationID()
+	if err != nil {
+		return "", err
+	}
+	return id, j.append(JournalEntry{ID: id, Op: op, Phase: PhaseIntent, Target: target, Source: source, Options: options})
+}
+
+// End records that the operation Begin returned id for completed, or failed
+// with opErr.
+func (j *Journal) End(id, op, target string, opErr error) error {
+	if j == nil {
+		return nil
+	}
+	entry := JournalEntry{ID: id, Op: op, Phase: PhaseCompleted, Target: target}
+	if opErr != nil {
+		entry.Phase = PhaseFailed
+		entry.Error = opErr.Error()
+	}
+	return j.append(entry)
+}
+
+func (j *Journal) append(entry JournalEntry) error {
+	entry.Time = time.Now().UTC()
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	n, err := j.file.Write(append(line, '\n'))
+	j.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if err := j.file.Sync(); err != nil {
+		return err
+	}
+
+	if entry.Phase == PhaseIntent {
+		j.open = append(j.open, entry)
+	} else {
+		for i, intent := range j.open {
+			if intent.ID == entry.ID {
+				j.open = append(j.open[:i], j.open[i+1:]...)
+				break
+			}
+		}
+	}
+
+	if j.maxSize > 0 && j.size > j.maxSize {
+		// the entry is safely written either way; a journal that could not
+		// be compacted is tried again on the next write
+		j.compact()
+	}
+	return nil
+}
+
+// compact rewrites the journal with only the intents of the operations still
+// in progress. The new journal is synced before it is renamed over the old
+// one, so that a crash part way leaves one or the other whole.
+func (j *Journal) compact() error {
+	compacted := j.path + ".compacting"
+	file, err := os.OpenFile(compacted, os.O_WRONLY|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, intent := range j.open {
+		line, err := json.Marshal(intent)
+		if err == nil {
+			var n int
+			n, err = file.Write(append(line, '\n'))
+			size += int64(n)
+		}
+		if err != nil {
+			file.Close()
+			os.Remove(compacted)
+			return err
+		}
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(compacted)
+		return err
+	}
+	if err := os.Rename(compacted, j.path); err != nil {
+		file.Close()
+		os.Remove(compacted)
+		return err
+	}
+
+	j.file.Close()
+	j.file = file
+	j.size = size
+	return syncDir(filepath.Dir(j.path))
+}
+
+// Entries reads the journal back. Lines that cannot be read, such as one
+// torn by a crash part way through writing it, are skipped and counted.
+func (j *Journal) Entries() ([]JournalEntry, int, error) {
+	file, err := os.Open(j.path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+	return ReadJournal(file)
+}
+
+// ReadJournal reads journal entries from r, skipping and counting the lines
+// that are not entries.
+func ReadJournal(r io.Reader) ([]JournalEntry, int, error) {
+	var entries []JournalEntry
+	skipped := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.ID == "" {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, skipped, scanner.Err()
+}
+
+// Truncate empties the journal, once every operation in it is over.
+func (j *Journal) Truncate() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if err := j.file.Truncate(0); err != nil {
+		return err
+	}
+	j.size = 0
+	j.open = nil
+	return j.file.Sync()
+}
+
+func (j *Journal) Close() error {
+	if j == nil {
+		return nil
+	}
+	return j.file.Close()
+}
+
+// Incomplete returns the intents in entries that have no completed or failed
+// entry, in the order they were written.
+func Incomplete(entries []JournalEntry) []JournalEntry {
+	over := map[string]bool{}
+	for _, entry := range entries {
+		if entry.Phase != PhaseIntent {
+			over[entry.ID] = true
+		}
+	}
+
+	var incomplete []JournalEntry
+	for _, entry := range entries {
+		if entry.Phase == PhaseIntent && !over[entry.ID] {
+			incomplete = append(incomplete, entry)
+		}
+	}
+	return incomplete
+}
+
+func newOperationID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,197 @@
+package efsmounter_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		dir     string
+		path    string
+		journal *efsmounter.Journal
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "journal")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, efsmounter.JournalFile)
+		journal, err = efsmounter.OpenJournal(path)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		journal.Close()
+		os.RemoveAll(dir)
+	})
+
+	It("reads back what was written, ending each intent by its ID", func() {
+		mountID, err := journal.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "vers=4.1")
+		Expect(err).NotTo(HaveOccurred())
+		unmountID, err := journal.Begin(efsmounter.OpUnmount, "/volumes/b", "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journal.End(mountID, efsmounter.OpMount, "/volumes/a", fmt.Errorf("timed out"))).To(Succeed())
+
+		entries, skipped, err := journal.Entries()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(BeZero())
+		Expect(entries).To(HaveLen(3))
+		Expect(entries[0].Options).To(Equal("vers=4.1"))
+		Expect(entries[2].Phase).To(Equal(efsmounter.PhaseFailed))
+		Expect(entries[2].Error).To(Equal("timed out"))
+
+		incomplete := efsmounter.Incomplete(entries)
+		Expect(incomplete).To(HaveLen(1))
+		Expect(incomplete[0].ID).To(Equal(unmountID))
+		Expect(incomplete[0].Target).To(Equal("/volumes/b"))
+	})
+
+	It("keeps appending to an existing journal", func() {
+		_, err := journal.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journal.Close()).To(Succeed())
+
+		journal, err = efsmounter.OpenJournal(path)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = journal.Begin(efsmounter.OpUnmount, "/volumes/a", "", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		entries, _, err := journal.Entries()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+	})
+
+	It("compacts down to the operations in progress once it outgrows its limit", func() {
+		leftOpen, err := journal.Begin(efsmounter.OpUnmount, "/volumes/a", "", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(journal.Close()).To(Succeed())
+
+		By("keeping intents written before it was reopened")
+		journal, err = efsmounter.OpenJournalWithMaxSize(path, 1024)
+		Expect(err).NotTo(HaveOccurred())
+		for i := 0; i < 20; i++ {
+			id, err := journal.Begin(efsmounter.OpMount, "/volumes/b", "10.0.0.1:/", "")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(journal.End(id, efsmounter.OpMount, "/volumes/b", nil)).To(Succeed())
+		}
+		inProgress, err := journal.Begin(efsmounter.OpMount, "/volumes/c", "10.0.0.1:/", "")
+		Expect(err).NotTo(HaveOccurred())
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Size()).To(BeNumerically("<=", 1024))
+
+		entries, skipped, err := journal.Entries()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(BeZero())
+		incomplete := efsmounter.Incomplete(entries)
+		Expect(incomplete).To(HaveLen(2))
+		Expect(incomplete[0].ID).To(Equal(leftOpen))
+		Expect(incomplete[1].ID).To(Equal(inProgress))
+	})
+
+	It("skips a line torn by a crash", func() {
+		entries, skipped, err := efsmounter.ReadJournal(strings.NewReader(
+			`{"id":"1","op":"mount","phase":"intent","target":"/volumes/a"}` + "\n" +
+				`{"id":"1","op":"mount","pha`,
+		))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(skipped).To(Equal(1))
+		Expect(entries).To(HaveLen(1))
+		Expect(entries[0].Target).To(Equal("/volumes/a"))
+	})
+
+	It("does nothing when nil", func() {
+		var none *efsmounter.Journal
+		id, err := none.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(none.End(id, efsmounter.OpMount, "/volumes/a", nil)).To(Succeed())
+		Expect(none.Close()).To(Succeed())
+	})
+
+	Context("#Replay", func() {
+		var (
+			env         dockerdriver.Env
+			fakeMounter *efsdriverfakes.FakeMounter
+			mounted     map[string]bool
+		)
+
+		BeforeEach(func() {
+			env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("replay"), context.TODO())
+			fakeMounter = &efsdriverfakes.FakeMounter{}
+			mounted = map[string]bool{}
+		})
+
+		replay := func() ([]efsmounter.ReplayOutcome, error) {
+			outcomes, skipped, err := efsmounter.Replay(env, journal, fakeMounter, func(target string) bool { return mounted[target] })
+			Expect(skipped).To(BeZero())
+			return outcomes, err
+		}
+
+		It("rolls back incomplete mounts and finishes incomplete unmounts", func() {
+			_, err := journal.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = journal.Begin(efsmounter.OpUnmount, "/volumes/b", "", "")
+			Expect(err).NotTo(HaveOccurred())
+			mounted["/volumes/a"] = true
+
+			outcomes, err := replay()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outcomes).To(HaveLen(2))
+			Expect(outcomes[0].Action).To(Equal(efsmounter.ReplayRolledBack))
+			Expect(outcomes[0].Err).NotTo(HaveOccurred())
+			Expect(outcomes[1].Action).To(Equal(efsmounter.ReplayFinished))
+			Expect(outcomes[1].Err).NotTo(HaveOccurred())
+
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+			_, target := fakeMounter.UnmountArgsForCall(0)
+			Expect(target).To(Equal("/volumes/a"))
+
+			entries, _, err := journal.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+
+		It("unmounts a target only once", func() {
+			_, err := journal.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "")
+			Expect(err).NotTo(HaveOccurred())
+			_, err = journal.Begin(efsmounter.OpUnmount, "/volumes/a", "", "")
+			Expect(err).NotTo(HaveOccurred())
+			mounted["/volumes/a"] = true
+
+			_, err = replay()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+
+		It("keeps operations whose target fails to unmount for the next start", func() {
+			_, err := journal.Begin(efsmounter.OpMount, "/volumes/a", "10.0.0.1:/", "")
+			Expect(err).NotTo(HaveOccurred())
+			mounted["/volumes/a"] = true
+			fakeMounter.UnmountReturns(fmt.Errorf("device busy"))
+
+			outcomes, err := replay()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outcomes).To(HaveLen(1))
+			Expect(outcomes[0].Err).To(MatchError("device busy"))
+
+			entries, _, err := journal.Entries()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(efsmounter.Incomplete(entries)).To(HaveLen(1))
+		})
+	})
+})
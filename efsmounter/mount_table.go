@@ -0,0 +1,61 @@
+package efsmounter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MountTablePath lists the mounts the kernel has, in fstab format.
+const MountTablePath = "/proc/mounts"
+
+// MountEntry is one line of the mount table.
+type MountEntry struct {
+	Source  string
+	Target  string
+	FSType  string
+	Options string
+}
+
+// ParseMountTable reads a mount table in the format of /proc/mounts, whose
+// fields escape spaces, tabs, newlines and backslashes as octal.
+func ParseMountTable(r io.Reader) ([]MountEntry, error) {
+	var entries []MountEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("invalid mount table line %d: expected at least 4 fields, got %d", line, len(fields))
+		}
+		entries = append(entries, MountEntry{
+			Source:  unescapeMountField(fields[0]),
+			Target:  unescapeMountField(fields[1]),
+			FSType:  fields[2],
+			Options: fields[3],
+		})
+	}
+	return entries, scanner.Err()
+}
+
+func unescapeMountField(field string) string {
+	if !strings.Contains(field, `\`) {
+		return field
+	}
+	var unescaped strings.Builder
+	for i := 0; i < len(field); i++ {
+		if field[i] == '\\' && i+4 <= len(field) {
+			if b, err := strconv.ParseUint(field[i+1:i+4], 8, 8); err == nil {
+				unescaped.WriteByte(byte(b))
+				i += 3
+				continue
+			}
+		}
+		unescaped.WriteByte(field[i])
+	}
+	return unescaped.String()
+}
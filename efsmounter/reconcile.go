@@ -0,0 +1,87 @@
+package efsmounter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Inconsistency is a mount under the driver's mount directory that cannot be
+// adopted as one of its volumes.
+type Inconsistency struct {
+	Target string
+	Reason string
+}
+
+// ReconcileReport says what Reconcile made of the mount directory: the mounts
+// it adopted, the mountpoints left with nothing mounted on them, and the
+// mounts it would not adopt.
+type ReconcileReport struct {
+	Adopted      []MountRecord
+	Orphaned     []string
+	Inconsistent []Inconsistency
+}
+
+// Reconcile rebuilds records for the NFS file systems that the mount table
+// entries show mounted directly under mountDir, as they are left when the
+// driver restarts while applications keep running. mountpoints are the names
+// of the directories in mountDir. Nothing is mounted or unmounted.
+func Reconcile(entries []MountEntry, mountDir string, mountpoints []string, records *Records) ReconcileReport {
+	mountDir = filepath.Clean(mountDir)
+
+	var targets []string
+	byTarget := map[string][]MountEntry{}
+	for _, entry := range entries {
+		target := filepath.Clean(entry.Target)
+		if target == mountDir || !isWithin(target, mountDir) {
+			continue
+		}
+		if _, seen := byTarget[target]; !seen {
+			targets = append(targets, target)
+		}
+		byTarget[target] = append(byTarget[target], entry)
+	}
+	sort.Strings(targets)
+
+	var report ReconcileReport
+	for _, target := range targets {
+		mounted := byTarget[target]
+		entry := mounted[len(mounted)-1]
+
+		var reason string
+		source, err := ParseSource(entry.Source)
+		switch {
+		case filepath.Dir(target) != mountDir:
+			reason = "not directly under the mount directory"
+		case len(mounted) > 1:
+			reason = fmt.Sprintf("mounted %d times", len(mounted))
+		case !strings.HasPrefix(entry.FSType, "nfs"):
+			reason = fmt.Sprintf("unexpected file system type '%s'", entry.FSType)
+		case err != nil:
+			reason = err.Error()
+		}
+		if reason != "" {
+			report.Inconsistent = append(report.Inconsistent, Inconsistency{Target: target, Reason: reason})
+			continue
+		}
+
+		record := MountRecord{Target: target, Source: source, Options: entry.Options}
+		records.Put(record)
+		report.Adopted = append(report.Adopted, record)
+	}
+
+	for _, name := range mountpoints {
+		target := filepath.Join(mountDir, name)
+		if _, mounted := byTarget[target]; !mounted {
+			report.Orphaned = append(report.Orphaned, target)
+		}
+	}
+	sort.Strings(report.Orphaned)
+
+	return report
+}
+
+func isWithin(path, dir string) bool {
+	return strings.HasPrefix(path, dir+string(filepath.Separator)) || dir == string(filepath.Separator)
+}
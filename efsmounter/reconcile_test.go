@@ -0,0 +1,81 @@
+package efsmounter_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reconcile", func() {
+	var records *efsmounter.Records
+
+	BeforeEach(func() {
+		records = efsmounter.NewRecords()
+	})
+
+	Context("#ParseMountTable", func() {
+		It("reads the fields of every line and unescapes them", func() {
+			entries, err := efsmounter.ParseMountTable(strings.NewReader(
+				"proc /proc proc rw,nosuid 0 0\n" +
+					"\n" +
+					`fs-1234.efs.my-region.amazonaws.com:/ /var/vcap/data/volumes/my\040volume nfs4 rw,vers=4.1 0 0` + "\n",
+			))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]efsmounter.MountEntry{
+				{Source: "proc", Target: "/proc", FSType: "proc", Options: "rw,nosuid"},
+				{Source: "fs-1234.efs.my-region.amazonaws.com:/", Target: "/var/vcap/data/volumes/my volume", FSType: "nfs4", Options: "rw,vers=4.1"},
+			}))
+		})
+
+		It("refuses lines with too few fields", func() {
+			_, err := efsmounter.ParseMountTable(strings.NewReader("proc /proc proc rw 0 0\nnonsense here\n"))
+			Expect(err).To(MatchError(ContainSubstring("line 2")))
+		})
+	})
+
+	It("adopts nfs mounts directly under the mount directory", func() {
+		report := efsmounter.Reconcile([]efsmounter.MountEntry{
+			{Source: "proc", Target: "/proc", FSType: "proc", Options: "rw"},
+			{Source: "10.0.0.1:/", Target: "/volumes/b", FSType: "nfs4", Options: "rw,vers=4.1"},
+			{Source: "127.0.0.1:/", Target: "/volumes/a/", FSType: "nfs4", Options: "rw,port=20049"},
+			{Source: "tmpfs", Target: "/volumes", FSType: "tmpfs", Options: "rw"},
+		}, "/volumes", []string{"a", "b"}, records)
+
+		Expect(report.Adopted).To(Equal([]efsmounter.MountRecord{
+			{Target: "/volumes/a", Source: "127.0.0.1:/", Options: "rw,port=20049"},
+			{Target: "/volumes/b", Source: "10.0.0.1:/", Options: "rw,vers=4.1"},
+		}))
+		Expect(report.Orphaned).To(BeEmpty())
+		Expect(report.Inconsistent).To(BeEmpty())
+		Expect(records.All()).To(Equal(report.Adopted))
+	})
+
+	It("reports mountpoints with nothing mounted as orphaned", func() {
+		report := efsmounter.Reconcile([]efsmounter.MountEntry{
+			{Source: "10.0.0.1:/", Target: "/volumes/b", FSType: "nfs4", Options: "rw"},
+		}, "/volumes", []string{"c", "b", "a"}, records)
+
+		Expect(report.Orphaned).To(Equal([]string{"/volumes/a", "/volumes/c"}))
+	})
+
+	It("reports mounts it cannot adopt as inconsistent", func() {
+		report := efsmounter.Reconcile([]efsmounter.MountEntry{
+			{Source: "10.0.0.1:/", Target: "/volumes/a/nested", FSType: "nfs4", Options: "rw"},
+			{Source: "10.0.0.1:/", Target: "/volumes/b", FSType: "nfs4", Options: "rw"},
+			{Source: "10.0.0.2:/", Target: "/volumes/b", FSType: "nfs4", Options: "rw"},
+			{Source: "/dev/sdb1", Target: "/volumes/c", FSType: "ext4", Options: "rw"},
+			{Source: "10.0.0.1;reboot:/", Target: "/volumes/d", FSType: "nfs", Options: "rw"},
+		}, "/volumes", nil, records)
+
+		Expect(report.Adopted).To(BeEmpty())
+		Expect(records.All()).To(BeEmpty())
+		Expect(report.Inconsistent).To(HaveLen(4))
+		Expect(report.Inconsistent[0]).To(Equal(efsmounter.Inconsistency{Target: "/volumes/a/nested", Reason: "not directly under the mount directory"}))
+		Expect(report.Inconsistent[1]).To(Equal(efsmounter.Inconsistency{Target: "/volumes/b", Reason: "mounted 2 times"}))
+		Expect(report.Inconsistent[2]).To(Equal(efsmounter.Inconsistency{Target: "/volumes/c", Reason: "unexpected file system type 'ext4'"}))
+		Expect(report.Inconsistent[3].Target).To(Equal("/volumes/d"))
+		Expect(report.Inconsistent[3].Reason).To(ContainSubstring("invalid NFS server address"))
+	})
+})
@@ -0,0 +1,55 @@
+package efsmounter
+
+import (
+	"sort"
+	"sync"
+)
+
+// MountRecord is what the mounter knows of a file system it has mounted, or
+// adopted from the mount table at startup.
+type MountRecord struct {
+	Target  string
+	Source  string
+	Options string
+}
+
+// Records holds the mounter's MountRecords, keyed by target.
+type Records struct {
+	lock     sync.Mutex
+	byTarget map[string]MountRecord
+}
+
+func NewRecords() *Records {
+	return &Records{byTarget: map[string]MountRecord{}}
+}
+
+func (r *Records) Put(record MountRecord) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.byTarget[record.Target] = record
+}
+
+func (r *Records) Remove(target string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.byTarget, target)
+}
+
+func (r *Records) Get(target string) (MountRecord, bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	record, ok := r.byTarget[target]
+	return record, ok
+}
+
+// All returns every record, ordered by target.
+func (r *Records) All() []MountRecord {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	records := make([]MountRecord, 0, len(r.byTarget))
+	for _, record := range r.byTarget {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].Target < records[j].Target })
+	return records
+}
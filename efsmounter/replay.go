@@ -0,0 +1,69 @@
+package efsmounter
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/dockerdriver"
+)
+
+// What Replay did about an operation left incomplete.
+const (
+	ReplayRolledBack = "rolled-back"
+	ReplayFinished   = "finished"
+)
+
+// ErrRolledBack is recorded as the failure of mounts rolled back by Replay.
+var ErrRolledBack = errors.New("rolled back at startup")
+
+type ReplayOutcome struct {
+	Entry  JournalEntry
+	Action string
+	Err    error
+}
+
+// Replay resolves the operations the journal records as begun but never over,
+// as a crash leaves them. A mount is rolled back and an unmount finished, so
+// that either way the target ends up unmounted, the state from which the
+// caller can safely try again; mounted tells whether a target still needs
+// unmounting with mounter. Operations whose target fails to unmount stay
+// incomplete for the next start, and the journal is emptied only once none
+// are left. Replay also returns the number of unreadable journal lines.
+func Replay(env dockerdriver.Env, journal *Journal, mounter Mounter, mounted func(target string) bool) ([]ReplayOutcome, int, error) {
+	entries, skipped, err := journal.Entries()
+	if err != nil {
+		return nil, skipped, err
+	}
+
+	var outcomes []ReplayOutcome
+	unmounted := map[string]bool{}
+	resolved := true
+	for _, intent := range Incomplete(entries) {
+		outcome := ReplayOutcome{Entry: intent, Action: ReplayFinished}
+		var opErr error
+		if intent.Op == OpMount {
+			outcome.Action = ReplayRolledBack
+			opErr = ErrRolledBack
+		}
+
+		if !unmounted[intent.Target] && mounted(intent.Target) {
+			outcome.Err = mounter.Unmount(env, intent.Target)
+		}
+		outcomes = append(outcomes, outcome)
+
+		if outcome.Err != nil {
+			resolved = false
+			continue
+		}
+		unmounted[intent.Target] = true
+		if err := journal.End(intent.ID, intent.Op, intent.Target, opErr); err != nil {
+			return outcomes, skipped, err
+		}
+	}
+
+	if resolved {
+		if err := journal.Truncate(); err != nil {
+			return outcomes, skipped, err
+		}
+	}
+	return outcomes, skipped, nil
+}
@@ -0,0 +1,133 @@
+package efsmounter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ParseAddress validates the address of an NFS server and returns it in the
+// form a mount source needs: IPv4 addresses as they are, IPv6 addresses in
+// brackets, and DNS names in lower case without a trailing dot. Bracketed and
+// bare IPv6 addresses are both accepted. Anything else, including addresses
+// that carry a port, a path or characters a shell would interpret, is refused.
+func ParseAddress(address string) (string, error) {
+	if address == "" {
+		return "", fmt.Errorf("invalid NFS server address '': must not be empty")
+	}
+
+	host := address
+	bracketed := strings.HasPrefix(address, "[") && strings.HasSuffix(address, "]")
+	if bracketed {
+		host = address[1 : len(address)-1]
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if ip.To4() == nil {
+			return "[" + ip.String() + "]", nil
+		}
+		if bracketed && !strings.Contains(host, ":") {
+			return "", fmt.Errorf("invalid NFS server address '%s': only IPv6 addresses may be bracketed", address)
+		}
+		// IPv4-mapped IPv6 addresses are written as plain IPv4
+		return ip.To4().String(), nil
+	}
+	if bracketed {
+		return "", fmt.Errorf("invalid NFS server address '%s': not an IPv6 address", address)
+	}
+
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if len(name) > 253 {
+		return "", fmt.Errorf("invalid NFS server address '%s': name is too long", address)
+	}
+	labels := strings.Split(name, ".")
+	for _, label := range labels {
+		if !validLabel(label) {
+			return "", fmt.Errorf("invalid NFS server address '%s': not an IP address or DNS name", address)
+		}
+	}
+	if allDigits(labels[len(labels)-1]) {
+		// a name like 10.0.0.256 is a mistyped IPv4 address, not a host
+		return "", fmt.Errorf("invalid NFS server address '%s': not an IP address or DNS name", address)
+	}
+	return name, nil
+}
+
+// ParseSource validates a mount source of the form address:/path, such as
+// fs-12345678.efs.us-east-1.amazonaws.com:/ or [fd00::1]:/exports, and returns
+// it with its address normalized by ParseAddress.
+func ParseSource(source string) (string, error) {
+	var address, path string
+	if strings.HasPrefix(source, "[") {
+		end := strings.Index(source, "]:")
+		if end < 0 {
+			return "", fmt.Errorf("invalid mount source '%s': expected [address]:/path", source)
+		}
+		address, path = source[:end+1], source[end+2:]
+	} else {
+		separator := strings.Index(source, ":")
+		if separator < 0 {
+			return "", fmt.Errorf("invalid mount source '%s': expected address:/path", source)
+		}
+		address, path = source[:separator], source[separator+1:]
+	}
+
+	address, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if err := validExportPath(path); err != nil {
+		return "", fmt.Errorf("invalid mount source '%s': %s", source, err.Error())
+	}
+	return address + ":" + path, nil
+}
+
+// Source returns the mount source for path exported by the server at address.
+func Source(address, path string) (string, error) {
+	address, err := ParseAddress(address)
+	if err != nil {
+		return "", err
+	}
+	if err := validExportPath(path); err != nil {
+		return "", err
+	}
+	return address + ":" + path, nil
+}
+
+func validExportPath(path string) error {
+	if !strings.HasPrefix(path, "/") {
+		return fmt.Errorf("path '%s' must be absolute", path)
+	}
+	for _, element := range strings.Split(path, "/") {
+		if element == ".." {
+			return fmt.Errorf("path '%s' must not contain '..'", path)
+		}
+	}
+	for _, r := range path {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune("`$&|;<>()\\\"' *?[]{}!~#", r) {
+			return fmt.Errorf("path '%s' must not contain %q", path, r)
+		}
+	}
+	return nil
+}
+
+func validLabel(label string) bool {
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func allDigits(label string) bool {
+	for _, c := range label {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,69 @@
+package efsmounter_test
+
+import (
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sources", func() {
+	Context("#ParseAddress", func() {
+		It("normalizes the addresses it accepts", func() {
+			for address, normalized := range map[string]string{
+				"10.0.0.1":                             "10.0.0.1",
+				"fd00::1":                              "[fd00::1]",
+				"[FD00:0:0::1]":                        "[fd00::1]",
+				"[::ffff:10.0.0.1]":                    "10.0.0.1",
+				"fs-1234.efs.us-east-1.amazonaws.com":  "fs-1234.efs.us-east-1.amazonaws.com",
+				"FS-1234.EFS.us-east-1.amazonaws.com.": "fs-1234.efs.us-east-1.amazonaws.com",
+				"nfs-server":                           "nfs-server",
+			} {
+				parsed, err := efsmounter.ParseAddress(address)
+				Expect(err).NotTo(HaveOccurred(), address)
+				Expect(parsed).To(Equal(normalized), address)
+			}
+		})
+
+		It("refuses anything else", func() {
+			for _, address := range []string{
+				"",
+				"10.0.0.256",
+				"[10.0.0.1]",
+				"[not-ipv6]",
+				"10.0.0.1:2049",
+				"10.0.0.1:/",
+				"server/export",
+				"server;reboot",
+				"$(reboot)",
+				"server name",
+				"-o",
+				"server..local",
+			} {
+				_, err := efsmounter.ParseAddress(address)
+				Expect(err).To(HaveOccurred(), address)
+			}
+		})
+	})
+
+	Context("#ParseSource", func() {
+		It("normalizes the address of the source", func() {
+			source, err := efsmounter.ParseSource("[FD00::1]:/exports/data")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(source).To(Equal("[fd00::1]:/exports/data"))
+		})
+
+		It("refuses sources without an absolute path", func() {
+			for _, source := range []string{"10.0.0.1", "10.0.0.1:exports", "[fd00::1]", "fd00::1:/"} {
+				_, err := efsmounter.ParseSource(source)
+				Expect(err).To(HaveOccurred(), source)
+			}
+		})
+
+		It("refuses paths that climb out of the export or carry shell syntax", func() {
+			for _, source := range []string{"10.0.0.1:/../etc", "10.0.0.1:/data;reboot", "10.0.0.1:/$(reboot)"} {
+				_, err := efsmounter.ParseSource(source)
+				Expect(err).To(HaveOccurred(), source)
+			}
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package efstracing_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEfsTracing(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EFS Tracing Suite")
+}
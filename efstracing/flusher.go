@@ -0,0 +1,55 @@
+package efstracing
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// DefaultFlushInterval is how often NewFlusher exports spans.
+const DefaultFlushInterval = 5 * time.Second
+
+// ExportTimeout bounds every export NewFlusher makes.
+const ExportTimeout = 10 * time.Second
+
+// NewFlusher exports the tracer's spans every interval, and once more when
+// signalled to stop so that the last spans are not lost.
+func NewFlusher(logger lager.Logger, tracer *Tracer, clock clock.Clock, interval time.Duration) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		logger := logger.Session("trace-flusher", lager.Data{"interval": interval.String()})
+		close(ready)
+
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				flush(logger, tracer)
+			case <-signals:
+				flush(logger, tracer)
+				return nil
+			}
+		}
+	})
+}
+
+func flush(logger lager.Logger, tracer *Tracer) {
+	ctx, cancel := context.WithTimeout(context.Background(), ExportTimeout)
+	defer cancel()
+
+	exported, dropped, err := tracer.Flush(ctx)
+	if err != nil {
+		logger.Error("export-failed", err, lager.Data{"dropped": dropped})
+		return
+	}
+	if dropped > 0 {
+		logger.Info("spans-dropped", lager.Data{"dropped": dropped})
+	}
+	if exported > 0 {
+		logger.Debug("exported", lager.Data{"spans": exported})
+	}
+}
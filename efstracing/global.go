@@ -0,0 +1,45 @@
+package efstracing
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracing is disabled until SetTracer is given a tracer, so the packages that
+// start spans need not be told whether it is on.
+var (
+	globalLock   sync.RWMutex
+	globalTracer *Tracer
+)
+
+// SetTracer makes Start record spans with tracer, or stop recording them if
+// tracer is nil.
+func SetTracer(tracer *Tracer) {
+	globalLock.Lock()
+	defer globalLock.Unlock()
+	globalTracer = tracer
+}
+
+func Enabled() bool {
+	return current() != nil
+}
+
+func current() *Tracer {
+	globalLock.RLock()
+	defer globalLock.RUnlock()
+	return globalTracer
+}
+
+// Start begins an internal span with the tracer given to SetTracer. While
+// tracing is disabled it returns ctx and a nil Span.
+func Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, *Span) {
+	return StartWithKind(ctx, KindInternal, name, attributes...)
+}
+
+func StartWithKind(ctx context.Context, kind SpanKind, name string, attributes ...Attribute) (context.Context, *Span) {
+	tracer := current()
+	if tracer == nil {
+		return ctx, nil
+	}
+	return tracer.Start(ctx, kind, name, attributes...)
+}
@@ -0,0 +1,118 @@
+package efstracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/efsdriver/efstracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("OTLPExporter", func() {
+	var (
+		collector *httptest.Server
+		requests  chan *http.Request
+		bodies    chan map[string]interface{}
+		status    int
+	)
+
+	// collector stands in for an OpenTelemetry collector's OTLP/HTTP receiver
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan map[string]interface{}, 1)
+		status = http.StatusOK
+		collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			body, _ := ioutil.ReadAll(req.Body)
+			var decoded map[string]interface{}
+			json.Unmarshal(body, &decoded)
+			requests <- req
+			bodies <- decoded
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		collector.Close()
+	})
+
+	It("posts spans in the OTLP/HTTP JSON encoding", func() {
+		exporter, err := efstracing.NewOTLPExporter(collector.URL, "some-service", http.DefaultClient)
+		Expect(err).NotTo(HaveOccurred())
+
+		fakeClock := fakeclock.NewFakeClock(time.Unix(1, 0))
+		tracer := efstracing.NewTracer(fakeClock, exporter)
+		ctx, parent := tracer.Start(context.Background(), efstracing.KindServer, "parent")
+		_, child := tracer.Start(ctx, efstracing.KindInternal, "child", efstracing.String("s", "v"), efstracing.Int("i", 42), efstracing.Bool("b", true))
+		fakeClock.Increment(time.Second)
+		child.RecordError(errors.New("badness"))
+		child.End()
+		parent.End()
+
+		_, _, err = tracer.Flush(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+
+		var request *http.Request
+		Eventually(requests).Should(Receive(&request))
+		Expect(request.Method).To(Equal("POST"))
+		Expect(request.URL.Path).To(Equal(efstracing.TracesPath))
+		Expect(request.Header.Get("Content-Type")).To(Equal("application/json"))
+
+		var body map[string]interface{}
+		Eventually(bodies).Should(Receive(&body))
+		resourceSpans := body["resourceSpans"].([]interface{})[0].(map[string]interface{})
+		Expect(resourceSpans["resource"]).To(Equal(map[string]interface{}{
+			"attributes": []interface{}{map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "some-service"}}},
+		}))
+		spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0]).To(Equal(map[string]interface{}{
+			"traceId":           parent.SpanContext().TraceID.String(),
+			"spanId":            child.SpanContext().SpanID.String(),
+			"parentSpanId":      parent.SpanContext().SpanID.String(),
+			"name":              "child",
+			"kind":              float64(1),
+			"startTimeUnixNano": "1000000000",
+			"endTimeUnixNano":   "2000000000",
+			"attributes": []interface{}{
+				map[string]interface{}{"key": "s", "value": map[string]interface{}{"stringValue": "v"}},
+				map[string]interface{}{"key": "i", "value": map[string]interface{}{"intValue": "42"}},
+				map[string]interface{}{"key": "b", "value": map[string]interface{}{"boolValue": true}},
+			},
+			"status": map[string]interface{}{"code": float64(2), "message": "badness"},
+		}))
+		Expect(spans[1]).NotTo(HaveKey("parentSpanId"))
+		Expect(spans[1]).To(HaveKeyWithValue("kind", float64(2)))
+		Expect(spans[1]).To(HaveKeyWithValue("status", map[string]interface{}{"code": float64(1)}))
+	})
+
+	It("keeps the path of the endpoint it is given", func() {
+		exporter, err := efstracing.NewOTLPExporter(collector.URL+"/custom/traces", "some-service", http.DefaultClient)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(exporter.Export(context.Background(), []efstracing.SpanData{{Name: "span"}})).To(Succeed())
+		var request *http.Request
+		Eventually(requests).Should(Receive(&request))
+		Expect(request.URL.Path).To(Equal("/custom/traces"))
+	})
+
+	It("reports a collector that refuses the spans", func() {
+		status = http.StatusBadRequest
+		exporter, err := efstracing.NewOTLPExporter(collector.URL, "some-service", http.DefaultClient)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = exporter.Export(context.Background(), []efstracing.SpanData{{Name: "span"}})
+		Expect(err).To(MatchError(ContainSubstring("400 Bad Request")))
+	})
+
+	It("refuses endpoints that are not http URLs", func() {
+		_, err := efstracing.NewOTLPExporter("127.0.0.1:4318", "some-service", http.DefaultClient)
+		Expect(err).To(MatchError(ContainSubstring("expected an http or https URL")))
+	})
+})
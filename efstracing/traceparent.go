@@ -0,0 +1,94 @@
+package efstracing
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader carries the caller's span in the W3C Trace Context
+// format: version, trace id, parent span id and flags, in lower case hex.
+const TraceparentHeader = "traceparent"
+
+const sampledFlag = 0x01
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+func (id SpanID) String() string  { return hex.EncodeToString(id[:]) }
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+// IsValid reports whether both ids are set, as the W3C format requires.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != TraceID{} && sc.SpanID != SpanID{}
+}
+
+func FormatTraceparent(sc SpanContext) string {
+	flags := 0
+	if sc.Sampled {
+		flags |= sampledFlag
+	}
+	return fmt.Sprintf("00-%s-%s-%02x", sc.TraceID, sc.SpanID, flags)
+}
+
+// ParseTraceparent reads a traceparent header. Versions after 00 are read as
+// far as version 00 goes, as the format asks.
+func ParseTraceparent(value string) (SpanContext, error) {
+	invalid := fmt.Errorf("invalid %s header '%s'", TraceparentHeader, value)
+
+	parts := strings.Split(value, "-")
+	if len(parts) < 4 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, invalid
+	}
+
+	var sc SpanContext
+	var version, flags [1]byte
+	if !decodeHex(version[:], parts[0]) || !decodeHex(sc.TraceID[:], parts[1]) || !decodeHex(sc.SpanID[:], parts[2]) || !decodeHex(flags[:], parts[3]) {
+		return SpanContext{}, invalid
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, invalid
+	}
+	sc.Sampled = flags[0]&sampledFlag != 0
+	return sc, nil
+}
+
+// decodeHex fills dst from exactly len(dst) bytes of lower case hex.
+func decodeHex(dst []byte, value string) bool {
+	if len(value) != hex.EncodedLen(len(dst)) || strings.ToLower(value) != value {
+		return false
+	}
+	_, err := hex.Decode(dst, []byte(value))
+	return err == nil
+}
+
+// Inject sets the TraceparentHeader to the span ctx carries, if any.
+func Inject(ctx context.Context, header http.Header) {
+	if sc, ok := SpanContextFromContext(ctx); ok {
+		header.Set(TraceparentHeader, FormatTraceparent(sc))
+	}
+}
+
+// Extract returns a context whose spans continue the trace in the
+// TraceparentHeader. A missing or malformed header leaves ctx as it is, so
+// that spans started from it begin a new trace.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	value := header.Get(TraceparentHeader)
+	if value == "" {
+		return ctx
+	}
+	sc, err := ParseTraceparent(value)
+	if err != nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, &Span{data: SpanData{SpanContext: sc}, remote: true})
+}
@@ -0,0 +1,65 @@
+package efstracing_test
+
+import (
+	"context"
+	"net/http"
+
+	"code.cloudfoundry.org/efsdriver/efstracing"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traceparent", func() {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	It("reads and writes the W3C format", func() {
+		sc, err := efstracing.ParseTraceparent(traceparent)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		Expect(sc.SpanID.String()).To(Equal("00f067aa0ba902b7"))
+		Expect(sc.Sampled).To(BeTrue())
+		Expect(efstracing.FormatTraceparent(sc)).To(Equal(traceparent))
+	})
+
+	It("reads later versions as far as version 00 goes", func() {
+		sc, err := efstracing.ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sc.Sampled).To(BeFalse())
+	})
+
+	It("refuses malformed headers", func() {
+		for _, value := range []string{
+			"",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+			traceparent + "-extra",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		} {
+			_, err := efstracing.ParseTraceparent(value)
+			Expect(err).To(MatchError(ContainSubstring("invalid traceparent header")), value)
+		}
+	})
+
+	It("injects the span a context carries and extracts it again", func() {
+		header := http.Header{}
+		header.Set(efstracing.TraceparentHeader, traceparent)
+		ctx := efstracing.Extract(context.Background(), header)
+
+		out := http.Header{}
+		efstracing.Inject(ctx, out)
+		Expect(out.Get(efstracing.TraceparentHeader)).To(Equal(traceparent))
+		Expect(efstracing.SpanFromContext(ctx)).To(BeNil())
+	})
+
+	It("ignores a malformed header", func() {
+		header := http.Header{}
+		header.Set(efstracing.TraceparentHeader, "nonsense")
+		ctx := efstracing.Extract(context.Background(), header)
+
+		_, ok := efstracing.SpanContextFromContext(ctx)
+		Expect(ok).To(BeFalse())
+	})
+})
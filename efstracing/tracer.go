@@ -0,0 +1,202 @@
+package efstracing
+
+import (
+	"context"
+	"crypto/rand"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// MaxPendingSpans bounds the spans a Tracer holds between flushes. Spans
+// ended while it is full are dropped.
+const MaxPendingSpans = 2048
+
+type SpanKind int
+
+const (
+	KindInternal SpanKind = iota + 1
+	KindServer
+	KindClient
+)
+
+// Attribute is a key and a string, int64 or bool value describing a span.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+func String(key, value string) Attribute    { return Attribute{key, value} }
+func Int(key string, value int64) Attribute { return Attribute{key, value} }
+func Bool(key string, value bool) Attribute { return Attribute{key, value} }
+
+// SpanData is what is exported of an ended span.
+type SpanData struct {
+	Name          string
+	Kind          SpanKind
+	SpanContext   SpanContext
+	Parent        SpanID
+	Start         time.Time
+	End           time.Time
+	Attributes    []Attribute
+	Failed        bool
+	StatusMessage string
+}
+
+// Tracer records spans and hands them to its exporter when flushed. Run
+// NewFlusher alongside it to flush periodically.
+type Tracer struct {
+	clock    clock.Clock
+	exporter Exporter
+
+	lock    sync.Mutex
+	pending []SpanData
+	dropped int
+}
+
+func NewTracer(clock clock.Clock, exporter Exporter) *Tracer {
+	return &Tracer{clock: clock, exporter: exporter}
+}
+
+// Start begins a span, the child of the one ctx carries if any, and returns
+// a context carrying the new span. Spans whose remote parent was not sampled
+// are propagated but not recorded.
+func (t *Tracer) Start(ctx context.Context, kind SpanKind, name string, attributes ...Attribute) (context.Context, *Span) {
+	span := &Span{
+		tracer: t,
+		data: SpanData{
+			Name:       name,
+			Kind:       kind,
+			Start:      t.clock.Now(),
+			Attributes: attributes,
+		},
+	}
+
+	if parent, ok := SpanContextFromContext(ctx); ok {
+		span.data.SpanContext.TraceID = parent.TraceID
+		span.data.SpanContext.Sampled = parent.Sampled
+		span.data.Parent = parent.SpanID
+	} else {
+		rand.Read(span.data.SpanContext.TraceID[:])
+		span.data.SpanContext.Sampled = true
+	}
+	rand.Read(span.data.SpanContext.SpanID[:])
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) record(data SpanData) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if len(t.pending) >= MaxPendingSpans {
+		t.dropped++
+		return
+	}
+	t.pending = append(t.pending, data)
+}
+
+// Flush exports the spans ended since the last flush, and reports how many
+// were dropped in that time. Spans that fail to export are not retried.
+func (t *Tracer) Flush(ctx context.Context) (exported, dropped int, err error) {
+	t.lock.Lock()
+	pending, dropped := t.pending, t.dropped
+	t.pending, t.dropped = nil, 0
+	t.lock.Unlock()
+
+	if len(pending) == 0 {
+		return 0, dropped, nil
+	}
+	if err := t.exporter.Export(ctx, pending); err != nil {
+		return 0, dropped + len(pending), err
+	}
+	return len(pending), dropped, nil
+}
+
+// Span is an operation being traced. The methods of a nil Span do nothing,
+// which is what Start returns while tracing is disabled.
+type Span struct {
+	tracer *Tracer
+	remote bool
+
+	lock  sync.Mutex
+	data  SpanData
+	ended bool
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span started in this process that ctx
+// carries, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok && !span.remote {
+		return span
+	}
+	return nil
+}
+
+// ContextWithSpan returns ctx carrying span, for work that must outlive the
+// context span was started in but still belongs to it.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanContextFromContext returns the span ctx carries, whether started in
+// this process or extracted from a caller's request.
+func SpanContextFromContext(ctx context.Context) (SpanContext, bool) {
+	if span, ok := ctx.Value(spanKey{}).(*Span); ok && span != nil {
+		return span.data.SpanContext, true
+	}
+	return SpanContext{}, false
+}
+
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.data.SpanContext
+}
+
+func (s *Span) SetAttributes(attributes ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Attributes = append(s.data.Attributes, attributes...)
+}
+
+// RecordError marks the span as failed with err, unless err is nil.
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.data.Failed = true
+	s.data.StatusMessage = err.Error()
+}
+
+// End records the span with its tracer. Only the first call counts.
+func (s *Span) End() {
+	if s == nil || s.remote {
+		return
+	}
+	s.lock.Lock()
+	if s.ended {
+		s.lock.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = s.tracer.clock.Now()
+	data := s.data
+	data.Attributes = append([]Attribute{}, s.data.Attributes...)
+	s.lock.Unlock()
+
+	if data.SpanContext.Sampled {
+		s.tracer.record(data)
+	}
+}
@@ -0,0 +1,154 @@
+package efstracing_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efstracing"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Tracer", func() {
+	var (
+		fakeClock    *fakeclock.FakeClock
+		fakeExporter *efsdriverfakes.FakeExporter
+		tracer       *efstracing.Tracer
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeExporter = &efsdriverfakes.FakeExporter{}
+		tracer = efstracing.NewTracer(fakeClock, fakeExporter)
+	})
+
+	exported := func() []efstracing.SpanData {
+		_, _, err := tracer.Flush(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeExporter.ExportCallCount()).To(Equal(1))
+		_, spans := fakeExporter.ExportArgsForCall(0)
+		return spans
+	}
+
+	It("records spans and their children in one trace", func() {
+		start := fakeClock.Now()
+		ctx, parent := tracer.Start(context.Background(), efstracing.KindServer, "parent", efstracing.String("a", "b"))
+		_, child := tracer.Start(ctx, efstracing.KindInternal, "child")
+		fakeClock.Increment(time.Second)
+		child.RecordError(errors.New("badness"))
+		child.End()
+		parent.End()
+		parent.End()
+
+		spans := exported()
+		Expect(spans).To(HaveLen(2))
+		Expect(spans[0].Name).To(Equal("child"))
+		Expect(spans[0].SpanContext.TraceID).To(Equal(parent.SpanContext().TraceID))
+		Expect(spans[0].Parent).To(Equal(parent.SpanContext().SpanID))
+		Expect(spans[0].Failed).To(BeTrue())
+		Expect(spans[0].StatusMessage).To(Equal("badness"))
+		Expect(spans[0].Start).To(Equal(start))
+		Expect(spans[0].End).To(Equal(start.Add(time.Second)))
+
+		Expect(spans[1].Name).To(Equal("parent"))
+		Expect(spans[1].Kind).To(Equal(efstracing.KindServer))
+		Expect(spans[1].Parent).To(Equal(efstracing.SpanID{}))
+		Expect(spans[1].Attributes).To(Equal([]efstracing.Attribute{efstracing.String("a", "b")}))
+		Expect(spans[1].SpanContext.Sampled).To(BeTrue())
+	})
+
+	It("propagates but does not record spans whose remote parent was not sampled", func() {
+		remote, err := efstracing.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		Expect(err).NotTo(HaveOccurred())
+		ctx := efstracing.ContextWithSpan(context.Background(), nil)
+		Expect(ctx).To(Equal(context.Background()))
+
+		header := http.Header{}
+		header.Set(efstracing.TraceparentHeader, efstracing.FormatTraceparent(remote))
+		_, span := tracer.Start(efstracing.Extract(context.Background(), header), efstracing.KindServer, "server")
+		span.End()
+
+		Expect(span.SpanContext().TraceID).To(Equal(remote.TraceID))
+		Expect(span.SpanContext().Sampled).To(BeFalse())
+		exportedCount, _, err := tracer.Flush(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exportedCount).To(Equal(0))
+		Expect(fakeExporter.ExportCallCount()).To(Equal(0))
+	})
+
+	It("drops spans beyond MaxPendingSpans", func() {
+		for i := 0; i < efstracing.MaxPendingSpans+3; i++ {
+			_, span := tracer.Start(context.Background(), efstracing.KindInternal, "span")
+			span.End()
+		}
+
+		exportedCount, dropped, err := tracer.Flush(context.Background())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(exportedCount).To(Equal(efstracing.MaxPendingSpans))
+		Expect(dropped).To(Equal(3))
+	})
+
+	It("reports spans it failed to export as dropped", func() {
+		fakeExporter.ExportReturns(errors.New("collector down"))
+		_, span := tracer.Start(context.Background(), efstracing.KindInternal, "span")
+		span.End()
+
+		_, dropped, err := tracer.Flush(context.Background())
+		Expect(err).To(MatchError("collector down"))
+		Expect(dropped).To(Equal(1))
+	})
+
+	Context("when used through the package", func() {
+		AfterEach(func() {
+			efstracing.SetTracer(nil)
+		})
+
+		It("does nothing until a tracer is set", func() {
+			ctx, span := efstracing.Start(context.Background(), "span")
+			Expect(ctx).To(Equal(context.Background()))
+			Expect(span).To(BeNil())
+			Expect(efstracing.Enabled()).To(BeFalse())
+
+			span.SetAttributes(efstracing.Bool("a", true))
+			span.RecordError(errors.New("badness"))
+			span.End()
+		})
+
+		It("records spans with the tracer set", func() {
+			efstracing.SetTracer(tracer)
+			_, span := efstracing.StartWithKind(context.Background(), efstracing.KindClient, "span")
+			span.End()
+
+			spans := exported()
+			Expect(spans).To(HaveLen(1))
+			Expect(spans[0].Kind).To(Equal(efstracing.KindClient))
+		})
+	})
+
+	Context("#NewFlusher", func() {
+		It("exports periodically and once more when stopped", func() {
+			_, span := tracer.Start(context.Background(), efstracing.KindInternal, "first")
+			span.End()
+
+			process := ifrit.Invoke(efstracing.NewFlusher(lagertest.NewTestLogger("flusher"), tracer, fakeClock, time.Minute))
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(fakeExporter.ExportCallCount).Should(Equal(1))
+
+			_, span = tracer.Start(context.Background(), efstracing.KindInternal, "last")
+			span.End()
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+
+			Expect(fakeExporter.ExportCallCount()).To(Equal(2))
+			_, spans := fakeExporter.ExportArgsForCall(1)
+			Expect(spans[0].Name).To(Equal("last"))
+		})
+	})
+})
@@ -0,0 +1,139 @@
+package efsvoltools
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// ACE is a single NFSv4 access control entry.
+type ACE struct {
+	Type        string
+	Flags       []string `json:",omitempty"`
+	Principal   string
+	Permissions []string
+}
+
+const (
+	ACETypeAllow = "allow"
+	ACETypeDeny  = "deny"
+	ACETypeAudit = "audit"
+	ACETypeAlarm = "alarm"
+)
+
+const (
+	ACEFlagFileInherit      = "file_inherit"
+	ACEFlagDirectoryInherit = "directory_inherit"
+	ACEFlagNoPropagate      = "no_propagate_inherit"
+	ACEFlagInheritOnly      = "inherit_only"
+	ACEFlagSuccessfulAccess = "successful_access"
+	ACEFlagFailedAccess     = "failed_access"
+	ACEFlagGroup            = "group"
+)
+
+const (
+	ACEPermReadData        = "read_data"
+	ACEPermWriteData       = "write_data"
+	ACEPermAppendData      = "append_data"
+	ACEPermExecute         = "execute"
+	ACEPermDelete          = "delete"
+	ACEPermDeleteChild     = "delete_child"
+	ACEPermReadAttributes  = "read_attributes"
+	ACEPermWriteAttributes = "write_attributes"
+	ACEPermReadNamedAttrs  = "read_named_attrs"
+	ACEPermWriteNamedAttrs = "write_named_attrs"
+	ACEPermReadACL         = "read_acl"
+	ACEPermWriteACL        = "write_acl"
+	ACEPermWriteOwner      = "write_owner"
+	ACEPermSynchronize     = "synchronize"
+)
+
+const (
+	PrincipalOwner    = "OWNER@"
+	PrincipalGroup    = "GROUP@"
+	PrincipalEveryone = "EVERYONE@"
+)
+
+// MaxACEs bounds the size of an ACL accepted by SetACL.
+const MaxACEs = 256
+
+var aceTypes = []string{ACETypeAllow, ACETypeDeny, ACETypeAudit, ACETypeAlarm}
+
+var aceFlags = []string{
+	ACEFlagFileInherit, ACEFlagDirectoryInherit, ACEFlagNoPropagate, ACEFlagInheritOnly,
+	ACEFlagSuccessfulAccess, ACEFlagFailedAccess, ACEFlagGroup,
+}
+
+var acePermissions = []string{
+	ACEPermReadData, ACEPermWriteData, ACEPermAppendData, ACEPermExecute, ACEPermDelete,
+	ACEPermDeleteChild, ACEPermReadAttributes, ACEPermWriteAttributes, ACEPermReadNamedAttrs,
+	ACEPermWriteNamedAttrs, ACEPermReadACL, ACEPermWriteACL, ACEPermWriteOwner, ACEPermSynchronize,
+}
+
+func (a ACE) Validate() error {
+	if !contains(aceTypes, a.Type) {
+		return fmt.Errorf("invalid ACE type '%s'", a.Type)
+	}
+
+	if a.Principal == "" {
+		return fmt.Errorf("missing ACE principal")
+	}
+	for _, r := range a.Principal {
+		if r == ':' || r == ',' || unicode.IsSpace(r) || unicode.IsControl(r) {
+			return fmt.Errorf("invalid character %q in ACE principal '%s'", r, a.Principal)
+		}
+	}
+	special := strings.HasSuffix(a.Principal, "@")
+	if special && a.Principal != PrincipalOwner && a.Principal != PrincipalGroup && a.Principal != PrincipalEveryone {
+		return fmt.Errorf("unknown special ACE principal '%s'", a.Principal)
+	}
+
+	group := false
+	for _, flag := range a.Flags {
+		if !contains(aceFlags, flag) {
+			return fmt.Errorf("invalid ACE flag '%s'", flag)
+		}
+		group = group || flag == ACEFlagGroup
+	}
+	if a.Principal == PrincipalGroup && !group {
+		return fmt.Errorf("ACE principal '%s' requires the '%s' flag", PrincipalGroup, ACEFlagGroup)
+	}
+	if group && (a.Principal == PrincipalOwner || a.Principal == PrincipalEveryone) {
+		return fmt.Errorf("ACE principal '%s' cannot carry the '%s' flag", a.Principal, ACEFlagGroup)
+	}
+
+	if len(a.Permissions) == 0 {
+		return fmt.Errorf("ACE for '%s' grants no permissions", a.Principal)
+	}
+	for _, permission := range a.Permissions {
+		if !contains(acePermissions, permission) {
+			return fmt.Errorf("invalid ACE permission '%s'", permission)
+		}
+	}
+	return nil
+}
+
+// ValidateACL checks a whole ACL before it is applied.
+func ValidateACL(aces []ACE) error {
+	if len(aces) == 0 {
+		return fmt.Errorf("an ACL needs at least one ACE")
+	}
+	if len(aces) > MaxACEs {
+		return fmt.Errorf("an ACL may hold at most %d ACEs", MaxACEs)
+	}
+	for i, ace := range aces {
+		if err := ace.Validate(); err != nil {
+			return fmt.Errorf("ACE %d: %s", i, err.Error())
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
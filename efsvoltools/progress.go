@@ -0,0 +1,24 @@
+package efsvoltools
+
+import (
+	"context"
+
+	"code.cloudfoundry.org/dockerdriver"
+)
+
+type ProgressFunc func(Progress)
+
+type progressKey struct{}
+
+// WithProgress returns a context that routes ReportProgress calls to fn.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress lets a long running operation publish how far it has got.
+// It is a no-op unless the caller asked for progress through WithProgress.
+func ReportProgress(env dockerdriver.Env, progress Progress) {
+	if fn, ok := env.Context().Value(progressKey{}).(ProgressFunc); ok {
+		fn(progress)
+	}
+}
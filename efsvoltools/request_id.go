@@ -0,0 +1,52 @@
+package efsvoltools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// RequestIdHeader carries an id that ties together the log lines the client
+// and the server write for one request. The server echoes it in its response.
+const RequestIdHeader = "X-Request-Id"
+
+// maxRequestIdLength bounds the ids accepted from callers, which end up in
+// every log line written for the request.
+const maxRequestIdLength = 128
+
+type requestIdKey struct{}
+
+// WithRequestId returns a context carrying id, to be sent along with any
+// request made with it.
+func WithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestId returns the id ctx carries, or "" if it carries none.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdKey{}).(string)
+	return id
+}
+
+// NewRequestId returns a random id, for requests that do not carry one yet.
+func NewRequestId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestId reports whether id is short and made only of printable
+// ASCII, so that it is safe to log and to send back as a header.
+func ValidRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -1,22 +1,137 @@
 package efsvoltools
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
 	"code.cloudfoundry.org/dockerdriver"
 	"github.com/tedsuo/rata"
 )
 
 const (
-	OpenPermsRoute = "openPerms"
+	OpenPermsRoute      = "openPerms"
+	BatchOpenPermsRoute = "batchOpenPerms"
+	RepairPermsRoute    = "repairPerms"
+	ExportRoute         = "export"
+	ImportRoute         = "import"
+	CloneRoute          = "cloneDirectory"
+	ListRoute           = "listDirectories"
+	GetACLRoute         = "getACL"
+	SetACLRoute         = "setACL"
+	GetJobRoute         = "getJob"
+	CancelJobRoute      = "cancelJob"
+	CapabilitiesRoute   = "capabilities"
 )
 
 var Routes = rata.Routes{
 	{Path: "/EfsDriver.OpenPerms", Method: "POST", Name: OpenPermsRoute},
+	{Path: "/EfsDriver.BatchOpenPerms", Method: "POST", Name: BatchOpenPermsRoute},
+	{Path: "/EfsDriver.RepairPerms", Method: "POST", Name: RepairPermsRoute},
+	{Path: "/EfsDriver.Export", Method: "POST", Name: ExportRoute},
+	{Path: "/EfsDriver.Import", Method: "POST", Name: ImportRoute},
+	{Path: "/EfsDriver.CloneDirectory", Method: "POST", Name: CloneRoute},
+	{Path: "/EfsDriver.ListDirectories", Method: "POST", Name: ListRoute},
+	{Path: "/EfsDriver.GetACL", Method: "POST", Name: GetACLRoute},
+	{Path: "/EfsDriver.SetACL", Method: "POST", Name: SetACLRoute},
+	{Path: "/EfsDriver.Jobs/:job_id", Method: "GET", Name: GetJobRoute},
+	{Path: "/EfsDriver.Jobs/:job_id", Method: "DELETE", Name: CancelJobRoute},
+	{Path: "/EfsDriver.Capabilities", Method: "GET", Name: CapabilitiesRoute},
+}
+
+// APIVersion is raised whenever a route changes in a way older clients cannot
+// cope with. Adding an operation does not change it; clients find out about
+// new operations through CapabilitiesRoute instead.
+const APIVersion = 1
+
+// Operations are the routes a driver may or may not support, depending on its
+// version. A driver that predates CapabilitiesRoute only supports
+// LegacyOperations.
+var Operations = []string{
+	OpenPermsRoute,
+	BatchOpenPermsRoute,
+	RepairPermsRoute,
+	ExportRoute,
+	ImportRoute,
+	CloneRoute,
+	ListRoute,
+	GetACLRoute,
+	SetACLRoute,
+}
+
+var LegacyOperations = []string{OpenPermsRoute}
+
+// IdempotentRoutes are the operations that leave the volume in the same state
+// however often they run, so clients may safely send them again after a
+// connection error or server failure. Import is not among them: its archive
+// is streamed and cannot be replayed.
+var IdempotentRoutes = map[string]bool{
+	OpenPermsRoute:      true,
+	BatchOpenPermsRoute: true,
+	RepairPermsRoute:    true,
+	ExportRoute:         true,
+	CloneRoute:          true,
+	ListRoute:           true,
+	GetACLRoute:         true,
+	SetACLRoute:         true,
+	GetJobRoute:         true,
+	CancelJobRoute:      true,
+	CapabilitiesRoute:   true,
+}
+
+// Requests carrying a "Prefer: respond-async" header are run in the background;
+// the server answers 202 with a Job that can be polled through GetJobRoute.
+const (
+	PreferHeader = "Prefer"
+	PreferAsync  = "respond-async"
+)
+
+// A request carrying a DeadlineHeader is abandoned by the server once the time
+// it gives has passed. The header holds the time left, in milliseconds, rather
+// than a point in time, so that clocks need not agree.
+const DeadlineHeader = "X-Efs-Deadline"
+
+func FormatDeadline(remaining time.Duration) string {
+	milliseconds := int64(remaining / time.Millisecond)
+	if milliseconds < 1 {
+		milliseconds = 1
+	}
+	return strconv.FormatInt(milliseconds, 10)
+}
+
+func ParseDeadline(value string) (time.Duration, error) {
+	milliseconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || milliseconds < 1 {
+		return 0, fmt.Errorf("invalid %s header '%s'", DeadlineHeader, value)
+	}
+	return time.Duration(milliseconds) * time.Millisecond, nil
 }
 
+// Export and Import stream a gzipped tarball as the response and request body
+// respectively. Import carries its JSON request in RequestHeader, and an error
+// hit after an export has started streaming is sent in the StreamErrorTrailer.
+const (
+	RequestHeader      = "X-Efs-Request"
+	StreamErrorTrailer = "X-Efs-Stream-Error"
+	ArchiveContentType = "application/gzip"
+)
+
 //go:generate counterfeiter -o ../efsdriverfakes/fake_vol_tool.go . VolTools
 
 type VolTools interface {
 	OpenPerms(env dockerdriver.Env, getRequest OpenPermsRequest) ErrorResponse
+	BatchOpenPerms(env dockerdriver.Env, batchRequest BatchOpenPermsRequest) BatchOpenPermsResponse
+	RepairPerms(env dockerdriver.Env, repairRequest RepairPermsRequest) RepairPermsResponse
+	Export(env dockerdriver.Env, exportRequest ExportRequest, w io.Writer) ErrorResponse
+	Import(env dockerdriver.Env, importRequest ImportRequest, r io.Reader) ErrorResponse
+	CloneDirectory(env dockerdriver.Env, cloneRequest CloneDirectoryRequest) CloneDirectoryResponse
+	ListDirectories(env dockerdriver.Env, listRequest ListDirectoriesRequest) ListDirectoriesResponse
+	GetACL(env dockerdriver.Env, getACLRequest GetACLRequest) GetACLResponse
+	SetACL(env dockerdriver.Env, setACLRequest SetACLRequest) ErrorResponse
+	Capabilities(env dockerdriver.Env) CapabilitiesResponse
 }
 
 type OpenPermsRequest struct {
@@ -24,6 +139,213 @@ type OpenPermsRequest struct {
 	Opts map[string]interface{}
 }
 
+// BatchOpenPermsRequest opens the permissions of many volumes at once. Entries
+// on the same file system are handled with a single mount, and up to
+// Parallelism file systems are handled at a time.
+type BatchOpenPermsRequest struct {
+	Entries     []OpenPermsRequest
+	Parallelism int
+}
+
+// BatchOpenPermsResponse holds a result for every entry of the request, in the
+// same order; entries that succeeded have an empty Err. Failed counts the
+// entries that did not, and Err is only set when the batch as a whole failed.
+type BatchOpenPermsResponse struct {
+	Results []ErrorResponse
+	Failed  int64
+	ErrorResponse
+}
+
+// RepairPermsRequest describes the ownership and modes to apply to every entry
+// below Path (relative to the root of the file system). Nil fields are left
+// untouched. Symlinks are never followed or modified.
+type RepairPermsRequest struct {
+	Name        string
+	Opts        map[string]interface{}
+	Path        string
+	Uid         *int
+	Gid         *int
+	FileMode    *os.FileMode
+	DirMode     *os.FileMode
+	Parallelism int
+}
+
+type RepairPermsResponse struct {
+	Changed   int64
+	Unchanged int64
+	Skipped   int64
+	Failed    int64
+	ErrorResponse
+}
+
+type ExportRequest struct {
+	Name string
+	Opts map[string]interface{}
+	Path string
+}
+
+type ImportRequest struct {
+	Name string
+	Opts map[string]interface{}
+	Path string
+}
+
+// CloneLocation names a directory on a volume; Path is relative to the root of
+// the file system.
+type CloneLocation struct {
+	Name string
+	Opts map[string]interface{}
+	Path string
+}
+
+type CloneDirectoryRequest struct {
+	Source      CloneLocation
+	Destination CloneLocation
+	Parallelism int
+}
+
+type CloneDirectoryResponse struct {
+	Copied  int64
+	Skipped int64
+	Failed  int64
+	Bytes   int64
+	ErrorResponse
+}
+
+// ListDirectoriesRequest lists Depth levels below Path, PageSize entries at a
+// time. PageToken is empty for the first page and otherwise the NextPageToken
+// of the previous response. IncludeUsage adds up the size of every file below
+// each directory, which can be slow on large trees.
+type ListDirectoriesRequest struct {
+	Name         string
+	Opts         map[string]interface{}
+	Path         string
+	Depth        int
+	PageSize     int
+	PageToken    string
+	IncludeUsage bool
+}
+
+type DirectoryEntry struct {
+	Path    string
+	IsDir   bool
+	Size    int64
+	Usage   *int64 `json:",omitempty"`
+	Uid     uint32
+	Gid     uint32
+	Mode    string
+	ModTime time.Time
+}
+
+type ListDirectoriesResponse struct {
+	Entries       []DirectoryEntry
+	NextPageToken string `json:",omitempty"`
+	ErrorResponse
+}
+
+type GetACLRequest struct {
+	Name string
+	Opts map[string]interface{}
+	Path string
+}
+
+type GetACLResponse struct {
+	ACEs []ACE
+	ErrorResponse
+}
+
+// SetACLRequest replaces the whole NFSv4 ACL of Path with ACEs, in order.
+type SetACLRequest struct {
+	Name string
+	Opts map[string]interface{}
+	Path string
+	ACEs []ACE
+}
+
+type CapabilitiesResponse struct {
+	APIVersion int
+	Operations []string
+	ErrorResponse
+}
+
+// Supports reports whether operation is among the operations.
+func (c CapabilitiesResponse) Supports(operation string) bool {
+	for _, supported := range c.Operations {
+		if supported == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorCode classifies an ErrorResponse so clients need not match on Err,
+// which stays a human readable message.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidRequest ErrorCode = "invalid_request"
+	ErrorCodeMountFailed    ErrorCode = "mount_failed"
+	ErrorCodeChmodFailed    ErrorCode = "chmod_failed"
+	ErrorCodeNotFound       ErrorCode = "not_found"
+	ErrorCodeTimeout        ErrorCode = "timeout"
+	ErrorCodeUnauthorized   ErrorCode = "unauthorized"
+	ErrorCodeTooManyJobs    ErrorCode = "too_many_jobs"
+	// ErrorCodeInternal covers every failure without a more specific code.
+	ErrorCodeInternal ErrorCode = "internal"
+)
+
+// ErrorResponse reports the outcome of an operation; Err is empty on success.
+// Retryable is set when sending the same request again may succeed.
 type ErrorResponse struct {
-	Err string
+	Err       string
+	Code      ErrorCode         `json:",omitempty"`
+	Details   map[string]string `json:",omitempty"`
+	Retryable bool              `json:",omitempty"`
+}
+
+func NewErrorResponse(code ErrorCode, err string) ErrorResponse {
+	return ErrorResponse{Err: err, Code: code, Retryable: code == ErrorCodeMountFailed || code == ErrorCodeTimeout || code == ErrorCodeTooManyJobs}
+}
+
+// WithDetail returns a copy of e with key set to value in its Details.
+func (e ErrorResponse) WithDetail(key, value string) ErrorResponse {
+	details := map[string]string{key: value}
+	for k, v := range e.Details {
+		if k != key {
+			details[k] = v
+		}
+	}
+	e.Details = details
+	return e
+}
+
+type JobState string
+
+const (
+	JobPending   JobState = "pending"
+	JobRunning   JobState = "running"
+	JobSucceeded JobState = "succeeded"
+	JobFailed    JobState = "failed"
+	JobCancelled JobState = "cancelled"
+)
+
+func (s JobState) Finished() bool {
+	return s == JobSucceeded || s == JobFailed || s == JobCancelled
+}
+
+type Job struct {
+	ID        string
+	Operation string
+	State     JobState
+	Progress  Progress
+	Result    json.RawMessage `json:",omitempty"`
+	Err       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+type Progress struct {
+	Done    int64
+	Total   int64
+	Message string `json:",omitempty"`
 }
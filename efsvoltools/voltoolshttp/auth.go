@@ -0,0 +1,165 @@
+package voltoolshttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// Tokens are compact JWTs signed with HS256. The scope claim is a space
+// separated list of the route names (efsvoltools.OpenPermsRoute, ...) the
+// bearer may call.
+const (
+	AuthorizationHeader = "Authorization"
+	bearerPrefix        = "Bearer "
+	tokenAlgorithm      = "HS256"
+
+	// MinTokenSecretLength is the shortest shared secret accepted for signing.
+	MinTokenSecretLength = 32
+)
+
+var (
+	ErrMissingToken      = errors.New("missing bearer token")
+	ErrInvalidToken      = errors.New("invalid bearer token")
+	ErrExpiredToken      = errors.New("expired bearer token")
+	ErrInsufficientScope = errors.New("bearer token does not grant this operation")
+)
+
+type TokenClaims struct {
+	Subject   string `json:"sub,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+	Scope     string `json:"scope"`
+}
+
+type tokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+func (c TokenClaims) grants(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func SignToken(secret []byte, claims TokenClaims) (string, error) {
+	header, err := json.Marshal(tokenHeader{Algorithm: tokenAlgorithm, Type: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature(secret, signed)), nil
+}
+
+func signature(secret []byte, signed string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return mac.Sum(nil)
+}
+
+type TokenVerifier struct {
+	secret []byte
+	clock  clock.Clock
+}
+
+func NewTokenVerifier(secret []byte, clock clock.Clock) (*TokenVerifier, error) {
+	if len(secret) < MinTokenSecretLength {
+		return nil, fmt.Errorf("token secret must be at least %d bytes long", MinTokenSecretLength)
+	}
+	return &TokenVerifier{secret: secret, clock: clock}, nil
+}
+
+// Verify checks the signature and expiry of token and that it grants scope.
+// ErrInsufficientScope is returned only for otherwise valid tokens.
+func (v *TokenVerifier) Verify(token, scope string) (TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	given, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(given, signature(v.secret, parts[0]+"."+parts[1])) {
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	var header tokenHeader
+	if err := decodeTokenPart(parts[0], &header); err != nil || header.Algorithm != tokenAlgorithm {
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	var claims TokenClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil || claims.ExpiresAt == 0 {
+		return TokenClaims{}, ErrInvalidToken
+	}
+
+	if !v.clock.Now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return claims, ErrExpiredToken
+	}
+	if !claims.grants(scope) {
+		return claims, ErrInsufficientScope
+	}
+	return claims, nil
+}
+
+func decodeTokenPart(part string, into interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, into)
+}
+
+// TokenSource provides the bearer token the remote client sends with a call
+// to route.
+type TokenSource interface {
+	Token(route string) (string, error)
+}
+
+type signedTokenSource struct {
+	secret  []byte
+	subject string
+	ttl     time.Duration
+	clock   clock.Clock
+}
+
+// NewSignedTokenSource mints a short lived token for every call, scoped to
+// just the route being called.
+func NewSignedTokenSource(secret []byte, subject string, ttl time.Duration, clock clock.Clock) TokenSource {
+	return &signedTokenSource{secret: secret, subject: subject, ttl: ttl, clock: clock}
+}
+
+func (s *signedTokenSource) Token(route string) (string, error) {
+	return SignToken(s.secret, TokenClaims{
+		Subject:   s.subject,
+		ExpiresAt: s.clock.Now().Add(s.ttl).Unix(),
+		Scope:     route,
+	})
+}
+
+type staticTokenSource struct {
+	token string
+}
+
+// NewStaticTokenSource sends the same, externally issued token with every call.
+func NewStaticTokenSource(token string) TokenSource {
+	return &staticTokenSource{token}
+}
+
+func (s *staticTokenSource) Token(string) (string, error) {
+	return s.token, nil
+}
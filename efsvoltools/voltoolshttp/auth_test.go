@@ -0,0 +1,208 @@
+package voltoolshttp_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Authorization", func() {
+	var (
+		secret    []byte
+		fakeClock *fakeclock.FakeClock
+		verifier  *voltoolshttp.TokenVerifier
+	)
+
+	BeforeEach(func() {
+		secret = []byte("0123456789abcdef0123456789abcdef")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		var err error
+		verifier, err = voltoolshttp.NewTokenVerifier(secret, fakeClock)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	sign := func(claims voltoolshttp.TokenClaims) string {
+		token, err := voltoolshttp.SignToken(secret, claims)
+		Expect(err).NotTo(HaveOccurred())
+		return token
+	}
+
+	Context("#Verify", func() {
+		var claims voltoolshttp.TokenClaims
+
+		BeforeEach(func() {
+			claims = voltoolshttp.TokenClaims{
+				Subject:   "broker",
+				ExpiresAt: fakeClock.Now().Add(time.Minute).Unix(),
+				Scope:     efsvoltools.OpenPermsRoute + " " + efsvoltools.ListRoute,
+			}
+		})
+
+		It("accepts a token that grants the scope", func() {
+			verified, err := verifier.Verify(sign(claims), efsvoltools.ListRoute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verified.Subject).To(Equal("broker"))
+		})
+
+		It("refuses a scope the token does not grant", func() {
+			_, err := verifier.Verify(sign(claims), efsvoltools.CloneRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrInsufficientScope))
+		})
+
+		It("refuses an expired token", func() {
+			token := sign(claims)
+			fakeClock.Increment(time.Minute)
+
+			_, err := verifier.Verify(token, efsvoltools.OpenPermsRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrExpiredToken))
+		})
+
+		It("refuses a token without an expiry", func() {
+			claims.ExpiresAt = 0
+			_, err := verifier.Verify(sign(claims), efsvoltools.OpenPermsRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrInvalidToken))
+		})
+
+		It("refuses a token signed with another secret", func() {
+			token, err := voltoolshttp.SignToken([]byte("fedcba9876543210fedcba9876543210"), claims)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = verifier.Verify(token, efsvoltools.OpenPermsRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrInvalidToken))
+		})
+
+		It("refuses a token whose claims were changed", func() {
+			parts := strings.Split(sign(claims), ".")
+			claims.Scope = efsvoltools.CloneRoute
+			payload, err := json.Marshal(claims)
+			Expect(err).NotTo(HaveOccurred())
+			parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+			_, err = verifier.Verify(strings.Join(parts, "."), efsvoltools.CloneRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrInvalidToken))
+		})
+
+		It("refuses unsigned tokens", func() {
+			parts := strings.Split(sign(claims), ".")
+			parts[0] = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+			_, err := verifier.Verify(parts[0]+"."+parts[1]+".", efsvoltools.OpenPermsRoute)
+			Expect(err).To(Equal(voltoolshttp.ErrInvalidToken))
+		})
+
+		It("refuses short secrets", func() {
+			_, err := voltoolshttp.NewTokenVerifier([]byte("short"), fakeClock)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when serving requests", func() {
+		var (
+			fakeVolTools *efsdriverfakes.FakeVolTools
+			handler      http.Handler
+			recorder     *httptest.ResponseRecorder
+			request      *http.Request
+		)
+
+		BeforeEach(func() {
+			fakeVolTools = &efsdriverfakes.FakeVolTools{}
+
+			var err error
+			handler, err = voltoolshttp.NewHandler(lagertest.NewTestLogger("auth"), fakeVolTools, verifier)
+			Expect(err).NotTo(HaveOccurred())
+
+			recorder = httptest.NewRecorder()
+			request, err = http.NewRequest("POST", "http://0.0.0.0/EfsDriver.OpenPerms", bytes.NewBufferString(`{"Name":"some-volume"}`))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		JustBeforeEach(func() {
+			handler.ServeHTTP(recorder, request)
+		})
+
+		Context("without a token", func() {
+			It("responds 401 without calling the volume tools", func() {
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get("WWW-Authenticate")).To(Equal(`Bearer realm="efsvoltools"`))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"Err":"missing bearer token","Code":"unauthorized","Details":{"reason":"missing_token"}}`))
+				Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with an expired token", func() {
+			BeforeEach(func() {
+				token := sign(voltoolshttp.TokenClaims{ExpiresAt: fakeClock.Now().Add(-time.Second).Unix(), Scope: efsvoltools.OpenPermsRoute})
+				request.Header.Set("Authorization", "Bearer "+token)
+			})
+
+			It("responds 401", func() {
+				Expect(recorder.Code).To(Equal(http.StatusUnauthorized))
+				Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(`error="invalid_token"`))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"Err":"expired bearer token","Code":"unauthorized","Details":{"reason":"invalid_token"}}`))
+				Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with a token for another operation", func() {
+			BeforeEach(func() {
+				token := sign(voltoolshttp.TokenClaims{ExpiresAt: fakeClock.Now().Add(time.Minute).Unix(), Scope: efsvoltools.ListRoute})
+				request.Header.Set("Authorization", "Bearer "+token)
+			})
+
+			It("responds 403", func() {
+				Expect(recorder.Code).To(Equal(http.StatusForbidden))
+				Expect(recorder.Header().Get("WWW-Authenticate")).To(ContainSubstring(`error="insufficient_scope", scope="openPerms"`))
+				Expect(recorder.Body.String()).To(MatchJSON(`{"Err":"bearer token does not grant this operation","Code":"unauthorized","Details":{"reason":"insufficient_scope"}}`))
+				Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("with a valid token", func() {
+			BeforeEach(func() {
+				token := sign(voltoolshttp.TokenClaims{ExpiresAt: fakeClock.Now().Add(time.Minute).Unix(), Scope: efsvoltools.OpenPermsRoute})
+				request.Header.Set("Authorization", "Bearer "+token)
+			})
+
+			It("dispatches to the volume tools", func() {
+				Expect(recorder.Code).To(Equal(http.StatusOK))
+				Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Context("when the remote client has a token source", func() {
+		It("attaches a token scoped to each call", func() {
+			fakeVolTools := &efsdriverfakes.FakeVolTools{}
+			fakeVolTools.CapabilitiesReturns(efsvoltools.CapabilitiesResponse{APIVersion: efsvoltools.APIVersion, Operations: efsvoltools.Operations})
+			handler, err := voltoolshttp.NewHandler(lagertest.NewTestLogger("auth"), fakeVolTools, verifier)
+			Expect(err).NotTo(HaveOccurred())
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			tokens := voltoolshttp.NewSignedTokenSource(secret, "broker", time.Minute, fakeClock)
+			client := voltoolshttp.NewRemoteClientWithClient(server.URL, http.DefaultClient, fakeClock)
+			client.Tokens = tokens
+			env := driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("auth"), context.TODO())
+
+			Expect(client.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume"}).Err).To(BeEmpty())
+			Expect(client.ListDirectories(env, efsvoltools.ListDirectoriesRequest{Name: "some-volume"}).Err).To(BeEmpty())
+			Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(1))
+			Expect(fakeVolTools.ListDirectoriesCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,133 @@
+package voltoolshttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+// RemoteError is returned for every reply from the voltools server with a
+// status outside 2xx. Message, Code and Details are the error the server
+// reported, if its body carried one. Retryable follows the server when it
+// sent a Code, and the status otherwise.
+type RemoteError struct {
+	StatusCode int
+	Status     string
+	Message    string
+	Code       efsvoltools.ErrorCode
+	Details    map[string]string
+	Retryable  bool
+}
+
+func (e *RemoteError) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	return fmt.Sprintf("unexpected response status: %s", e.Status)
+}
+
+// UnsupportedOperationError is returned, without sending the request, for an
+// operation the driver has said it does not support.
+type UnsupportedOperationError struct {
+	Operation  string
+	APIVersion int
+}
+
+func (e *UnsupportedOperationError) Error() string {
+	return fmt.Sprintf("the driver does not support operation '%s' (voltools API version %d, client requires %d)", e.Operation, e.APIVersion, efsvoltools.APIVersion)
+}
+
+// MalformedResponseError is returned when a reply cannot be decoded, such as
+// an HTML error page from a proxy in front of the server.
+type MalformedResponseError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *MalformedResponseError) Error() string {
+	return fmt.Sprintf("malformed response body (status %d): %s", e.StatusCode, e.Err.Error())
+}
+
+func newRemoteError(response *http.Response, body []byte) *RemoteError {
+	status := response.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+
+	var remoteError efsvoltools.ErrorResponse
+	json.Unmarshal(body, &remoteError)
+
+	retryable := retryableStatus(response.StatusCode)
+	if remoteError.Code != "" {
+		retryable = remoteError.Retryable
+	}
+
+	return &RemoteError{
+		StatusCode: response.StatusCode,
+		Status:     status,
+		Message:    remoteError.Err,
+		Code:       remoteError.Code,
+		Details:    remoteError.Details,
+		Retryable:  retryable,
+	}
+}
+
+// errorResponse turns an error from a remote call into the ErrorResponse the
+// VolTools interface reports, keeping whatever code the server gave it.
+func errorResponse(err error) efsvoltools.ErrorResponse {
+	switch err := err.(type) {
+	case *RemoteError:
+		code := err.Code
+		if code == "" {
+			code = statusErrorCode(err.StatusCode)
+		}
+		return efsvoltools.ErrorResponse{Err: err.Error(), Code: code, Details: err.Details, Retryable: err.Retryable}
+	case *UnsupportedOperationError:
+		return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()).WithDetail("operation", err.Operation)
+	case *MalformedResponseError:
+		return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInternal, err.Error())
+	case net.Error:
+		if err.Timeout() {
+			return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeTimeout, err.Error())
+		}
+	}
+
+	if err == context.DeadlineExceeded {
+		return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeTimeout, err.Error())
+	}
+	return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInternal, err.Error())
+}
+
+// statusErrorCode is the code for a reply from a server that did not send one.
+func statusErrorCode(statusCode int) efsvoltools.ErrorCode {
+	switch statusCode {
+	case http.StatusBadRequest:
+		return efsvoltools.ErrorCodeInvalidRequest
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return efsvoltools.ErrorCodeUnauthorized
+	case http.StatusNotFound:
+		return efsvoltools.ErrorCodeNotFound
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return efsvoltools.ErrorCodeTimeout
+	}
+	return efsvoltools.ErrorCodeInternal
+}
+
+func isSuccess(statusCode int) bool {
+	return statusCode >= 200 && statusCode < 300
+}
+
+// retryableStatus reports whether the same request may succeed when sent again.
+func retryableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	case http.StatusNotImplemented:
+		return false
+	}
+	return statusCode >= http.StatusInternalServerError
+}
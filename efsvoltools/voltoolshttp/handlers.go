@@ -1,57 +1,539 @@
 package voltoolshttp
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strings"
 
 	cf_http_handlers "code.cloudfoundry.org/cfhttp/handlers"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/lager"
 	"github.com/tedsuo/rata"
 )
 
-func NewHandler(logger lager.Logger, client efsvoltools.VolTools) (http.Handler, error) {
+// NewHandler serves client over HTTP. Every request must carry a bearer token
+// accepted by verifier that grants the route being called; a nil verifier
+// turns authorization off.
+func NewHandler(logger lager.Logger, client efsvoltools.VolTools, verifier *TokenVerifier) (http.Handler, error) {
+	return NewHandlerWithJobStore(logger, client, verifier, NewJobStore(clock.NewClock(), DefaultJobTTL))
+}
+
+func NewHandlerWithJobStore(logger lager.Logger, client efsvoltools.VolTools, verifier *TokenVerifier, jobs *JobStore) (http.Handler, error) {
 	logger = logger.Session("server")
 	logger.Info("start")
 	defer logger.Info("end")
 
 	var handlers = rata.Handlers{
-		efsvoltools.OpenPermsRoute: newOpenPermsHandler(logger, client),
+		efsvoltools.OpenPermsRoute:      newOpenPermsHandler(logger, client, jobs),
+		efsvoltools.BatchOpenPermsRoute: newBatchOpenPermsHandler(logger, client, jobs),
+		efsvoltools.RepairPermsRoute:    newRepairPermsHandler(logger, client, jobs),
+		efsvoltools.ExportRoute:         newExportHandler(logger, client),
+		efsvoltools.ImportRoute:         newImportHandler(logger, client),
+		efsvoltools.CloneRoute:          newCloneDirectoryHandler(logger, client, jobs),
+		efsvoltools.ListRoute:           newListDirectoriesHandler(logger, client, jobs),
+		efsvoltools.GetACLRoute:         newGetACLHandler(logger, client, jobs),
+		efsvoltools.SetACLRoute:         newSetACLHandler(logger, client, jobs),
+		efsvoltools.GetJobRoute:         newGetJobHandler(logger, jobs),
+		efsvoltools.CancelJobRoute:      newCancelJobHandler(logger, jobs),
+		efsvoltools.CapabilitiesRoute:   newCapabilitiesHandler(logger, client),
+	}
+
+	for route, handler := range handlers {
+		handlers[route] = withDeadline(logger, handler)
+	}
+
+	if verifier != nil {
+		for route, handler := range handlers {
+			handlers[route] = authorize(logger, verifier, route, handler)
+		}
+	}
+
+	for route, handler := range handlers {
+		handlers[route] = identifyRequest(traceRequest(route, handler))
 	}
 
 	return rata.NewRouter(efsvoltools.Routes, handlers)
 }
 
-func newOpenPermsHandler(logger lager.Logger, client efsvoltools.VolTools) http.HandlerFunc {
+// identifyRequest gives every request an id, the one in the caller's
+// RequestIdHeader if it sent a usable one, and echoes it in the response.
+// Handlers add it to their log lines through requestLogger.
+func identifyRequest(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := req.Header.Get(efsvoltools.RequestIdHeader)
+		if !efsvoltools.ValidRequestId(id) {
+			id = efsvoltools.NewRequestId()
+		}
+
+		w.Header().Set(efsvoltools.RequestIdHeader, id)
+		handler.ServeHTTP(w, req.WithContext(efsvoltools.WithRequestId(req.Context(), id)))
+	})
+}
+
+// requestLogger returns logger with the id of req in its data, so that it is
+// on every line logged for the request, including by jobs it starts.
+func requestLogger(logger lager.Logger, req *http.Request) lager.Logger {
+	if id := efsvoltools.RequestId(req.Context()); id != "" {
+		return logger.WithData(lager.Data{"request-id": id})
+	}
+	return logger
+}
+
+// traceRequest serves the request in a server span, continuing the caller's
+// trace if it sent a TraceparentHeader, while tracing is enabled.
+func traceRequest(route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !efstracing.Enabled() {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		ctx := efstracing.Extract(req.Context(), req.Header)
+		ctx, span := efstracing.StartWithKind(ctx, efstracing.KindServer, "voltoolshttp."+route,
+			efstracing.String("voltools.route", route),
+			efstracing.String("voltools.request_id", efsvoltools.RequestId(req.Context())),
+		)
+		defer span.End()
+
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(recorder, req.WithContext(ctx))
+
+		span.SetAttributes(efstracing.Int("http.status_code", int64(recorder.status)))
+		if recorder.status >= http.StatusBadRequest {
+			span.RecordError(fmt.Errorf("replied %d %s", recorder.status, http.StatusText(recorder.status)))
+		}
+	})
+}
+
+// statusRecorder remembers the status a handler replied with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// authorize rejects requests without a valid token with 401, and those whose
+// token does not grant route with 403, before handler sees them.
+func authorize(logger lager.Logger, verifier *TokenVerifier, route string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("authorize", lager.Data{"route": route})
+
+		authorization := req.Header.Get(AuthorizationHeader)
+		if !strings.HasPrefix(authorization, bearerPrefix) {
+			logger.Info("missing-token")
+			w.Header().Set("WWW-Authenticate", `Bearer realm="efsvoltools"`)
+			cf_http_handlers.WriteJSONResponse(w, http.StatusUnauthorized, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeUnauthorized, ErrMissingToken.Error()).WithDetail("reason", "missing_token"))
+			return
+		}
+
+		claims, err := verifier.Verify(strings.TrimPrefix(authorization, bearerPrefix), route)
+		switch err {
+		case nil:
+			logger.Debug("authorized", lager.Data{"subject": claims.Subject})
+			handler.ServeHTTP(w, req)
+		case ErrInsufficientScope:
+			logger.Info("insufficient-scope", lager.Data{"subject": claims.Subject, "scope": claims.Scope})
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="efsvoltools", error="insufficient_scope", scope="%s"`, route))
+			cf_http_handlers.WriteJSONResponse(w, http.StatusForbidden, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeUnauthorized, err.Error()).WithDetail("reason", "insufficient_scope"))
+		default:
+			logger.Info("invalid-token", lager.Data{"reason": err.Error()})
+			w.Header().Set("WWW-Authenticate", `Bearer realm="efsvoltools", error="invalid_token"`)
+			cf_http_handlers.WriteJSONResponse(w, http.StatusUnauthorized, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeUnauthorized, err.Error()).WithDetail("reason", "invalid_token"))
+		}
+	})
+}
+
+// withDeadline bounds the request's context by the caller's DeadlineHeader,
+// so that the operation is cancelled once the caller has given up on it.
+// Operations run as jobs are not bound by it.
+func withDeadline(logger lager.Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		value := req.Header.Get(efsvoltools.DeadlineHeader)
+		if value == "" {
+			handler.ServeHTTP(w, req)
+			return
+		}
+
+		timeout, err := efsvoltools.ParseDeadline(value)
+		if err != nil {
+			requestLogger(logger, req).Error("invalid-deadline", err)
+			cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()).WithDetail("header", efsvoltools.DeadlineHeader))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+func newOpenPermsHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, req *http.Request) {
-		logger := logger.Session("handle-open-perms")
+		logger := requestLogger(logger, req).Session("handle-open-perms")
 		logger.Info("start")
 		defer logger.Info("end")
 
-		body, err := ioutil.ReadAll(req.Body)
-		if err != nil {
-			logger.Error("failed-reading-request-body", err)
-			cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.ErrorResponse{Err: err.Error()})
+		var request efsvoltools.OpenPermsRequest
+		if !readRequest(logger, w, req, &request) {
 			return
 		}
 
-		var request efsvoltools.OpenPermsRequest
-		if err = json.Unmarshal(body, &request); err != nil {
-			logger.Error("failed-unmarshalling-request-body", err)
-			cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.ErrorResponse{Err: err.Error()})
+		serveOperation(logger, w, req, jobs, efsvoltools.OpenPermsRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			openPermsResponse := client.OpenPerms(env, request)
+			if openPermsResponse.Err != "" {
+				env.Logger().Error("failed-modifying-permissions", nil, lager.Data{"volume": request.Name, "err": openPermsResponse.Err})
+			}
+			return openPermsResponse, openPermsResponse
+		})
+	}
+}
+
+func newBatchOpenPermsHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-batch-open-perms")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.BatchOpenPermsRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.BatchOpenPermsRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			batchResponse := client.BatchOpenPerms(env, request)
+			if batchResponse.Err != "" {
+				env.Logger().Error("failed-modifying-permissions", nil, lager.Data{"entries": len(request.Entries), "err": batchResponse.Err})
+			} else if batchResponse.Failed > 0 {
+				env.Logger().Info("failed-modifying-some-permissions", lager.Data{"entries": len(request.Entries), "failed": batchResponse.Failed})
+			}
+			return batchResponse, batchResponse.ErrorResponse
+		})
+	}
+}
+
+func newRepairPermsHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-repair-perms")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.RepairPermsRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.RepairPermsRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			repairPermsResponse := client.RepairPerms(env, request)
+			if repairPermsResponse.Err != "" {
+				env.Logger().Error("failed-repairing-permissions", nil, lager.Data{"volume": request.Name, "err": repairPermsResponse.Err})
+			}
+			return repairPermsResponse, repairPermsResponse.ErrorResponse
+		})
+	}
+}
+
+func newCloneDirectoryHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-clone-directory")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.CloneDirectoryRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.CloneRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			cloneResponse := client.CloneDirectory(env, request)
+			if cloneResponse.Err != "" {
+				env.Logger().Error("failed-cloning-directory", nil, lager.Data{"source": request.Source.Name, "destination": request.Destination.Name, "err": cloneResponse.Err})
+			}
+			return cloneResponse, cloneResponse.ErrorResponse
+		})
+	}
+}
+
+func newListDirectoriesHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-list-directories")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.ListDirectoriesRequest
+		if !readRequest(logger, w, req, &request) {
 			return
 		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.ListRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			listResponse := client.ListDirectories(env, request)
+			if listResponse.Err != "" {
+				env.Logger().Error("failed-listing-directories", nil, lager.Data{"volume": request.Name, "err": listResponse.Err})
+			}
+			return listResponse, listResponse.ErrorResponse
+		})
+	}
+}
+
+func newGetACLHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-get-acl")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.GetACLRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.GetACLRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			getACLResponse := client.GetACL(env, request)
+			if getACLResponse.Err != "" {
+				env.Logger().Error("failed-reading-acl", nil, lager.Data{"volume": request.Name, "err": getACLResponse.Err})
+			}
+			return getACLResponse, getACLResponse.ErrorResponse
+		})
+	}
+}
+
+func newSetACLHandler(logger lager.Logger, client efsvoltools.VolTools, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-set-acl")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.SetACLRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		serveOperation(logger, w, req, jobs, efsvoltools.SetACLRoute, func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+			setACLResponse := client.SetACL(env, request)
+			if setACLResponse.Err != "" {
+				env.Logger().Error("failed-writing-acl", nil, lager.Data{"volume": request.Name, "err": setACLResponse.Err})
+			}
+			return setACLResponse, setACLResponse
+		})
+	}
+}
+
+func newExportHandler(logger lager.Logger, client efsvoltools.VolTools) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-export")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.ExportRequest
+		if !readRequest(logger, w, req, &request) {
+			return
+		}
+
+		env := driverhttp.EnvWithMonitor(logger, req.Context(), w)
+
+		w.Header().Set("Trailer", efsvoltools.StreamErrorTrailer)
+		stream := &streamWriter{w: w}
+
+		exportResponse := client.Export(env, request, stream)
+		if exportResponse.Err != "" {
+			logger.Error("failed-exporting-volume", nil, lager.Data{"volume": request.Name, "err": exportResponse.Err})
+			if !stream.started {
+				cf_http_handlers.WriteJSONResponse(w, errorStatus(exportResponse), exportResponse)
+				return
+			}
+			w.Header().Set(efsvoltools.StreamErrorTrailer, strings.Replace(exportResponse.Err, "\n", " ", -1))
+			return
+		}
+
+		stream.start()
+	}
+}
+
+func newImportHandler(logger lager.Logger, client efsvoltools.VolTools) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-import")
+		logger.Info("start")
+		defer logger.Info("end")
+
+		var request efsvoltools.ImportRequest
+		if err := json.Unmarshal([]byte(req.Header.Get(efsvoltools.RequestHeader)), &request); err != nil {
+			logger.Error("failed-unmarshalling-request-header", err)
+			cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()))
+			return
+		}
+
 		env := driverhttp.EnvWithMonitor(logger, req.Context(), w)
 
-		openPermsResponse := client.OpenPerms(env, request)
-		if openPermsResponse.Err != "" {
-			logger.Error("failed-modifying-permissions", err, lager.Data{"volume": request.Name})
-			cf_http_handlers.WriteJSONResponse(w, http.StatusInternalServerError, openPermsResponse)
+		importResponse := client.Import(env, request, req.Body)
+		if importResponse.Err != "" {
+			logger.Error("failed-importing-volume", nil, lager.Data{"volume": request.Name, "err": importResponse.Err})
+			cf_http_handlers.WriteJSONResponse(w, errorStatus(importResponse), importResponse)
 			return
 		}
 
-		cf_http_handlers.WriteJSONResponse(w, http.StatusOK, openPermsResponse)
+		cf_http_handlers.WriteJSONResponse(w, http.StatusOK, importResponse)
+	}
+}
+
+// streamWriter only commits to a successful response once the first byte of
+// the stream is written, so that early failures can still be reported with an
+// error status.
+type streamWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (s *streamWriter) start() {
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", efsvoltools.ArchiveContentType)
+		s.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func (s *streamWriter) Write(p []byte) (int, error) {
+	s.start()
+	return s.w.Write(p)
+}
+
+func newCapabilitiesHandler(logger lager.Logger, client efsvoltools.VolTools) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-capabilities")
+		logger.Debug("start")
+		defer logger.Debug("end")
+
+		env := driverhttp.NewHttpDriverEnv(logger, req.Context())
+
+		capabilitiesResponse := client.Capabilities(env)
+		if capabilitiesResponse.Err != "" {
+			logger.Error("failed-reading-capabilities", nil, lager.Data{"err": capabilitiesResponse.Err})
+			cf_http_handlers.WriteJSONResponse(w, http.StatusInternalServerError, capabilitiesResponse)
+			return
+		}
+
+		cf_http_handlers.WriteJSONResponse(w, http.StatusOK, capabilitiesResponse)
+	}
+}
+
+func newGetJobHandler(logger lager.Logger, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-get-job")
+		id := rata.Param(req, "job_id")
+
+		job, ok := jobs.Get(id)
+		if !ok {
+			logger.Info("job-not-found", lager.Data{"job-id": id})
+			cf_http_handlers.WriteJSONResponse(w, http.StatusNotFound, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeNotFound, "job not found: "+id).WithDetail("job_id", id))
+			return
+		}
+
+		cf_http_handlers.WriteJSONResponse(w, http.StatusOK, job)
+	}
+}
+
+func newCancelJobHandler(logger lager.Logger, jobs *JobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		logger := requestLogger(logger, req).Session("handle-cancel-job")
+		id := rata.Param(req, "job_id")
+
+		job, ok := jobs.Cancel(id)
+		if !ok {
+			logger.Info("job-not-found", lager.Data{"job-id": id})
+			cf_http_handlers.WriteJSONResponse(w, http.StatusNotFound, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeNotFound, "job not found: "+id).WithDetail("job_id", id))
+			return
+		}
+
+		logger.Info("job-cancelled", lager.Data{"job-id": id})
+		cf_http_handlers.WriteJSONResponse(w, http.StatusAccepted, job)
+	}
+}
+
+func readRequest(logger lager.Logger, w http.ResponseWriter, req *http.Request, request interface{}) bool {
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		logger.Error("failed-reading-request-body", err)
+		cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()))
+		return false
+	}
+
+	if err = json.Unmarshal(body, request); err != nil {
+		logger.Error("failed-unmarshalling-request-body", err)
+		cf_http_handlers.WriteJSONResponse(w, http.StatusBadRequest, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()))
+		return false
+	}
+
+	return true
+}
+
+// serveOperation runs an operation inline, or as a background job when the
+// client prefers an asynchronous response.
+func serveOperation(logger lager.Logger, w http.ResponseWriter, req *http.Request, jobs *JobStore, operation string, run JobFunc) {
+	if prefersAsync(req) {
+		job, err := jobs.Start(logger, operation, run)
+		if err == ErrTooManyJobs {
+			logger.Info("too-many-jobs")
+			cf_http_handlers.WriteJSONResponse(w, http.StatusTooManyRequests, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeTooManyJobs, err.Error()))
+			return
+		}
+		if err != nil {
+			logger.Error("failed-starting-job", err)
+			cf_http_handlers.WriteJSONResponse(w, http.StatusInternalServerError, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInternal, err.Error()))
+			return
+		}
+
+		logger.Info("job-started", lager.Data{"job-id": job.ID})
+		if path, err := efsvoltools.Routes.CreatePathForRoute(efsvoltools.GetJobRoute, rata.Params{"job_id": job.ID}); err == nil {
+			w.Header().Set("Location", path)
+		}
+		cf_http_handlers.WriteJSONResponse(w, http.StatusAccepted, job)
+		return
+	}
+
+	env := driverhttp.EnvWithMonitor(logger, req.Context(), w)
+
+	response, errorResponse := run(env)
+	if errorResponse.Err != "" {
+		cf_http_handlers.WriteJSONResponse(w, errorStatus(errorResponse), response)
+		return
+	}
+
+	cf_http_handlers.WriteJSONResponse(w, http.StatusOK, response)
+}
+
+// errorStatus is the status a failed operation is served with. Requests that
+// cannot succeed as sent get a client error; the rest are server faults, and
+// those worth sending again are reported as unavailable.
+func errorStatus(response efsvoltools.ErrorResponse) int {
+	switch response.Code {
+	case efsvoltools.ErrorCodeInvalidRequest:
+		return http.StatusBadRequest
+	case efsvoltools.ErrorCodeNotFound:
+		return http.StatusNotFound
+	case efsvoltools.ErrorCodeUnauthorized:
+		return http.StatusForbidden
+	}
+	if response.Retryable {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func prefersAsync(req *http.Request) bool {
+	for _, value := range req.Header[efsvoltools.PreferHeader] {
+		for _, preference := range strings.Split(value, ",") {
+			if strings.TrimSpace(preference) == efsvoltools.PreferAsync {
+				return true
+			}
+		}
 	}
+	return false
 }
@@ -2,16 +2,24 @@ package voltoolshttp_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"time"
 
 	"fmt"
 
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
+	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -26,7 +34,7 @@ var _ = Describe("Volman Driver Handlers", func() {
 			By("faking out the driver")
 			voltools := &efsdriverfakes.FakeVolTools{}
 			voltools.OpenPermsReturns(efsvoltools.ErrorResponse{})
-			handler, err := voltoolshttp.NewHandler(testLogger, voltools)
+			handler, err := voltoolshttp.NewHandler(testLogger, voltools, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			By("then fake serving the response using the handler")
@@ -53,5 +61,294 @@ var _ = Describe("Volman Driver Handlers", func() {
 			Expect(response.Err).Should(BeEmpty())
 		})
 
+		Context("when the caller sends a deadline", func() {
+			var (
+				voltools    *efsdriverfakes.FakeVolTools
+				handler     http.Handler
+				httpRequest *http.Request
+			)
+
+			BeforeEach(func() {
+				var err error
+				voltools = &efsdriverfakes.FakeVolTools{}
+				handler, err = voltoolshttp.NewHandler(testLogger, voltools, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				httpRequest, err = http.NewRequest("POST", "http://0.0.0.0/EfsDriver.OpenPerms", bytes.NewBufferString(`{"Name":"some-volume"}`))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should bound the operation's context by it", func() {
+				httpRequest.Header.Set(efsvoltools.DeadlineHeader, "1500")
+				start := time.Now()
+
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Code).To(Equal(http.StatusOK))
+
+				Expect(voltools.OpenPermsCallCount()).To(Equal(1))
+				env, _ := voltools.OpenPermsArgsForCall(0)
+				deadline, ok := env.Context().Deadline()
+				Expect(ok).To(BeTrue())
+				Expect(deadline).To(BeTemporally("~", start.Add(1500*time.Millisecond), time.Second))
+			})
+
+			It("should refuse a malformed deadline", func() {
+				httpRequest.Header.Set(efsvoltools.DeadlineHeader, "soon")
+
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Code).To(Equal(http.StatusBadRequest))
+				Expect(httpResponseRecorder.Body.String()).To(ContainSubstring(`"Code":"invalid_request"`))
+				Expect(voltools.OpenPermsCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the caller sends a request id", func() {
+			var (
+				logger      *lagertest.TestLogger
+				voltools    *efsdriverfakes.FakeVolTools
+				handler     http.Handler
+				httpRequest *http.Request
+			)
+
+			BeforeEach(func() {
+				var err error
+				logger = lagertest.NewTestLogger("HandlersTest")
+				voltools = &efsdriverfakes.FakeVolTools{}
+				voltools.OpenPermsStub = func(env dockerdriver.Env, request efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
+					env.Logger().Info("opening-perms")
+					return efsvoltools.ErrorResponse{}
+				}
+				handler, err = voltoolshttp.NewHandler(logger, voltools, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				httpRequest, err = http.NewRequest("POST", "http://0.0.0.0/EfsDriver.OpenPerms", bytes.NewBufferString(`{"Name":"some-volume"}`))
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should log it on every line and echo it back", func() {
+				httpRequest.Header.Set(efsvoltools.RequestIdHeader, "some-request-id")
+
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Code).To(Equal(http.StatusOK))
+				Expect(httpResponseRecorder.Header().Get(efsvoltools.RequestIdHeader)).To(Equal("some-request-id"))
+
+				env, _ := voltools.OpenPermsArgsForCall(0)
+				Expect(efsvoltools.RequestId(env.Context())).To(Equal("some-request-id"))
+
+				var lines []lager.LogFormat
+				for _, log := range logger.Logs() {
+					if strings.Contains(log.Message, "handle-open-perms") {
+						lines = append(lines, log)
+					}
+				}
+				Expect(lines).To(HaveLen(3))
+				for _, line := range lines {
+					Expect(line.Data).To(HaveKeyWithValue("request-id", "some-request-id"))
+				}
+			})
+
+			It("should replace an unusable one with its own", func() {
+				httpRequest.Header.Set(efsvoltools.RequestIdHeader, strings.Repeat("x", 200))
+
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Header().Get(efsvoltools.RequestIdHeader)).To(MatchRegexp("^[0-9a-f]{32}$"))
+			})
+
+			It("should give one to requests that come without", func() {
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+
+				requestId := httpResponseRecorder.Header().Get(efsvoltools.RequestIdHeader)
+				Expect(requestId).To(MatchRegexp("^[0-9a-f]{32}$"))
+				Expect(logger.Logs()[len(logger.Logs())-1].Data).To(HaveKeyWithValue("request-id", requestId))
+			})
+		})
+
+		Context("when tracing is enabled", func() {
+			var (
+				fakeExporter *efsdriverfakes.FakeExporter
+				tracer       *efstracing.Tracer
+			)
+
+			BeforeEach(func() {
+				fakeExporter = &efsdriverfakes.FakeExporter{}
+				tracer = efstracing.NewTracer(clock.NewClock(), fakeExporter)
+				efstracing.SetTracer(tracer)
+			})
+
+			AfterEach(func() {
+				efstracing.SetTracer(nil)
+			})
+
+			It("should serve the request in a span continuing the caller's trace", func() {
+				voltools := &efsdriverfakes.FakeVolTools{}
+				voltools.OpenPermsReturns(efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeChmodFailed, "badness"))
+				handler, err := voltoolshttp.NewHandler(testLogger, voltools, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				httpRequest, err := http.NewRequest("POST", "http://0.0.0.0/EfsDriver.OpenPerms", bytes.NewBufferString(`{"Name":"some-volume"}`))
+				Expect(err).NotTo(HaveOccurred())
+				httpRequest.Header.Set(efstracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+				handler.ServeHTTP(httptest.NewRecorder(), httpRequest)
+
+				env, _ := voltools.OpenPermsArgsForCall(0)
+				sc, ok := efstracing.SpanContextFromContext(env.Context())
+				Expect(ok).To(BeTrue())
+				Expect(sc.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+
+				_, _, err = tracer.Flush(context.Background())
+				Expect(err).NotTo(HaveOccurred())
+				_, spans := fakeExporter.ExportArgsForCall(0)
+				Expect(spans).To(HaveLen(1))
+				Expect(spans[0].Name).To(Equal("voltoolshttp.openPerms"))
+				Expect(spans[0].Kind).To(Equal(efstracing.KindServer))
+				Expect(spans[0].SpanContext.SpanID).To(Equal(sc.SpanID))
+				Expect(spans[0].Parent.String()).To(Equal("00f067aa0ba902b7"))
+				Expect(spans[0].Attributes).To(ContainElement(efstracing.Int("http.status_code", http.StatusInternalServerError)))
+				Expect(spans[0].Failed).To(BeTrue())
+			})
+		})
+
+		Context("when the operation fails", func() {
+			var (
+				voltools *efsdriverfakes.FakeVolTools
+				handler  http.Handler
+			)
+
+			BeforeEach(func() {
+				var err error
+				voltools = &efsdriverfakes.FakeVolTools{}
+				handler, err = voltoolshttp.NewHandler(testLogger, voltools, nil)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			serve := func() *httptest.ResponseRecorder {
+				route, found := efsvoltools.Routes.FindRouteByName(efsvoltools.RepairPermsRoute)
+				Expect(found).To(BeTrue())
+				httpRequest, err := http.NewRequest("POST", "http://0.0.0.0"+route.Path, bytes.NewBufferString(`{"Name":"some-volume"}`))
+				Expect(err).NotTo(HaveOccurred())
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				return httpResponseRecorder
+			}
+
+			It("should reply with a status that matches the error code", func() {
+				voltools.RepairPermsReturnsOnCall(0, efsvoltools.RepairPermsResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, "Missing mandatory 'volume_name'")})
+				voltools.RepairPermsReturnsOnCall(1, efsvoltools.RepairPermsResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeNotFound, "no such directory")})
+				voltools.RepairPermsReturnsOnCall(2, efsvoltools.RepairPermsResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeMountFailed, "Error mounting volume: badness")})
+				voltools.RepairPermsReturnsOnCall(3, efsvoltools.RepairPermsResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInternal, "Error walking volume: badness")})
+
+				invalid := serve()
+				Expect(invalid.Code).To(Equal(http.StatusBadRequest))
+				var response efsvoltools.RepairPermsResponse
+				Expect(json.Unmarshal(invalid.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+
+				Expect(serve().Code).To(Equal(http.StatusNotFound))
+				Expect(serve().Code).To(Equal(http.StatusServiceUnavailable))
+				Expect(serve().Code).To(Equal(http.StatusInternalServerError))
+			})
+		})
+
+		Context("when the client prefers an asynchronous response", func() {
+			var (
+				voltools *efsdriverfakes.FakeVolTools
+				handler  http.Handler
+			)
+
+			BeforeEach(func() {
+				var err error
+				voltools = &efsdriverfakes.FakeVolTools{}
+				voltools.OpenPermsReturns(efsvoltools.ErrorResponse{})
+				jobs := voltoolshttp.NewJobStore(fakeclock.NewFakeClock(time.Now()), time.Minute)
+				handler, err = voltoolshttp.NewHandlerWithJobStore(testLogger, voltools, nil, jobs)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("should accept the request and run it as a job", func() {
+				route, found := efsvoltools.Routes.FindRouteByName(efsvoltools.OpenPermsRoute)
+				Expect(found).To(BeTrue())
+
+				jsonReq, err := json.Marshal(efsvoltools.OpenPermsRequest{Name: "some-volume", Opts: map[string]interface{}{"ip": "12.12.12.12"}})
+				Expect(err).NotTo(HaveOccurred())
+				httpRequest, err := http.NewRequest("POST", "http://0.0.0.0"+route.Path, bytes.NewReader(jsonReq))
+				Expect(err).NotTo(HaveOccurred())
+				httpRequest.Header.Set("Prefer", "respond-async")
+
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Code).To(Equal(http.StatusAccepted))
+
+				var job efsvoltools.Job
+				Expect(json.Unmarshal(httpResponseRecorder.Body.Bytes(), &job)).To(Succeed())
+				Expect(job.ID).NotTo(BeEmpty())
+				Expect(job.Operation).To(Equal(efsvoltools.OpenPermsRoute))
+				Expect(httpResponseRecorder.Header().Get("Location")).To(Equal("/EfsDriver.Jobs/" + job.ID))
+
+				By("then polling the job until it finishes")
+				Eventually(func() efsvoltools.JobState {
+					httpRequest, err := http.NewRequest("GET", "http://0.0.0.0/EfsDriver.Jobs/"+job.ID, nil)
+					Expect(err).NotTo(HaveOccurred())
+					httpResponseRecorder := httptest.NewRecorder()
+					handler.ServeHTTP(httpResponseRecorder, httpRequest)
+					Expect(httpResponseRecorder.Code).To(Equal(http.StatusOK))
+
+					var polled efsvoltools.Job
+					Expect(json.Unmarshal(httpResponseRecorder.Body.Bytes(), &polled)).To(Succeed())
+					return polled.State
+				}).Should(Equal(efsvoltools.JobSucceeded))
+
+				Expect(voltools.OpenPermsCallCount()).To(Equal(1))
+				_, request := voltools.OpenPermsArgsForCall(0)
+				Expect(request.Name).To(Equal("some-volume"))
+			})
+
+			It("should refuse jobs beyond the store's limit", func() {
+				release := make(chan struct{})
+				defer close(release)
+				voltools.OpenPermsStub = func(dockerdriver.Env, efsvoltools.OpenPermsRequest) efsvoltools.ErrorResponse {
+					<-release
+					return efsvoltools.ErrorResponse{}
+				}
+				jobs := voltoolshttp.NewJobStoreWithContext(context.Background(), fakeclock.NewFakeClock(time.Now()), time.Minute, 1)
+				handler, err := voltoolshttp.NewHandlerWithJobStore(testLogger, voltools, nil, jobs)
+				Expect(err).NotTo(HaveOccurred())
+
+				route, found := efsvoltools.Routes.FindRouteByName(efsvoltools.OpenPermsRoute)
+				Expect(found).To(BeTrue())
+				serve := func() *httptest.ResponseRecorder {
+					jsonReq, err := json.Marshal(efsvoltools.OpenPermsRequest{Name: "some-volume", Opts: map[string]interface{}{"ip": "12.12.12.12"}})
+					Expect(err).NotTo(HaveOccurred())
+					httpRequest, err := http.NewRequest("POST", "http://0.0.0.0"+route.Path, bytes.NewReader(jsonReq))
+					Expect(err).NotTo(HaveOccurred())
+					httpRequest.Header.Set("Prefer", "respond-async")
+					httpResponseRecorder := httptest.NewRecorder()
+					handler.ServeHTTP(httpResponseRecorder, httpRequest)
+					return httpResponseRecorder
+				}
+
+				Expect(serve().Code).To(Equal(http.StatusAccepted))
+				refused := serve()
+				Expect(refused.Code).To(Equal(http.StatusTooManyRequests))
+
+				var response efsvoltools.ErrorResponse
+				Expect(json.Unmarshal(refused.Body.Bytes(), &response)).To(Succeed())
+				Expect(response.Code).To(Equal(efsvoltools.ErrorCodeTooManyJobs))
+				Expect(response.Retryable).To(BeTrue())
+			})
+
+			It("should report unknown jobs as not found", func() {
+				httpRequest, err := http.NewRequest("GET", "http://0.0.0.0/EfsDriver.Jobs/unknown", nil)
+				Expect(err).NotTo(HaveOccurred())
+				httpResponseRecorder := httptest.NewRecorder()
+				handler.ServeHTTP(httpResponseRecorder, httpRequest)
+				Expect(httpResponseRecorder.Code).To(Equal(http.StatusNotFound))
+			})
+		})
 	})
 })
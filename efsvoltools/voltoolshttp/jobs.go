@@ -0,0 +1,211 @@
+package voltoolshttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+const DefaultJobTTL = time.Hour
+
+// DefaultMaxJobs is how many jobs may be pending or running at once.
+const DefaultMaxJobs = 16
+
+// ErrTooManyJobs is returned by Start when the store already has as many
+// unfinished jobs as it allows.
+var ErrTooManyJobs = errors.New("too many jobs in progress")
+
+// JobFunc performs an operation and returns its response along with the
+// error it reports, whose Err is empty on success.
+type JobFunc func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse)
+
+type job struct {
+	efsvoltools.Job
+	cancel context.CancelFunc
+}
+
+// JobStore keeps track of operations run in the background. Jobs stay
+// queryable for ttl after they finish and are then forgotten.
+type JobStore struct {
+	ctx     context.Context
+	clock   clock.Clock
+	ttl     time.Duration
+	maxJobs int
+
+	mutex      sync.Mutex
+	jobs       map[string]*job
+	unfinished int
+}
+
+func NewJobStore(clock clock.Clock, ttl time.Duration) *JobStore {
+	return NewJobStoreWithContext(context.Background(), clock, ttl, DefaultMaxJobs)
+}
+
+// NewJobStoreWithContext runs every job with a context derived from ctx, so
+// that cancelling ctx cancels them all, and allows at most maxJobs unfinished
+// jobs at once.
+func NewJobStoreWithContext(ctx context.Context, clock clock.Clock, ttl time.Duration, maxJobs int) *JobStore {
+	return &JobStore{
+		ctx:     ctx,
+		clock:   clock,
+		ttl:     ttl,
+		maxJobs: maxJobs,
+		jobs:    map[string]*job{},
+	}
+}
+
+func (s *JobStore) Start(logger lager.Logger, operation string, run JobFunc) (efsvoltools.Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return efsvoltools.Job{}, err
+	}
+
+	now := s.clock.Now()
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{
+		Job: efsvoltools.Job{
+			ID:        id,
+			Operation: operation,
+			State:     efsvoltools.JobPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		cancel: cancel,
+	}
+
+	s.mutex.Lock()
+	s.expire()
+	if s.unfinished >= s.maxJobs {
+		s.mutex.Unlock()
+		cancel()
+		return efsvoltools.Job{}, ErrTooManyJobs
+	}
+	s.unfinished++
+	s.jobs[id] = j
+	snapshot := j.Job
+	s.mutex.Unlock()
+
+	logger = logger.Session("job", lager.Data{"job-id": id, "operation": operation})
+	ctx = efsvoltools.WithProgress(ctx, func(progress efsvoltools.Progress) {
+		s.update(id, func(j *job) { j.Progress = progress })
+	})
+
+	go s.run(driverhttp.NewHttpDriverEnv(logger, ctx), id, run)
+
+	return snapshot, nil
+}
+
+func (s *JobStore) Get(id string) (efsvoltools.Job, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.expire()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return efsvoltools.Job{}, false
+	}
+	return j.Job, true
+}
+
+// Cancel asks a job to stop by cancelling its context. The job is reported as
+// cancelled once the operation returns.
+func (s *JobStore) Cancel(id string) (efsvoltools.Job, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.expire()
+
+	j, ok := s.jobs[id]
+	if !ok {
+		return efsvoltools.Job{}, false
+	}
+	j.cancel()
+	return j.Job, true
+}
+
+func (s *JobStore) run(env dockerdriver.Env, id string, run JobFunc) {
+	logger := env.Logger()
+	logger.Info("start")
+	defer logger.Info("end")
+
+	// A panicking operation must not take the driver down with it, nor keep
+	// holding one of the store's slots.
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Error("job-panicked", fmt.Errorf("%v", r), lager.Data{"stack": string(debug.Stack())})
+			s.update(id, func(j *job) {
+				defer j.cancel()
+				s.unfinished--
+
+				j.Err = fmt.Sprintf("Internal error: %v", r)
+				j.State = efsvoltools.JobFailed
+			})
+		}
+	}()
+
+	s.update(id, func(j *job) { j.State = efsvoltools.JobRunning })
+
+	response, errorResponse := run(env)
+	errString := errorResponse.Err
+
+	result, err := json.Marshal(response)
+	if err != nil {
+		logger.Error("failed-marshalling-result", err)
+		errString = err.Error()
+	}
+
+	s.update(id, func(j *job) {
+		defer j.cancel()
+		s.unfinished--
+
+		j.Result = result
+		j.Err = errString
+		switch {
+		case env.Context().Err() != nil:
+			j.State = efsvoltools.JobCancelled
+		case errString != "":
+			j.State = efsvoltools.JobFailed
+		default:
+			j.State = efsvoltools.JobSucceeded
+		}
+	})
+}
+
+func (s *JobStore) update(id string, fn func(*job)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if j, ok := s.jobs[id]; ok {
+		fn(j)
+		j.UpdatedAt = s.clock.Now()
+	}
+}
+
+// expire must be called with the mutex held.
+func (s *JobStore) expire() {
+	now := s.clock.Now()
+	for id, j := range s.jobs {
+		if j.State.Finished() && now.Sub(j.UpdatedAt) > s.ttl {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,196 @@
+package voltoolshttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("JobStore", func() {
+	var (
+		testLogger *lagertest.TestLogger
+		fakeClock  *fakeclock.FakeClock
+		jobs       *voltoolshttp.JobStore
+	)
+
+	BeforeEach(func() {
+		testLogger = lagertest.NewTestLogger("JobStoreTest")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		jobs = voltoolshttp.NewJobStore(fakeClock, time.Minute)
+	})
+
+	stateOf := func(id string) func() efsvoltools.JobState {
+		return func() efsvoltools.JobState {
+			job, _ := jobs.Get(id)
+			return job.State
+		}
+	}
+
+	Context("when the operation succeeds", func() {
+		It("records the result", func() {
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				return efsvoltools.ErrorResponse{}, efsvoltools.ErrorResponse{}
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.ID).NotTo(BeEmpty())
+			Expect(job.Operation).To(Equal("some-operation"))
+
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobSucceeded))
+
+			job, found := jobs.Get(job.ID)
+			Expect(found).To(BeTrue())
+			var result efsvoltools.ErrorResponse
+			Expect(json.Unmarshal(job.Result, &result)).To(Succeed())
+			Expect(result.Err).To(BeEmpty())
+		})
+	})
+
+	Context("when the operation fails", func() {
+		It("records the error", func() {
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				failure := efsvoltools.ErrorResponse{Err: "badness"}
+				return failure, failure
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobFailed))
+			job, _ = jobs.Get(job.ID)
+			Expect(job.Err).To(Equal("badness"))
+		})
+	})
+
+	Context("when the operation reports progress", func() {
+		It("exposes the progress on the job", func() {
+			release := make(chan struct{})
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: 5, Total: 10})
+				<-release
+				return efsvoltools.ErrorResponse{}, efsvoltools.ErrorResponse{}
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(func() int64 {
+				job, _ := jobs.Get(job.ID)
+				return job.Progress.Done
+			}).Should(Equal(int64(5)))
+			Expect(stateOf(job.ID)()).To(Equal(efsvoltools.JobRunning))
+
+			close(release)
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobSucceeded))
+		})
+	})
+
+	Context("when the job is cancelled", func() {
+		It("cancels the operation's context", func() {
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				<-env.Context().Done()
+				failure := efsvoltools.ErrorResponse{Err: "cancelled"}
+				return failure, failure
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, found := jobs.Cancel(job.ID)
+			Expect(found).To(BeTrue())
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobCancelled))
+		})
+
+		It("reports unknown jobs", func() {
+			_, found := jobs.Cancel("unknown")
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Context("when the store is limited", func() {
+		var (
+			ctx     context.Context
+			cancel  context.CancelFunc
+			release chan struct{}
+		)
+
+		BeforeEach(func() {
+			ctx, cancel = context.WithCancel(context.Background())
+			jobs = voltoolshttp.NewJobStoreWithContext(ctx, fakeClock, time.Minute, 1)
+			release = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			cancel()
+		})
+
+		blocking := func(release chan struct{}) voltoolshttp.JobFunc {
+			return func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				select {
+				case <-release:
+				case <-env.Context().Done():
+				}
+				return efsvoltools.ErrorResponse{}, efsvoltools.ErrorResponse{}
+			}
+		}
+
+		It("refuses jobs beyond the limit until one finishes", func() {
+			job, err := jobs.Start(testLogger, "some-operation", blocking(release))
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = jobs.Start(testLogger, "some-operation", blocking(release))
+			Expect(err).To(Equal(voltoolshttp.ErrTooManyJobs))
+
+			close(release)
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobSucceeded))
+			_, err = jobs.Start(testLogger, "some-operation", blocking(release))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("fails a panicking job, cancels it and frees its slot", func() {
+			var jobCtx context.Context
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				jobCtx = env.Context()
+				panic("badness")
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobFailed))
+			job, _ = jobs.Get(job.ID)
+			Expect(job.Err).To(Equal("Internal error: badness"))
+			Expect(jobCtx.Err()).To(Equal(context.Canceled))
+			Expect(testLogger.Buffer()).To(gbytes.Say("job-panicked"))
+
+			_, err = jobs.Start(testLogger, "some-operation", blocking(release))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("cancels every job when the store's context is cancelled", func() {
+			job, err := jobs.Start(testLogger, "some-operation", blocking(release))
+			Expect(err).NotTo(HaveOccurred())
+
+			cancel()
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobCancelled))
+		})
+	})
+
+	Context("when a finished job outlives its ttl", func() {
+		It("forgets the job", func() {
+			job, err := jobs.Start(testLogger, "some-operation", func(env dockerdriver.Env) (interface{}, efsvoltools.ErrorResponse) {
+				return efsvoltools.ErrorResponse{}, efsvoltools.ErrorResponse{}
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(stateOf(job.ID)).Should(Equal(efsvoltools.JobSucceeded))
+
+			fakeClock.Increment(30 * time.Second)
+			_, found := jobs.Get(job.ID)
+			Expect(found).To(BeTrue())
+
+			fakeClock.Increment(time.Minute)
+			_, found = jobs.Get(job.ID)
+			Expect(found).To(BeFalse())
+		})
+	})
+})
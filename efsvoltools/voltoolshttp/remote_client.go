@@ -8,6 +8,8 @@ import (
 	"net/http"
 
 	"strings"
+	"sync"
+	"time"
 
 	"fmt"
 
@@ -20,49 +22,101 @@ import (
 
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/goshims/http_wrap"
 )
 
 type reqFactory struct {
-	reqGen  *rata.RequestGenerator
-	route   string
-	payload []byte
+	reqGen     *rata.RequestGenerator
+	route      string
+	params     rata.Params
+	header     os_http.Header
+	payload    []byte
+	body       io.Reader
+	idempotent bool
 }
 
 func newReqFactory(reqGen *rata.RequestGenerator, route string, payload []byte) *reqFactory {
 	return &reqFactory{
-		reqGen:  reqGen,
-		route:   route,
-		payload: payload,
+		reqGen:     reqGen,
+		route:      route,
+		header:     os_http.Header{},
+		payload:    payload,
+		idempotent: efsvoltools.IdempotentRoutes[route],
 	}
 }
 
 func (r *reqFactory) Request() (*os_http.Request, error) {
-	return r.reqGen.CreateRequest(r.route, nil, bytes.NewBuffer(r.payload))
+	var body io.Reader = bytes.NewBuffer(r.payload)
+	if r.body != nil {
+		body = r.body
+	}
+
+	request, err := r.reqGen.CreateRequest(r.route, r.params, body)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range r.header {
+		request.Header[key] = values
+	}
+	return request, nil
 }
 
+// CapabilitiesTTL is how long the remote client trusts the capabilities a
+// driver reported before asking again, so that an upgraded driver is noticed.
+const CapabilitiesTTL = 5 * time.Minute
+
 type remoteClient struct {
-	HttpClient http_wrap.Client
-	reqGen     *rata.RequestGenerator
-	clock      clock.Clock
+	HttpClient  http_wrap.Client
+	RetryPolicy RetryPolicy
+	// NegotiateCapabilities checks every operation against the driver's
+	// capabilities before sending it.
+	NegotiateCapabilities bool
+	// Tokens, when set, provides the bearer token sent with every request.
+	Tokens TokenSource
+	reqGen *rata.RequestGenerator
+	clock  clock.Clock
+
+	capabilitiesLock    sync.Mutex
+	capabilities        *efsvoltools.CapabilitiesResponse
+	capabilitiesFetched time.Time
 }
 
 func NewRemoteClient(url string) (*remoteClient, error) {
+	return NewRemoteClientWithCredentials(url, nil, nil)
+}
+
+// NewRemoteClientWithCredentials connects to the voltools server at url. When
+// tls is given, the client presents its certificate and verifies the server
+// against the CA, as required by a server started with the efsVolTools TLS
+// flags. When tokens is given, every request carries a bearer token from it.
+func NewRemoteClientWithCredentials(url string, tls *dockerdriver.TLSConfig, tokens TokenSource) (*remoteClient, error) {
 	client := cfhttp.NewClient()
 
 	if strings.Contains(url, ".sock") {
 		client = cfhttp.NewUnixClient(url)
 		url = fmt.Sprintf("unix://%s", url)
+	} else if tls != nil {
+		tlsConfig, err := cfhttp.NewTLSConfig(tls.CertFile, tls.KeyFile, tls.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.InsecureSkipVerify = tls.InsecureSkipVerify
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
 	}
-	return NewRemoteClientWithClient(url, client, clock.NewClock()), nil
+	remoteClient := NewRemoteClientWithClient(url, client, clock.NewClock())
+	remoteClient.Tokens = tokens
+	return remoteClient, nil
 }
 
 func NewRemoteClientWithClient(socketPath string, client http_wrap.Client, clock clock.Clock) *remoteClient {
 	return &remoteClient{
-		HttpClient: client,
-		reqGen:     rata.NewRequestGenerator(socketPath, efsvoltools.Routes),
-		clock:      clock,
+		HttpClient:            client,
+		RetryPolicy:           DefaultRetryPolicy,
+		NegotiateCapabilities: true,
+		reqGen:                rata.NewRequestGenerator(socketPath, efsvoltools.Routes),
+		clock:                 clock,
 	}
 }
 
@@ -71,40 +125,434 @@ func (r *remoteClient) OpenPerms(env dockerdriver.Env, request efsvoltools.OpenP
 	logger.Info("start")
 	defer logger.Info("end")
 
+	var openPermsResponse efsvoltools.ErrorResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.OpenPermsRoute, request, &openPermsResponse); err != nil {
+		openPermsResponse = errorResponse(err)
+	}
+	return openPermsResponse
+}
+
+func (r *remoteClient) BatchOpenPerms(env dockerdriver.Env, request efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse {
+	logger := env.Logger().Session("batch-open-perms", lager.Data{"entries": len(request.Entries)})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var batchResponse efsvoltools.BatchOpenPermsResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.BatchOpenPermsRoute, request, &batchResponse); err != nil {
+		batchResponse.ErrorResponse = errorResponse(err)
+	}
+	return batchResponse
+}
+
+func (r *remoteClient) RepairPerms(env dockerdriver.Env, request efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+	logger := env.Logger().Session("repair-perms", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var repairPermsResponse efsvoltools.RepairPermsResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.RepairPermsRoute, request, &repairPermsResponse); err != nil {
+		repairPermsResponse.ErrorResponse = errorResponse(err)
+	}
+	return repairPermsResponse
+}
+
+func (r *remoteClient) CloneDirectory(env dockerdriver.Env, request efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse {
+	logger := env.Logger().Session("clone-directory", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var cloneResponse efsvoltools.CloneDirectoryResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.CloneRoute, request, &cloneResponse); err != nil {
+		cloneResponse.ErrorResponse = errorResponse(err)
+	}
+	return cloneResponse
+}
+
+func (r *remoteClient) ListDirectories(env dockerdriver.Env, request efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse {
+	logger := env.Logger().Session("list-directories", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var listResponse efsvoltools.ListDirectoriesResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.ListRoute, request, &listResponse); err != nil {
+		listResponse.ErrorResponse = errorResponse(err)
+	}
+	return listResponse
+}
+
+func (r *remoteClient) GetACL(env dockerdriver.Env, request efsvoltools.GetACLRequest) efsvoltools.GetACLResponse {
+	logger := env.Logger().Session("get-acl", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var getACLResponse efsvoltools.GetACLResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.GetACLRoute, request, &getACLResponse); err != nil {
+		getACLResponse.ErrorResponse = errorResponse(err)
+	}
+	return getACLResponse
+}
+
+func (r *remoteClient) SetACL(env dockerdriver.Env, request efsvoltools.SetACLRequest) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("set-acl", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var setACLResponse efsvoltools.ErrorResponse
+	if err := r.call(driverhttp.EnvWithLogger(logger, env), efsvoltools.SetACLRoute, request, &setACLResponse); err != nil {
+		setACLResponse = errorResponse(err)
+	}
+	return setACLResponse
+}
+
+// Export streams the archive into w as it arrives, without buffering it.
+func (r *remoteClient) Export(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("export", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
 	payload, err := json.Marshal(request)
 	if err != nil {
 		logger.Error("failed-marshalling-request", err)
-		return efsvoltools.ErrorResponse{Err: err.Error()}
+		return errorResponse(err)
 	}
 
-	httpRequest := newReqFactory(r.reqGen, efsvoltools.OpenPermsRoute, payload)
+	response, err := r.do(driverhttp.EnvWithLogger(logger, env), newReqFactory(r.reqGen, efsvoltools.ExportRoute, payload))
+	if err != nil {
+		logger.Error("failed-exporting-volume", err)
+		return errorResponse(err)
+	}
+	defer response.Body.Close()
+
+	if !isSuccess(response.StatusCode) {
+		err := readRemoteError(response)
+		logger.Error("failed-exporting-volume", err)
+		return errorResponse(err)
+	}
+
+	if _, err := io.Copy(w, response.Body); err != nil {
+		logger.Error("failed-streaming-archive", err)
+		return errorResponse(err)
+	}
+
+	if streamErr := response.Trailer.Get(efsvoltools.StreamErrorTrailer); streamErr != "" {
+		return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInternal, streamErr)
+	}
+	return efsvoltools.ErrorResponse{}
+}
+
+// Import streams the archive read from r to the driver as the request body.
+func (r *remoteClient) Import(env dockerdriver.Env, request efsvoltools.ImportRequest, archive io.Reader) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("import", lager.Data{"request": request})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		logger.Error("failed-marshalling-request", err)
+		return errorResponse(err)
+	}
+
+	httpRequest := newReqFactory(r.reqGen, efsvoltools.ImportRoute, nil)
+	httpRequest.header.Set(efsvoltools.RequestHeader, string(payload))
+	httpRequest.header.Set("Content-Type", efsvoltools.ArchiveContentType)
+	httpRequest.body = archive
 
 	response, err := r.do(driverhttp.EnvWithLogger(logger, env), httpRequest)
 	if err != nil {
-		logger.Error("failed-creating-volume", err)
-		return efsvoltools.ErrorResponse{Err: err.Error()}
+		logger.Error("failed-importing-volume", err)
+		return errorResponse(err)
+	}
+	defer response.Body.Close()
+
+	var importResponse efsvoltools.ErrorResponse
+	if err := decodeResponse(response, &importResponse); err != nil {
+		logger.Error("failed-importing-volume", err)
+		importResponse = errorResponse(err)
+	}
+	return importResponse
+}
+
+// readRemoteError builds the RemoteError for a reply outside 2xx.
+func readRemoteError(response *http.Response) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	return newRemoteError(response, body)
+}
+
+// decodeResponse decodes the body of a reply into into. Replies outside 2xx
+// are decoded as far as they can be, so partial results survive, and come
+// back as a RemoteError.
+func decodeResponse(response *http.Response, into interface{}) error {
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	if !isSuccess(response.StatusCode) {
+		json.Unmarshal(body, into)
+		return newRemoteError(response, body)
+	}
+
+	if err := json.Unmarshal(body, into); err != nil {
+		return &MalformedResponseError{StatusCode: response.StatusCode, Err: err}
+	}
+	return nil
+}
+
+// call posts request to route and decodes the reply into response. Operations
+// reply with the same body shape whether they succeeded or failed, so a failed
+// reply still fills in response before its RemoteError is returned.
+func (r *remoteClient) call(env dockerdriver.Env, route string, request interface{}, response interface{}) error {
+	logger := env.Logger()
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		logger.Error("failed-marshalling-request", err)
+		return err
+	}
+
+	httpResponse, err := r.do(env, newReqFactory(r.reqGen, route, payload))
+	if err != nil {
+		logger.Error("failed-calling-route", err, lager.Data{"route": route})
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if err := decodeResponse(httpResponse, response); err != nil {
+		logger.Error("failed-calling-route", err, lager.Data{"route": route})
+		return err
+	}
+	return nil
+}
+
+// Capabilities asks the driver which operations and mount modes it supports.
+// A driver too old to know the capabilities route is reported as supporting
+// only the legacy operations.
+func (r *remoteClient) Capabilities(env dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+	logger := env.Logger().Session("capabilities")
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	response, err := r.do(driverhttp.EnvWithLogger(logger, env), newReqFactory(r.reqGen, efsvoltools.CapabilitiesRoute, nil))
+	if err != nil {
+		logger.Error("failed-reading-capabilities", err)
+		return efsvoltools.CapabilitiesResponse{ErrorResponse: errorResponse(err)}
 	}
+	defer response.Body.Close()
 
-	if response.StatusCode == http.StatusInternalServerError {
-		var remoteError efsvoltools.ErrorResponse
-		if err := unmarshallJSON(logger, response.Body, &remoteError); err != nil {
-			logger.Error("failed-parsing-error-response", err)
-			return efsvoltools.ErrorResponse{Err: err.Error()}
+	if response.StatusCode == http.StatusNotFound {
+		logger.Info("legacy-driver")
+		return efsvoltools.CapabilitiesResponse{
+			APIVersion: efsvoltools.APIVersion,
+			Operations: efsvoltools.LegacyOperations,
 		}
-		return remoteError
 	}
 
-	return efsvoltools.ErrorResponse{}
+	var capabilitiesResponse efsvoltools.CapabilitiesResponse
+	if err := decodeResponse(response, &capabilitiesResponse); err != nil {
+		logger.Error("failed-reading-capabilities", err)
+		capabilitiesResponse.ErrorResponse = errorResponse(err)
+	}
+	return capabilitiesResponse
+}
+
+// negotiate fails when the driver is known not to support route, before the
+// request is sent. Capabilities are cached for CapabilitiesTTL.
+func (r *remoteClient) negotiate(env dockerdriver.Env, route string) error {
+	if !r.NegotiateCapabilities || !isNegotiable(route) {
+		return nil
+	}
+
+	capabilities, err := r.cachedCapabilities(env)
+	if err != nil {
+		return err
+	}
+	if capabilities.APIVersion != efsvoltools.APIVersion || !capabilities.Supports(route) {
+		return &UnsupportedOperationError{Operation: route, APIVersion: capabilities.APIVersion}
+	}
+	return nil
+}
+
+// cachedCapabilities returns the cached capabilities, asking the driver again
+// once they have expired. The lock is not held while asking, so that a slow
+// driver holds up only the requests that need its answer.
+func (r *remoteClient) cachedCapabilities(env dockerdriver.Env) (*efsvoltools.CapabilitiesResponse, error) {
+	r.capabilitiesLock.Lock()
+	capabilities, fetched := r.capabilities, r.capabilitiesFetched
+	r.capabilitiesLock.Unlock()
+	if capabilities != nil && r.clock.Since(fetched) < CapabilitiesTTL {
+		return capabilities, nil
+	}
+
+	response := r.Capabilities(env)
+	if response.Err != "" {
+		return nil, fmt.Errorf("failed negotiating capabilities with the driver: %s", response.Err)
+	}
+
+	r.capabilitiesLock.Lock()
+	defer r.capabilitiesLock.Unlock()
+	r.capabilities = &response
+	r.capabilitiesFetched = r.clock.Now()
+	return &response, nil
+}
+
+// isNegotiable reports whether route is an operation that not every driver
+// supports.
+func isNegotiable(route string) bool {
+	for _, legacy := range efsvoltools.LegacyOperations {
+		if route == legacy {
+			return false
+		}
+	}
+	for _, operation := range efsvoltools.Operations {
+		if route == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// JobClient is implemented by remote clients that can run operations as
+// background jobs on the server and poll them until they finish.
+type JobClient interface {
+	StartJob(env dockerdriver.Env, route string, request interface{}) (efsvoltools.Job, error)
+	GetJob(env dockerdriver.Env, id string) (efsvoltools.Job, error)
+	CancelJob(env dockerdriver.Env, id string) (efsvoltools.Job, error)
+	WaitForJob(env dockerdriver.Env, id string, pollInterval time.Duration) (efsvoltools.Job, error)
+	RunJob(env dockerdriver.Env, route string, request interface{}, pollInterval time.Duration) (efsvoltools.Job, error)
 }
 
-func unmarshallJSON(logger lager.Logger, reader io.ReadCloser, jsonResponse interface{}) error {
-	body, err := ioutil.ReadAll(reader)
+func (r *remoteClient) StartJob(env dockerdriver.Env, route string, request interface{}) (efsvoltools.Job, error) {
+	logger := env.Logger().Session("start-job", lager.Data{"route": route})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	payload, err := json.Marshal(request)
 	if err != nil {
-		logger.Error("Error in Reading HTTP Response body from remote.", err)
+		logger.Error("failed-marshalling-request", err)
+		return efsvoltools.Job{}, err
 	}
-	err = json.Unmarshal(body, jsonResponse)
 
-	return err
+	httpRequest := newReqFactory(r.reqGen, route, payload)
+	httpRequest.header.Set(efsvoltools.PreferHeader, efsvoltools.PreferAsync)
+	// sending it again could start the job twice
+	httpRequest.idempotent = false
+
+	return r.doJob(driverhttp.EnvWithLogger(logger, env), httpRequest, http.StatusAccepted)
+}
+
+func (r *remoteClient) GetJob(env dockerdriver.Env, id string) (efsvoltools.Job, error) {
+	logger := env.Logger().Session("get-job", lager.Data{"job-id": id})
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	httpRequest := newReqFactory(r.reqGen, efsvoltools.GetJobRoute, nil)
+	httpRequest.params = rata.Params{"job_id": id}
+
+	return r.doJob(driverhttp.EnvWithLogger(logger, env), httpRequest, http.StatusOK)
+}
+
+func (r *remoteClient) CancelJob(env dockerdriver.Env, id string) (efsvoltools.Job, error) {
+	logger := env.Logger().Session("cancel-job", lager.Data{"job-id": id})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	httpRequest := newReqFactory(r.reqGen, efsvoltools.CancelJobRoute, nil)
+	httpRequest.params = rata.Params{"job_id": id}
+
+	return r.doJob(driverhttp.EnvWithLogger(logger, env), httpRequest, http.StatusAccepted)
+}
+
+// WaitForJob polls a job every pollInterval until it has finished, or until
+// the env's context is done.
+func (r *remoteClient) WaitForJob(env dockerdriver.Env, id string, pollInterval time.Duration) (efsvoltools.Job, error) {
+	logger := env.Logger().Session("wait-for-job", lager.Data{"job-id": id})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	env = driverhttp.EnvWithLogger(logger, env)
+	for {
+		job, err := r.GetJob(env, id)
+		if err != nil {
+			return job, err
+		}
+		if job.State.Finished() {
+			logger.Info("job-finished", lager.Data{"state": job.State})
+			return job, nil
+		}
+
+		select {
+		case <-r.clock.After(pollInterval):
+		case <-env.Context().Done():
+			return job, env.Context().Err()
+		}
+	}
+}
+
+// RunJob starts an operation as a background job and waits for it to finish.
+// Starting and polling the job are sent the same request id.
+func (r *remoteClient) RunJob(env dockerdriver.Env, route string, request interface{}, pollInterval time.Duration) (efsvoltools.Job, error) {
+	env, _ = withRequestId(env)
+	job, err := r.StartJob(env, route, request)
+	if err != nil {
+		return job, err
+	}
+	return r.WaitForJob(env, job.ID, pollInterval)
+}
+
+func (r *remoteClient) doJob(env dockerdriver.Env, requestFactory *reqFactory, expectedStatus int) (efsvoltools.Job, error) {
+	logger := env.Logger()
+
+	response, err := r.do(env, requestFactory)
+	if err != nil {
+		logger.Error("failed-requesting-job", err)
+		return efsvoltools.Job{}, err
+	}
+	defer response.Body.Close()
+
+	if !isSuccess(response.StatusCode) {
+		err := readRemoteError(response)
+		logger.Error("failed-requesting-job", err)
+		return efsvoltools.Job{}, err
+	}
+	if response.StatusCode != expectedStatus {
+		// e.g. a server that ran the operation inline instead of as a job
+		err := fmt.Errorf("unexpected response status: %d, expected %d", response.StatusCode, expectedStatus)
+		logger.Error("failed-requesting-job", err)
+		return efsvoltools.Job{}, err
+	}
+
+	var job efsvoltools.Job
+	if err := decodeResponse(response, &job); err != nil {
+		logger.Error("failed-parsing-job", err)
+		return efsvoltools.Job{}, err
+	}
+	return job, nil
+}
+
+func traceResponse(span *efstracing.Span, response *os_http.Response, err error, attempts int) {
+	span.SetAttributes(efstracing.Int("voltools.attempts", int64(attempts)))
+	if err != nil {
+		span.RecordError(err)
+		return
+	}
+	span.SetAttributes(efstracing.Int("http.status_code", int64(response.StatusCode)))
+	if !isSuccess(response.StatusCode) {
+		span.RecordError(fmt.Errorf("driver replied %s", response.Status))
+	}
+}
+
+// withRequestId returns env and the request id its context carries, giving it
+// a new id if the caller did not.
+func withRequestId(env dockerdriver.Env) (dockerdriver.Env, string) {
+	id := efsvoltools.RequestId(env.Context())
+	if efsvoltools.ValidRequestId(id) {
+		return env, id
+	}
+	id = efsvoltools.NewRequestId()
+	return driverhttp.EnvWithContext(efsvoltools.WithRequestId(env.Context(), id), env), id
 }
 
 func (r *remoteClient) clientError(logger lager.Logger, err error, msg string) string {
@@ -112,27 +560,99 @@ func (r *remoteClient) clientError(logger lager.Logger, err error, msg string) s
 	return err.Error()
 }
 
-func (r *remoteClient) do(env dockerdriver.Env, requestFactory *reqFactory) (*os_http.Response, error) {
-	var (
-		response *os_http.Response
-		err      error
-		request  *os_http.Request
-	)
+// do sends the request, retrying idempotent ones according to RetryPolicy.
+// The response of the last attempt is returned, whatever its status. Every
+// attempt, and any capabilities request negotiating it, carries the same
+// request id. When tracing is enabled all of it is one client span, which the
+// driver's span for every attempt is a child of.
+func (r *remoteClient) do(env dockerdriver.Env, requestFactory *reqFactory) (response *os_http.Response, err error) {
+	ctx, span := efstracing.StartWithKind(env.Context(), efstracing.KindClient, "voltoolshttp."+requestFactory.route, efstracing.String("voltools.route", requestFactory.route))
+	if span != nil {
+		env = driverhttp.EnvWithContext(ctx, env)
+	}
+	attempt := 0
+	defer func() {
+		traceResponse(span, response, err, attempt)
+		span.End()
+	}()
+
+	identifiedEnv, requestId := withRequestId(env)
+	logger := env.Logger().Session("do", lager.Data{"route": requestFactory.route, "request-id": requestId})
+	span.SetAttributes(efstracing.String("voltools.request_id", requestId))
+
+	if err := r.negotiate(identifiedEnv, requestFactory.route); err != nil {
+		logger.Error("failed-negotiating", err)
+		return nil, err
+	}
+
+	attempts := 1
+	if requestFactory.idempotent && r.RetryPolicy.MaxAttempts > 1 {
+		attempts = r.RetryPolicy.MaxAttempts
+	}
+
+	for attempt = 1; ; attempt++ {
+		request, err := r.newRequest(env, logger, requestFactory, requestId)
+		if err != nil {
+			return nil, err
+		}
+
+		response, err := r.HttpClient.Do(request)
+		if err != nil {
+			logger.Error("request-failed", err, lager.Data{"attempt": attempt})
+		} else {
+			logger.Debug("response", lager.Data{"response": response.Status, "attempt": attempt})
+		}
+
+		if attempt == attempts || !shouldRetry(response, err) || env.Context().Err() != nil {
+			return response, err
+		}
+
+		delay := r.RetryPolicy.backoff(attempt)
+		if response != nil {
+			if wait := retryAfter(response, r.clock.Now()); wait > delay {
+				delay = wait
+			}
+		}
+		logger.Info("retrying", lager.Data{"attempt": attempt, "delay": delay.String()})
+		if response != nil {
+			io.Copy(ioutil.Discard, response.Body)
+			response.Body.Close()
+		}
 
-	logger := env.Logger().Session("do")
+		select {
+		case <-r.clock.After(delay):
+		case <-env.Context().Done():
+			return nil, env.Context().Err()
+		}
+	}
+}
 
-	request, err = requestFactory.Request()
+// newRequest builds the request for one attempt, bound to the env's context.
+// The time left until its deadline is passed on in the DeadlineHeader so the
+// driver gives up when the caller does, requestId in the RequestIdHeader, and
+// the current span in the TraceparentHeader.
+func (r *remoteClient) newRequest(env dockerdriver.Env, logger lager.Logger, requestFactory *reqFactory, requestId string) (*os_http.Request, error) {
+	request, err := requestFactory.Request()
 	if err != nil {
 		logger.Error("request-gen-failed", err)
 		return nil, err
 	}
 
-	response, err = r.HttpClient.Do(request)
-	if err != nil {
-		logger.Error("request-failed", err)
-		return response, err
+	ctx := env.Context()
+	request = request.WithContext(ctx)
+	request.Header.Set(efsvoltools.RequestIdHeader, requestId)
+	efstracing.Inject(ctx, request.Header)
+	if deadline, ok := ctx.Deadline(); ok {
+		request.Header.Set(efsvoltools.DeadlineHeader, efsvoltools.FormatDeadline(deadline.Sub(r.clock.Now())))
 	}
-	logger.Debug("response", lager.Data{"response": response.Status})
 
-	return response, nil
+	if r.Tokens != nil {
+		token, err := r.Tokens.Token(requestFactory.route)
+		if err != nil {
+			logger.Error("token-failed", err)
+			return nil, err
+		}
+		request.Header.Set(AuthorizationHeader, bearerPrefix+token)
+	}
+	return request, nil
 }
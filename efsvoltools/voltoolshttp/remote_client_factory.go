@@ -1,11 +1,15 @@
 package voltoolshttp
 
-import "code.cloudfoundry.org/efsdriver/efsvoltools"
+import (
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
 
 //go:generate counterfeiter -o ../../efsdriverfakes/fake_remote_client_factory.go . EfsRemoteClientFactory
 
 type EfsRemoteClientFactory interface {
 	NewRemoteClient(url string) (efsvoltools.VolTools, error)
+	NewRemoteClientWithCredentials(url string, tls *dockerdriver.TLSConfig, tokens TokenSource) (efsvoltools.VolTools, error)
 }
 
 func NewRemoteClientFactory() EfsRemoteClientFactory {
@@ -17,3 +21,7 @@ type remoteClientFactory struct{}
 func (_ *remoteClientFactory) NewRemoteClient(url string) (efsvoltools.VolTools, error) {
 	return NewRemoteClient(url)
 }
+
+func (_ *remoteClientFactory) NewRemoteClientWithCredentials(url string, tls *dockerdriver.TLSConfig, tokens TokenSource) (efsvoltools.VolTools, error) {
+	return NewRemoteClientWithCredentials(url, tls, tokens)
+}
@@ -1,9 +1,19 @@
 package voltoolshttp_test
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"time"
 
+	"code.cloudfoundry.org/cfhttp"
 	"code.cloudfoundry.org/clock/fakeclock"
 
 	"bytes"
@@ -12,6 +22,8 @@ import (
 
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolshttp"
 	"code.cloudfoundry.org/goshims/http_wrap/http_fake"
@@ -40,7 +52,10 @@ var _ = Describe("RemoteClient", func() {
 
 		httpClient = new(http_fake.FakeClient)
 		fakeClock = fakeclock.NewFakeClock(time.Now())
-		voltools = voltoolshttp.NewRemoteClientWithClient("http://127.0.0.1:8080", httpClient, fakeClock)
+		client := voltoolshttp.NewRemoteClientWithClient("http://127.0.0.1:8080", httpClient, fakeClock)
+		client.RetryPolicy = voltoolshttp.NoRetries
+		client.NegotiateCapabilities = false
+		voltools = client
 	})
 
 	Context("when the driver returns as error and the transport is TCP", func() {
@@ -48,7 +63,10 @@ var _ = Describe("RemoteClient", func() {
 		BeforeEach(func() {
 			fakeClock = fakeclock.NewFakeClock(time.Now())
 			httpClient = new(http_fake.FakeClient)
-			voltools = voltoolshttp.NewRemoteClientWithClient("http://127.0.0.1:8080", httpClient, fakeClock)
+			client := voltoolshttp.NewRemoteClientWithClient("http://127.0.0.1:8080", httpClient, fakeClock)
+			client.RetryPolicy = voltoolshttp.NoRetries
+			client.NegotiateCapabilities = false
+			voltools = client
 			invalidHttpResponse = &http.Response{
 				StatusCode: 500,
 				Body:       stringCloser{bytes.NewBufferString("{\"Err\":\"some error string\"}")},
@@ -79,4 +97,714 @@ var _ = Describe("RemoteClient", func() {
 			Expect(response.Err).To(Equal(""))
 		})
 	})
+
+	Context("when the caller has a deadline", func() {
+		It("should send the request with the caller's context and the time left on the client's clock", func() {
+			httpClient.DoReturns(&http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString("{}")}}, nil)
+			ctx, cancel := context.WithDeadline(testCtx, fakeClock.Now().Add(time.Minute))
+			defer cancel()
+			fakeClock.Increment(20 * time.Second)
+
+			voltools.OpenPerms(driverhttp.NewHttpDriverEnv(testLogger, ctx), efsvoltools.OpenPermsRequest{Name: "some-volume"})
+
+			request := httpClient.DoArgsForCall(0)
+			Expect(request.Context()).To(Equal(ctx))
+			remaining, err := efsvoltools.ParseDeadline(request.Header.Get(efsvoltools.DeadlineHeader))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(remaining).To(Equal(40 * time.Second))
+		})
+
+		It("should not send a deadline the caller does not have", func() {
+			httpClient.DoReturns(&http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString("{}")}}, nil)
+
+			voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(httpClient.DoArgsForCall(0).Header).NotTo(HaveKey(efsvoltools.DeadlineHeader))
+		})
+	})
+
+	Context("when sending a request id", func() {
+		BeforeEach(func() {
+			httpClient.DoReturns(&http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString("{}")}}, nil)
+		})
+
+		It("should forward the one in the caller's context", func() {
+			ctx := efsvoltools.WithRequestId(testCtx, "some-request-id")
+
+			voltools.OpenPerms(driverhttp.NewHttpDriverEnv(testLogger, ctx), efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(httpClient.DoArgsForCall(0).Header.Get(efsvoltools.RequestIdHeader)).To(Equal("some-request-id"))
+		})
+
+		It("should generate one when the caller has none", func() {
+			voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+
+			first := httpClient.DoArgsForCall(0).Header.Get(efsvoltools.RequestIdHeader)
+			Expect(first).To(MatchRegexp("^[0-9a-f]{32}$"))
+			Expect(httpClient.DoArgsForCall(1).Header.Get(efsvoltools.RequestIdHeader)).NotTo(Equal(first))
+		})
+	})
+
+	Context("when tracing is enabled", func() {
+		var (
+			fakeExporter *efsdriverfakes.FakeExporter
+			tracer       *efstracing.Tracer
+		)
+
+		BeforeEach(func() {
+			fakeExporter = &efsdriverfakes.FakeExporter{}
+			tracer = efstracing.NewTracer(fakeClock, fakeExporter)
+			efstracing.SetTracer(tracer)
+			httpClient.DoReturns(&http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString("{}")}}, nil)
+		})
+
+		AfterEach(func() {
+			efstracing.SetTracer(nil)
+		})
+
+		It("should send the request in a client span of the caller's trace", func() {
+			ctx, parent := tracer.Start(testCtx, efstracing.KindInternal, "caller")
+			voltools.OpenPerms(driverhttp.NewHttpDriverEnv(testLogger, ctx), efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			parent.End()
+
+			sc, err := efstracing.ParseTraceparent(httpClient.DoArgsForCall(0).Header.Get(efstracing.TraceparentHeader))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sc.TraceID).To(Equal(parent.SpanContext().TraceID))
+
+			_, _, err = tracer.Flush(testCtx)
+			Expect(err).NotTo(HaveOccurred())
+			_, spans := fakeExporter.ExportArgsForCall(0)
+			Expect(spans).To(HaveLen(2))
+			Expect(spans[0].Name).To(Equal("voltoolshttp.openPerms"))
+			Expect(spans[0].Kind).To(Equal(efstracing.KindClient))
+			Expect(spans[0].SpanContext.SpanID).To(Equal(sc.SpanID))
+			Expect(spans[0].Parent).To(Equal(parent.SpanContext().SpanID))
+			Expect(spans[0].Attributes).To(ContainElement(efstracing.Int("http.status_code", 200)))
+		})
+	})
+
+	Context("when the driver replies with a status outside 2xx", func() {
+		It("should report a 400 as a typed error carrying the remote message", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusBadRequest,
+				Status:     "400 Bad Request",
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"invalid character 'x'"}`)},
+			}, nil)
+
+			_, err := voltools.(voltoolshttp.JobClient).GetJob(testEnv, "some-job")
+
+			var remoteError *voltoolshttp.RemoteError
+			Expect(errors.As(err, &remoteError)).To(BeTrue())
+			Expect(remoteError.StatusCode).To(Equal(http.StatusBadRequest))
+			Expect(remoteError.Message).To(Equal("invalid character 'x'"))
+			Expect(remoteError.Retryable).To(BeFalse())
+		})
+
+		It("should not mistake a 404 for success", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Body:       stringCloser{bytes.NewBufferString("404 page not found")},
+			}, nil)
+
+			response := voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("unexpected response status: 404 Not Found"))
+		})
+
+		It("should report an HTML page from a proxy as a retryable error", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusBadGateway,
+				Body:       stringCloser{bytes.NewBufferString("<html><body>Bad Gateway</body></html>")},
+			}, nil)
+
+			_, err := voltools.(voltoolshttp.JobClient).CancelJob(testEnv, "some-job")
+
+			var remoteError *voltoolshttp.RemoteError
+			Expect(errors.As(err, &remoteError)).To(BeTrue())
+			Expect(remoteError.Retryable).To(BeTrue())
+			Expect(err).To(MatchError("unexpected response status: 502 Bad Gateway"))
+		})
+
+		It("should keep partial results reported along with the error", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       stringCloser{bytes.NewBufferString(`{"Changed":2,"Failed":1,"Err":"some entries failed"}`)},
+			}, nil)
+
+			response := voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("some entries failed"))
+			Expect(response.Changed).To(Equal(int64(2)))
+		})
+
+		It("should keep the error code and details the driver reported", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusInternalServerError,
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"Error mounting volume: timed out","Code":"mount_failed","Details":{"ip":"1.2.3.4"},"Retryable":true}`)},
+			}, nil)
+
+			response := voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("Error mounting volume: timed out"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+			Expect(response.Details).To(Equal(map[string]string{"ip": "1.2.3.4"}))
+			Expect(response.Retryable).To(BeTrue())
+		})
+
+		It("should derive a code from the status when the driver sent none", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusForbidden,
+				Status:     "403 Forbidden",
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"forbidden"}`)},
+			}, nil)
+
+			response := voltools.SetACL(testEnv, efsvoltools.SetACLRequest{Name: "some-volume"})
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeUnauthorized))
+			Expect(response.Retryable).To(BeFalse())
+		})
+
+		It("should close the response body", func() {
+			body := &closeTracker{Reader: bytes.NewBufferString(`{"Err":"some error"}`)}
+			httpClient.DoReturns(&http.Response{StatusCode: http.StatusConflict, Body: body}, nil)
+
+			voltools.SetACL(testEnv, efsvoltools.SetACLRequest{Name: "some-volume"})
+			Expect(body.closed).To(BeTrue())
+		})
+	})
+
+	Context("when the driver replies with a malformed body", func() {
+		It("should report it instead of succeeding", func() {
+			body := &closeTracker{Reader: bytes.NewBufferString("<html>maintenance</html>")}
+			httpClient.DoReturns(&http.Response{StatusCode: http.StatusOK, Body: body}, nil)
+
+			response := voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(HavePrefix("malformed response body (status 200)"))
+			Expect(body.closed).To(BeTrue())
+		})
+	})
+
+	Context("when repairing permissions", func() {
+		It("should return the counts reported by the driver", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: 200,
+				Body:       stringCloser{bytes.NewBufferString(`{"Changed":3,"Failed":1}`)},
+			}, nil)
+
+			response := voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.Changed).To(Equal(int64(3)))
+			Expect(response.Failed).To(Equal(int64(1)))
+			Expect(httpClient.DoArgsForCall(0).URL.Path).To(Equal("/EfsDriver.RepairPerms"))
+		})
+
+		It("should report the driver's error", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: 500,
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"some error string"}`)},
+			}, nil)
+
+			response := voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("some error string"))
+		})
+	})
+
+	Context("when opening permissions in a batch", func() {
+		It("should return a result for every entry", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: 200,
+				Body:       stringCloser{bytes.NewBufferString(`{"Results":[{"Err":""},{"Err":"Error mounting volume: badness","Code":"mount_failed","Retryable":true}],"Failed":1}`)},
+			}, nil)
+
+			response := voltools.BatchOpenPerms(testEnv, efsvoltools.BatchOpenPermsRequest{Entries: []efsvoltools.OpenPermsRequest{{Name: "some-volume"}, {Name: "other-volume"}}})
+
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.Failed).To(Equal(int64(1)))
+			Expect(response.Results).To(HaveLen(2))
+			Expect(response.Results[1].Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+			Expect(httpClient.DoArgsForCall(0).URL.Path).To(Equal("/EfsDriver.BatchOpenPerms"))
+		})
+	})
+
+	Context("when the driver requires mutual TLS", func() {
+		var (
+			certDir      string
+			fakeVolTools *efsdriverfakes.FakeVolTools
+			server       *httptest.Server
+		)
+
+		BeforeEach(func() {
+			var err error
+			certDir, err = ioutil.TempDir("", "voltools-tls")
+			Expect(err).NotTo(HaveOccurred())
+			writeTestCertificates(certDir)
+
+			fakeVolTools = &efsdriverfakes.FakeVolTools{}
+			handler, err := voltoolshttp.NewHandler(testLogger, fakeVolTools, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			server = httptest.NewUnstartedServer(handler)
+			server.TLS, err = cfhttp.NewTLSConfig(filepath.Join(certDir, "server-cert.pem"), filepath.Join(certDir, "server-key.pem"), filepath.Join(certDir, "ca.pem"))
+			Expect(err).NotTo(HaveOccurred())
+			server.TLS.ClientAuth = tls.RequireAndVerifyClientCert
+			server.StartTLS()
+		})
+
+		AfterEach(func() {
+			server.Close()
+			os.RemoveAll(certDir)
+		})
+
+		It("should connect with the configured client certificate", func() {
+			client, err := voltoolshttp.NewRemoteClientWithCredentials(server.URL, &dockerdriver.TLSConfig{
+				CAFile:   filepath.Join(certDir, "ca.pem"),
+				CertFile: filepath.Join(certDir, "client-cert.pem"),
+				KeyFile:  filepath.Join(certDir, "client-key.pem"),
+			}, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			response := client.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(BeEmpty())
+			Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(1))
+		})
+
+		It("should be turned away without a client certificate", func() {
+			pool := x509.NewCertPool()
+			caPEM, err := ioutil.ReadFile(filepath.Join(certDir, "ca.pem"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pool.AppendCertsFromPEM(caPEM)).To(BeTrue())
+			httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+
+			client := voltoolshttp.NewRemoteClientWithClient(server.URL, httpClient, fakeClock)
+			client.RetryPolicy = voltoolshttp.NoRetries
+			response := client.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).NotTo(BeEmpty())
+			Expect(fakeVolTools.OpenPermsCallCount()).To(Equal(0))
+		})
+
+		It("should fail to build a client from missing key files", func() {
+			_, err := voltoolshttp.NewRemoteClientWithCredentials(server.URL, &dockerdriver.TLSConfig{
+				CAFile:   filepath.Join(certDir, "ca.pem"),
+				CertFile: filepath.Join(certDir, "missing-cert.pem"),
+				KeyFile:  filepath.Join(certDir, "missing-key.pem"),
+			}, nil)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("when managing ACLs", func() {
+		It("should decode the ACEs reported by the driver", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: 200,
+				Body:       stringCloser{bytes.NewBufferString(`{"ACEs":[{"Type":"allow","Principal":"OWNER@","Permissions":["read_data"]}]}`)},
+			}, nil)
+
+			response := voltools.GetACL(testEnv, efsvoltools.GetACLRequest{Name: "some-volume", Path: "some/path"})
+
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.ACEs).To(Equal([]efsvoltools.ACE{{Type: efsvoltools.ACETypeAllow, Principal: efsvoltools.PrincipalOwner, Permissions: []string{efsvoltools.ACEPermReadData}}}))
+			Expect(httpClient.DoArgsForCall(0).URL.Path).To(Equal("/EfsDriver.GetACL"))
+		})
+
+		It("should report the driver's error when setting an ACL", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: 500,
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"Invalid ACL"}`)},
+			}, nil)
+
+			response := voltools.SetACL(testEnv, efsvoltools.SetACLRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("Invalid ACL"))
+			Expect(httpClient.DoArgsForCall(0).URL.Path).To(Equal("/EfsDriver.SetACL"))
+		})
+	})
+
+	Context("when negotiating capabilities with a driver", func() {
+		var (
+			fakeVolTools *efsdriverfakes.FakeVolTools
+			server       *httptest.Server
+		)
+
+		BeforeEach(func() {
+			fakeVolTools = &efsdriverfakes.FakeVolTools{}
+			fakeVolTools.CapabilitiesReturns(efsvoltools.CapabilitiesResponse{
+				APIVersion: efsvoltools.APIVersion,
+				Operations: []string{efsvoltools.OpenPermsRoute, efsvoltools.ListRoute},
+			})
+			handler, err := voltoolshttp.NewHandler(testLogger, fakeVolTools, nil)
+			Expect(err).NotTo(HaveOccurred())
+			server = httptest.NewServer(handler)
+			client := voltoolshttp.NewRemoteClientWithClient(server.URL, http.DefaultClient, fakeClock)
+			client.RetryPolicy = voltoolshttp.NoRetries
+			voltools = client
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should report the driver's capabilities", func() {
+			capabilities := voltools.Capabilities(testEnv)
+			Expect(capabilities.Err).To(BeEmpty())
+			Expect(capabilities.APIVersion).To(Equal(efsvoltools.APIVersion))
+			Expect(capabilities.Operations).To(Equal([]string{efsvoltools.OpenPermsRoute, efsvoltools.ListRoute}))
+		})
+
+		It("should send supported operations, asking for capabilities only once", func() {
+			Expect(voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"}).Err).To(BeEmpty())
+			Expect(voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"}).Err).To(BeEmpty())
+			Expect(fakeVolTools.ListDirectoriesCallCount()).To(Equal(2))
+			Expect(fakeVolTools.CapabilitiesCallCount()).To(Equal(1))
+		})
+
+		It("should fail fast on operations the driver does not support", func() {
+			response := voltools.SetACL(testEnv, efsvoltools.SetACLRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("the driver does not support operation 'setACL' (voltools API version 1, client requires 1)"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(fakeVolTools.SetACLCallCount()).To(Equal(0))
+
+			_, err := voltools.(voltoolshttp.JobClient).StartJob(testEnv, efsvoltools.CloneRoute, efsvoltools.CloneDirectoryRequest{})
+			Expect(err).To(BeAssignableToTypeOf(&voltoolshttp.UnsupportedOperationError{}))
+		})
+
+		It("should refuse a driver speaking another API version", func() {
+			fakeVolTools.CapabilitiesReturns(efsvoltools.CapabilitiesResponse{APIVersion: efsvoltools.APIVersion + 1, Operations: efsvoltools.Operations})
+
+			response := voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			Expect(response.Err).To(ContainSubstring("does not support operation 'listDirectories'"))
+			Expect(fakeVolTools.ListDirectoriesCallCount()).To(Equal(0))
+		})
+
+		It("should ask again once the capabilities have expired", func() {
+			voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			fakeClock.Increment(voltoolshttp.CapabilitiesTTL)
+			voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			Expect(fakeVolTools.CapabilitiesCallCount()).To(Equal(2))
+		})
+
+		It("should not hold up other requests while asking for capabilities", func() {
+			release := make(chan struct{})
+			defer close(release)
+			fakeVolTools.CapabilitiesStub = func(dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+				if fakeVolTools.CapabilitiesCallCount() == 1 {
+					<-release
+				}
+				return efsvoltools.CapabilitiesResponse{APIVersion: efsvoltools.APIVersion, Operations: efsvoltools.Operations}
+			}
+
+			go voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			Eventually(fakeVolTools.CapabilitiesCallCount).Should(Equal(1))
+
+			done := make(chan efsvoltools.ListDirectoriesResponse, 1)
+			go func() {
+				done <- voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			}()
+			Eventually(done).Should(Receive())
+			Expect(fakeVolTools.ListDirectoriesCallCount()).To(Equal(1))
+		})
+
+		It("should treat a driver without the capabilities route as supporting only the legacy operations", func() {
+			legacy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/EfsDriver.OpenPerms" {
+					http.NotFound(w, req)
+					return
+				}
+				w.Write([]byte(`{}`))
+			}))
+			defer legacy.Close()
+			client := voltoolshttp.NewRemoteClientWithClient(legacy.URL, http.DefaultClient, fakeClock)
+			client.RetryPolicy = voltoolshttp.NoRetries
+
+			Expect(client.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"}).Err).To(BeEmpty())
+			Expect(client.Capabilities(testEnv).Operations).To(Equal(efsvoltools.LegacyOperations))
+			Expect(client.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"}).Err).To(ContainSubstring("does not support operation"))
+		})
+	})
+
+	Context("when streaming archives through a driver", func() {
+		var (
+			fakeVolTools *efsdriverfakes.FakeVolTools
+			server       *httptest.Server
+		)
+
+		BeforeEach(func() {
+			fakeVolTools = &efsdriverfakes.FakeVolTools{}
+			fakeVolTools.CapabilitiesReturns(efsvoltools.CapabilitiesResponse{APIVersion: efsvoltools.APIVersion, Operations: efsvoltools.Operations})
+			handler, err := voltoolshttp.NewHandler(testLogger, fakeVolTools, nil)
+			Expect(err).NotTo(HaveOccurred())
+			server = httptest.NewServer(handler)
+			client := voltoolshttp.NewRemoteClientWithClient(server.URL, http.DefaultClient, fakeClock)
+			client.RetryPolicy = voltoolshttp.NoRetries
+			voltools = client
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should stream an export into the writer", func() {
+			fakeVolTools.ExportStub = func(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+				w.Write([]byte("some archive"))
+				return efsvoltools.ErrorResponse{}
+			}
+
+			archive := &bytes.Buffer{}
+			response := voltools.Export(testEnv, efsvoltools.ExportRequest{Name: "some-volume"}, archive)
+			Expect(response.Err).To(BeEmpty())
+			Expect(archive.String()).To(Equal("some archive"))
+		})
+
+		It("should report a failure that happens before the export starts", func() {
+			fakeVolTools.ExportReturns(efsvoltools.ErrorResponse{Err: "mount failed"})
+
+			archive := &bytes.Buffer{}
+			response := voltools.Export(testEnv, efsvoltools.ExportRequest{Name: "some-volume"}, archive)
+			Expect(response.Err).To(Equal("mount failed"))
+			Expect(archive.Len()).To(Equal(0))
+		})
+
+		It("should report a failure that happens part way through the export", func() {
+			fakeVolTools.ExportStub = func(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+				w.Write([]byte("partial"))
+				return efsvoltools.ErrorResponse{Err: "read failed"}
+			}
+
+			response := voltools.Export(testEnv, efsvoltools.ExportRequest{Name: "some-volume"}, &bytes.Buffer{})
+			Expect(response.Err).To(Equal("read failed"))
+		})
+
+		It("should stream an import from the reader", func() {
+			var received []byte
+			fakeVolTools.ImportStub = func(env dockerdriver.Env, request efsvoltools.ImportRequest, r io.Reader) efsvoltools.ErrorResponse {
+				Expect(request.Name).To(Equal("some-volume"))
+				received, _ = ioutil.ReadAll(r)
+				return efsvoltools.ErrorResponse{}
+			}
+
+			response := voltools.Import(testEnv, efsvoltools.ImportRequest{Name: "some-volume"}, bytes.NewBufferString("some archive"))
+			Expect(response.Err).To(BeEmpty())
+			Expect(string(received)).To(Equal("some archive"))
+		})
+	})
+
+	Context("when running operations as jobs", func() {
+		var jobClient voltoolshttp.JobClient
+
+		jobResponse := func(status int, state efsvoltools.JobState) *http.Response {
+			return &http.Response{
+				StatusCode: status,
+				Body:       stringCloser{bytes.NewBufferString(fmt.Sprintf(`{"ID":"some-job","State":"%s"}`, state))},
+			}
+		}
+
+		BeforeEach(func() {
+			jobClient = voltools.(voltoolshttp.JobClient)
+		})
+
+		It("should ask for an asynchronous response when starting a job", func() {
+			httpClient.DoReturns(jobResponse(http.StatusAccepted, efsvoltools.JobPending), nil)
+
+			job, err := jobClient.StartJob(testEnv, efsvoltools.OpenPermsRoute, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(job.ID).To(Equal("some-job"))
+
+			request := httpClient.DoArgsForCall(0)
+			Expect(request.URL.Path).To(Equal("/EfsDriver.OpenPerms"))
+			Expect(request.Header.Get("Prefer")).To(Equal("respond-async"))
+		})
+
+		It("should report an error when the job is unknown", func() {
+			httpClient.DoReturns(&http.Response{
+				StatusCode: http.StatusNotFound,
+				Status:     "404 Not Found",
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"job not found: some-job"}`)},
+			}, nil)
+
+			_, err := jobClient.GetJob(testEnv, "some-job")
+			Expect(err).To(MatchError("job not found: some-job"))
+		})
+
+		It("should poll the job using the clock until it finishes", func() {
+			httpClient.DoReturnsOnCall(0, jobResponse(http.StatusOK, efsvoltools.JobRunning), nil)
+			httpClient.DoReturnsOnCall(1, jobResponse(http.StatusOK, efsvoltools.JobSucceeded), nil)
+
+			done := make(chan efsvoltools.Job)
+			go func() {
+				defer GinkgoRecover()
+				job, err := jobClient.WaitForJob(testEnv, "some-job", time.Second)
+				Expect(err).NotTo(HaveOccurred())
+				done <- job
+			}()
+
+			Eventually(httpClient.DoCallCount).Should(Equal(1))
+			Expect(httpClient.DoArgsForCall(0).URL.Path).To(Equal("/EfsDriver.Jobs/some-job"))
+			Consistently(done).ShouldNot(Receive())
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			var job efsvoltools.Job
+			Eventually(done).Should(Receive(&job))
+			Expect(job.State).To(Equal(efsvoltools.JobSucceeded))
+			Expect(httpClient.DoCallCount()).To(Equal(2))
+		})
+	})
+
+	Context("when retrying failed requests", func() {
+		var client voltoolshttp.JobClient
+
+		BeforeEach(func() {
+			remoteClient := voltoolshttp.NewRemoteClientWithClient("http://127.0.0.1:8080", httpClient, fakeClock)
+			remoteClient.RetryPolicy = voltoolshttp.RetryPolicy{MaxAttempts: 3, InitialDelay: time.Second, MaxDelay: time.Minute, Multiplier: 2}
+			remoteClient.NegotiateCapabilities = false
+			voltools = remoteClient
+			client = remoteClient
+		})
+
+		unavailable := func() *http.Response {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Status: "503 Service Unavailable", Body: stringCloser{bytes.NewBufferString("")}}
+		}
+
+		It("should retry idempotent operations with exponential backoff", func() {
+			httpClient.DoReturnsOnCall(0, nil, errors.New("connection refused"))
+			httpClient.DoReturnsOnCall(1, unavailable(), nil)
+			httpClient.DoReturnsOnCall(2, &http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString(`{"Changed":1}`)}}, nil)
+
+			done := make(chan efsvoltools.RepairPermsResponse)
+			go func() {
+				defer GinkgoRecover()
+				done <- voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+			}()
+
+			Eventually(httpClient.DoCallCount).Should(Equal(1))
+			fakeClock.WaitForWatcherAndIncrement(time.Second - time.Millisecond)
+			Consistently(httpClient.DoCallCount).Should(Equal(1))
+			fakeClock.Increment(time.Millisecond)
+
+			Eventually(httpClient.DoCallCount).Should(Equal(2))
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Consistently(httpClient.DoCallCount).Should(Equal(2))
+			fakeClock.Increment(time.Second)
+
+			var response efsvoltools.RepairPermsResponse
+			Eventually(done).Should(Receive(&response))
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.Changed).To(Equal(int64(1)))
+			Expect(httpClient.DoCallCount()).To(Equal(3))
+		})
+
+		It("should give up after the configured number of attempts", func() {
+			httpClient.DoStub = func(*http.Request) (*http.Response, error) { return unavailable(), nil }
+
+			done := make(chan efsvoltools.ListDirectoriesResponse)
+			go func() {
+				defer GinkgoRecover()
+				done <- voltools.ListDirectories(testEnv, efsvoltools.ListDirectoriesRequest{Name: "some-volume"})
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+			var response efsvoltools.ListDirectoriesResponse
+			Eventually(done).Should(Receive(&response))
+			Expect(response.Err).To(ContainSubstring("503 Service Unavailable"))
+			Expect(httpClient.DoCallCount()).To(Equal(3))
+
+			By("sending every attempt the same request id")
+			requestId := httpClient.DoArgsForCall(0).Header.Get(efsvoltools.RequestIdHeader)
+			Expect(requestId).NotTo(BeEmpty())
+			Expect(httpClient.DoArgsForCall(1).Header.Get(efsvoltools.RequestIdHeader)).To(Equal(requestId))
+			Expect(httpClient.DoArgsForCall(2).Header.Get(efsvoltools.RequestIdHeader)).To(Equal(requestId))
+		})
+
+		It("should retry a driver with too many jobs once it asks to be", func() {
+			httpClient.DoReturnsOnCall(0, &http.Response{
+				StatusCode: http.StatusTooManyRequests,
+				Status:     "429 Too Many Requests",
+				Header:     http.Header{"Retry-After": []string{"5"}},
+				Body:       stringCloser{bytes.NewBufferString(`{"Err":"too many jobs in progress","Code":"too_many_jobs","Retryable":true}`)},
+			}, nil)
+			httpClient.DoReturnsOnCall(1, &http.Response{StatusCode: 200, Body: stringCloser{bytes.NewBufferString(`{"Changed":1}`)}}, nil)
+
+			done := make(chan efsvoltools.RepairPermsResponse)
+			go func() {
+				defer GinkgoRecover()
+				done <- voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+			}()
+
+			Eventually(httpClient.DoCallCount).Should(Equal(1))
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Consistently(httpClient.DoCallCount).Should(Equal(1))
+			fakeClock.Increment(4 * time.Second)
+
+			var response efsvoltools.RepairPermsResponse
+			Eventually(done).Should(Receive(&response))
+			Expect(response.Err).To(BeEmpty())
+			Expect(httpClient.DoCallCount()).To(Equal(2))
+		})
+
+		It("should not retry client errors", func() {
+			httpClient.DoReturns(&http.Response{StatusCode: http.StatusBadRequest, Status: "400 Bad Request", Body: stringCloser{bytes.NewBufferString("")}}, nil)
+
+			voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{Name: "some-volume"})
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+		})
+
+		It("should not retry invalid requests to operations with their own response type", func() {
+			httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusBadRequest,
+					Status:     "400 Bad Request",
+					Body:       stringCloser{bytes.NewBufferString(`{"Changed":0,"Err":"Missing mandatory 'volume_name'","Code":"invalid_request","Details":{"field":"volume_name"}}`)},
+				}, nil
+			}
+
+			response := voltools.RepairPerms(testEnv, efsvoltools.RepairPermsRequest{})
+			Expect(response.Err).To(Equal("Missing mandatory 'volume_name'"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(response.Details).To(Equal(map[string]string{"field": "volume_name"}))
+			Expect(response.Retryable).To(BeFalse())
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+		})
+
+		It("should not retry failures the driver reports as permanent", func() {
+			httpClient.DoStub = func(*http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusInternalServerError,
+					Body:       stringCloser{bytes.NewBufferString(`{"Err":"Error chmoding volume: read-only file system","Code":"chmod_failed"}`)},
+				}, nil
+			}
+
+			response := voltools.OpenPerms(testEnv, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			Expect(response.Err).To(Equal("Error chmoding volume: read-only file system"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeChmodFailed))
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+		})
+
+		It("should not retry operations that are not idempotent", func() {
+			httpClient.DoReturns(nil, errors.New("connection reset"))
+
+			response := voltools.Import(testEnv, efsvoltools.ImportRequest{Name: "some-volume"}, bytes.NewBufferString("archive"))
+			Expect(response.Err).To(ContainSubstring("connection reset"))
+
+			_, err := client.StartJob(testEnv, efsvoltools.CloneRoute, efsvoltools.CloneDirectoryRequest{})
+			Expect(err).To(HaveOccurred())
+			Expect(httpClient.DoCallCount()).To(Equal(2))
+		})
+
+		It("should stop waiting when the context is cancelled", func() {
+			httpClient.DoReturns(nil, errors.New("connection refused"))
+			ctx, cancel := context.WithCancel(context.Background())
+			env := driverhttp.NewHttpDriverEnv(testLogger, ctx)
+
+			done := make(chan efsvoltools.ErrorResponse)
+			go func() {
+				defer GinkgoRecover()
+				done <- voltools.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume"})
+			}()
+
+			Eventually(httpClient.DoCallCount).Should(Equal(1))
+			cancel()
+
+			var response efsvoltools.ErrorResponse
+			Eventually(done).Should(Receive(&response))
+			Expect(response.Err).To(Equal(context.Canceled.Error()))
+			Expect(httpClient.DoCallCount()).To(Equal(1))
+		})
+	})
 })
@@ -0,0 +1,94 @@
+package voltoolshttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+// RetryPolicy controls how often the remote client sends an idempotent request
+// again after a connection error or a retryable status, waiting InitialDelay
+// before the first retry and Multiplier times longer before each one after,
+// up to MaxDelay. A server asking for a longer wait with Retry-After gets it.
+type RetryPolicy struct {
+	MaxAttempts  int
+	InitialDelay time.Duration
+	MaxDelay     time.Duration
+	Multiplier   float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:  4,
+	InitialDelay: 250 * time.Millisecond,
+	MaxDelay:     5 * time.Second,
+	Multiplier:   2,
+}
+
+var NoRetries = RetryPolicy{MaxAttempts: 1}
+
+// backoff is the delay before the retry that follows attempt.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(p.InitialDelay)
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < float64(p.MaxDelay)); i++ {
+		delay *= multiplier
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+	return time.Duration(delay)
+}
+
+// shouldRetry reports whether a request should be sent again: after a
+// connection error, or a retryable status that the driver has not declared
+// permanent. Other client errors are never retried.
+func shouldRetry(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return retryableStatus(response.StatusCode) && !declinesRetry(response)
+}
+
+// retryAfter is how long the Retry-After header of response asks the client
+// to wait at now, or zero when it has none.
+func retryAfter(response *http.Response, now time.Time) time.Duration {
+	value := response.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// declinesRetry reports whether the body of response is an ErrorResponse that
+// carries a code but is not marked Retryable, meaning the server knows sending
+// the request again will fail the same way. The body is put back so it can
+// still be read.
+func declinesRetry(response *http.Response) bool {
+	body, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	var errorResponse efsvoltools.ErrorResponse
+	if json.Unmarshal(body, &errorResponse) != nil || errorResponse.Code == "" {
+		return false
+	}
+	return !errorResponse.Retryable
+}
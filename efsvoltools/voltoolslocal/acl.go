@@ -0,0 +1,69 @@
+package voltoolslocal
+
+import (
+	"fmt"
+	"os"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+func (d *EfsVolToolsLocal) GetACL(env dockerdriver.Env, request efsvoltools.GetACLRequest) efsvoltools.GetACLResponse {
+	logger := env.Logger().Session("get-acl", lager.Data{"opts": request.Opts, "path": request.Path})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	var response efsvoltools.GetACLResponse
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	response.ErrorResponse = d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if err := d.aclTarget(v.mountPath, v.root); err != nil {
+			return errorResponse(efsvoltools.ErrorCodeInvalidRequest, err, fmt.Sprintf("Error reading path '%s': %s", request.Path, err.Error())).WithDetail("field", "path")
+		}
+		aces, err := d.aclBackend.GetACL(driverhttp.EnvWithLogger(logger, env), v.root)
+		if err != nil {
+			logger.Error("get-acl-failed", err)
+			return errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error reading ACL: %s", err.Error()))
+		}
+		response.ACEs = aces
+		return efsvoltools.ErrorResponse{}
+	})
+	return response
+}
+
+func (d *EfsVolToolsLocal) SetACL(env dockerdriver.Env, request efsvoltools.SetACLRequest) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("set-acl", lager.Data{"opts": request.Opts, "path": request.Path})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	if err := efsvoltools.ValidateACL(request.ACEs); err != nil {
+		return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, fmt.Sprintf("Invalid ACL: %s", err.Error()))
+	}
+
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	return d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if err := d.aclTarget(v.mountPath, v.root); err != nil {
+			return errorResponse(efsvoltools.ErrorCodeInvalidRequest, err, fmt.Sprintf("Error reading path '%s': %s", request.Path, err.Error())).WithDetail("field", "path")
+		}
+		if err := d.aclBackend.SetACL(driverhttp.EnvWithLogger(logger, env), v.root, request.ACEs); err != nil {
+			logger.Error("set-acl-failed", err)
+			return errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error writing ACL: %s", err.Error()))
+		}
+		return efsvoltools.ErrorResponse{}
+	})
+}
+
+// aclTarget makes sure target exists and that neither it nor any directory
+// between it and mountPath is a symlink, which the ACL tools would otherwise
+// follow.
+func (d *EfsVolToolsLocal) aclTarget(mountPath, target string) error {
+	info, err := d.os.Lstat(target)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return fmt.Errorf("is a symlink")
+	}
+	return d.noSymlinksBelow(mountPath, target, false)
+}
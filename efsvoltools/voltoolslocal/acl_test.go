@@ -0,0 +1,214 @@
+package voltoolslocal_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/dockerdriverfakes"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ACLs", func() {
+	var (
+		env            dockerdriver.Env
+		fakeMounter    *volumedriverfakes.FakeMounter
+		fakeACLBackend *efsdriverfakes.FakeACLBackend
+		volTools       *voltoolslocal.EfsVolToolsLocal
+		tempDir        string
+		opts           map[string]interface{}
+		aces           []efsvoltools.ACE
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("acls"), context.TODO())
+		opts = map[string]interface{}{"ip": "1.1.1.1"}
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "acls")
+		Expect(err).NotTo(HaveOccurred())
+		keptDir := filepath.Join(tempDir, "kept")
+		Expect(os.MkdirAll(filepath.Join(keptDir, "some-volume", "shared", "x"), 0755)).To(Succeed())
+		Expect(os.Symlink("shared", filepath.Join(keptDir, "some-volume", "link"))).To(Succeed())
+		Expect(os.MkdirAll(filepath.Join(tempDir, "outside", "etc"), 0755)).To(Succeed())
+		Expect(os.Symlink(filepath.Join(tempDir, "outside"), filepath.Join(keptDir, "some-volume", "escape"))).To(Succeed())
+
+		fakeMounter = persistentFakeMounter(keptDir)
+		fakeACLBackend = &efsdriverfakes.FakeACLBackend{}
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, filepath.Join(tempDir, "mounts"), fakeMounter, fakeACLBackend)
+
+		aces = []efsvoltools.ACE{
+			{Type: efsvoltools.ACETypeAllow, Principal: efsvoltools.PrincipalOwner, Permissions: []string{efsvoltools.ACEPermReadData, efsvoltools.ACEPermWriteData}},
+			{Type: efsvoltools.ACETypeAllow, Flags: []string{efsvoltools.ACEFlagGroup}, Principal: "1000", Permissions: []string{efsvoltools.ACEPermReadData}},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	Context("#GetACL", func() {
+		It("reads the ACL of the path inside the mounted volume", func() {
+			fakeACLBackend.GetACLReturns(aces, nil)
+
+			response := volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts, Path: "shared"})
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.ACEs).To(Equal(aces))
+
+			Expect(fakeACLBackend.GetACLCallCount()).To(Equal(1))
+			_, path := fakeACLBackend.GetACLArgsForCall(0)
+			Expect(path).To(MatchRegexp("^" + filepath.Join(tempDir, "mounts", "some-volume") + "-[0-9a-f]{16}/shared$"))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+
+		It("reports backend failures and still unmounts", func() {
+			fakeACLBackend.GetACLReturns(nil, errors.New("badness"))
+
+			response := volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts, Path: "shared"})
+			Expect(response.Err).To(Equal("Error reading ACL: badness"))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+
+		It("refuses symlinks", func() {
+			response := volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts, Path: "link"})
+			Expect(response.Err).To(ContainSubstring("is a symlink"))
+			Expect(fakeACLBackend.GetACLCallCount()).To(Equal(0))
+		})
+
+		It("refuses paths through a symlinked directory", func() {
+			response := volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts, Path: "link/x"})
+			Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(fakeACLBackend.GetACLCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("#SetACL", func() {
+		It("hands the ACEs to the backend", func() {
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "shared", ACEs: aces})
+			Expect(response.Err).To(BeEmpty())
+
+			Expect(fakeACLBackend.SetACLCallCount()).To(Equal(1))
+			_, path, applied := fakeACLBackend.SetACLArgsForCall(0)
+			Expect(path).To(MatchRegexp("^" + filepath.Join(tempDir, "mounts", "some-volume") + "-[0-9a-f]{16}/shared$"))
+			Expect(applied).To(Equal(aces))
+		})
+
+		It("validates the ACL before mounting anything", func() {
+			aces[1].Permissions = []string{"everything"}
+
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "shared", ACEs: aces})
+			Expect(response.Err).To(Equal("Invalid ACL: ACE 1: invalid ACE permission 'everything'"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(fakeMounter.MountCallCount()).To(Equal(0))
+			Expect(fakeACLBackend.SetACLCallCount()).To(Equal(0))
+		})
+
+		It("reports a missing path as not found", func() {
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "missing", ACEs: aces})
+			Expect(response.Err).To(HavePrefix("Error reading path 'missing'"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeNotFound))
+			Expect(response.Details).To(Equal(map[string]string{"field": "path"}))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+
+		It("refuses paths through a symlink leading out of the volume", func() {
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "escape/etc", ACEs: aces})
+			Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(response.Details).To(Equal(map[string]string{"field": "path"}))
+			Expect(fakeACLBackend.SetACLCallCount()).To(Equal(0))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+
+		It("refuses an empty ACL", func() {
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "shared"})
+			Expect(response.Err).To(ContainSubstring("at least one ACE"))
+		})
+
+		It("refuses GROUP@ without the group flag", func() {
+			aces[1].Principal = efsvoltools.PrincipalGroup
+			aces[1].Flags = nil
+
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "shared", ACEs: aces})
+			Expect(response.Err).To(ContainSubstring("requires the 'group' flag"))
+		})
+
+		It("refuses principals that would break the ACL spec", func() {
+			aces[1].Principal = "1000,A::EVERYONE@"
+
+			response := volTools.SetACL(env, efsvoltools.SetACLRequest{Name: "some-volume", Opts: opts, Path: "shared", ACEs: aces})
+			Expect(response.Err).To(ContainSubstring("invalid character"))
+		})
+	})
+
+	Context("the nfs4 backend", func() {
+		var (
+			fakeInvoker *dockerdriverfakes.FakeInvoker
+			backend     voltoolslocal.ACLBackend
+		)
+
+		BeforeEach(func() {
+			fakeInvoker = &dockerdriverfakes.FakeInvoker{}
+			backend = voltoolslocal.NewNfs4ACLBackend(fakeInvoker)
+		})
+
+		It("parses the output of nfs4_getfacl", func() {
+			fakeInvoker.InvokeReturns([]byte("# file: /some/path\nA::OWNER@:rwatTnNcCy\nA:g:GROUP@:rtncy\nD:fd:someone@example.com:w\n"), nil)
+
+			parsed, err := backend.GetACL(env, "/some/path")
+			Expect(err).NotTo(HaveOccurred())
+
+			_, cmd, args := fakeInvoker.InvokeArgsForCall(0)
+			Expect(cmd).To(Equal("nfs4_getfacl"))
+			Expect(args).To(Equal([]string{"/some/path"}))
+
+			Expect(parsed).To(HaveLen(3))
+			Expect(parsed[1]).To(Equal(efsvoltools.ACE{
+				Type:        efsvoltools.ACETypeAllow,
+				Flags:       []string{efsvoltools.ACEFlagGroup},
+				Principal:   efsvoltools.PrincipalGroup,
+				Permissions: []string{efsvoltools.ACEPermReadData, efsvoltools.ACEPermReadAttributes, efsvoltools.ACEPermReadNamedAttrs, efsvoltools.ACEPermReadACL, efsvoltools.ACEPermSynchronize},
+			}))
+			Expect(parsed[2].Type).To(Equal(efsvoltools.ACETypeDeny))
+			Expect(parsed[2].Flags).To(Equal([]string{efsvoltools.ACEFlagFileInherit, efsvoltools.ACEFlagDirectoryInherit}))
+			Expect(parsed[2].Principal).To(Equal("someone@example.com"))
+		})
+
+		It("reports output it cannot parse", func() {
+			fakeInvoker.InvokeReturns([]byte("A::OWNER@:rq\n"), nil)
+
+			_, err := backend.GetACL(env, "/some/path")
+			Expect(err).To(MatchError("unknown code 'q' in ACE 'A::OWNER@:rq'"))
+		})
+
+		It("writes the whole ACL with nfs4_setfacl", func() {
+			err := backend.SetACL(env, "/some/path", aces)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, cmd, args := fakeInvoker.InvokeArgsForCall(0)
+			Expect(cmd).To(Equal("nfs4_setfacl"))
+			Expect(args).To(Equal([]string{"-s", "A::OWNER@:rw,A:g:1000:r", "/some/path"}))
+		})
+
+		It("includes the tool's output in errors", func() {
+			fakeInvoker.InvokeReturns([]byte("Operation to request attribute not supported\n"), errors.New("exit status 1"))
+
+			err := backend.SetACL(env, "/some/path", aces)
+			Expect(err).To(MatchError("nfs4_setfacl failed: exit status 1 (Operation to request attribute not supported)"))
+		})
+	})
+})
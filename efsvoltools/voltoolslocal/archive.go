@@ -0,0 +1,315 @@
+package voltoolslocal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+// Export writes the directory at request.Path as a gzipped tarball to w,
+// preserving modes, ownership and symlinks.
+func (d *EfsVolToolsLocal) Export(env dockerdriver.Env, request efsvoltools.ExportRequest, w io.Writer) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("export", lager.Data{"opts": request.Opts, "path": request.Path})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	return d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), v, v.root, false); refused.Err != "" {
+			return refused
+		}
+		if err := d.writeArchive(driverhttp.EnvWithLogger(logger, env), v.root, w); err != nil {
+			logger.Error("export-failed", err)
+			return errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error exporting volume: %s", err.Error()))
+		}
+		return efsvoltools.ErrorResponse{}
+	})
+}
+
+// Import unpacks a gzipped tarball read from r into the directory at
+// request.Path. Entries that would land outside that directory, either through
+// their name or through a symlink already on disk, are refused.
+func (d *EfsVolToolsLocal) Import(env dockerdriver.Env, request efsvoltools.ImportRequest, r io.Reader) efsvoltools.ErrorResponse {
+	logger := env.Logger().Session("import", lager.Data{"opts": request.Opts, "path": request.Path})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	return d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), v, v.root, true); refused.Err != "" {
+			return refused
+		}
+		if err := d.readArchive(driverhttp.EnvWithLogger(logger, env), v.root, r); err != nil {
+			logger.Error("import-failed", err)
+			return errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error importing volume: %s", err.Error()))
+		}
+		return efsvoltools.ErrorResponse{}
+	})
+}
+
+func (d *EfsVolToolsLocal) writeArchive(env dockerdriver.Env, root string, w io.Writer) error {
+	logger := env.Logger().Session("write-archive", lager.Data{"root": root})
+	ctx := env.Context()
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	var entries int64
+	err := d.filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		relative, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		link := ""
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = d.os.Readlink(path); err != nil {
+				return err
+			}
+		case info.IsDir(), info.Mode().IsRegular():
+		default:
+			logger.Info("skipping-special-file", lager.Data{"path": path, "mode": info.Mode().String()})
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relative)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		header.Uname, header.Gname = "", ""
+
+		if err := tarWriter.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			if err := d.copyFrom(path, tarWriter); err != nil {
+				return err
+			}
+		}
+
+		entries++
+		if entries%progressInterval == 0 {
+			efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: entries})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("archive-written", lager.Data{"entries": entries})
+	return nil
+}
+
+func (d *EfsVolToolsLocal) copyFrom(path string, w io.Writer) error {
+	file, err := d.os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (d *EfsVolToolsLocal) readArchive(env dockerdriver.Env, root string, r io.Reader) error {
+	logger := env.Logger().Session("read-archive", lager.Data{"root": root})
+	ctx := env.Context()
+
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+	tarReader := tar.NewReader(gzipReader)
+
+	// directory modes are applied last so that read-only directories can
+	// still be filled in. Until then nothing may replace them, or the modes
+	// would be applied to whatever took their place.
+	type dirEntry struct {
+		target string
+		header *tar.Header
+	}
+	var dirs []dirEntry
+	dirTargets := map[string]bool{}
+	var entries int64
+	for {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		target, err := d.archiveTarget(root, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := d.os.Mkdir(target, 0700); err != nil && !os.IsExist(err) {
+				return err
+			}
+			if info, err := d.os.Lstat(target); err != nil {
+				return err
+			} else if !info.IsDir() {
+				return fmt.Errorf("Refusing archive entry '%s': a non-directory is in the way", header.Name)
+			}
+			dirs = append(dirs, dirEntry{target: target, header: header})
+			dirTargets[target] = true
+			continue
+		case tar.TypeReg:
+			if err := d.writeFile(target, header, tarReader); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if dirTargets[target] {
+				return fmt.Errorf("Refusing archive entry '%s': it would replace a directory from the same archive", header.Name)
+			}
+			if err := d.os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			if err := d.os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+			if err := d.os.Lchown(target, header.Uid, header.Gid); err != nil {
+				return err
+			}
+		default:
+			logger.Info("skipping-unsupported-entry", lager.Data{"name": header.Name, "type": string(header.Typeflag)})
+			continue
+		}
+
+		entries++
+		if entries%progressInterval == 0 {
+			efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: entries})
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := d.applyDirHeader(dirs[i].target, dirs[i].header); err != nil {
+			return err
+		}
+		entries++
+	}
+
+	logger.Info("archive-read", lager.Data{"entries": entries})
+	return nil
+}
+
+func (d *EfsVolToolsLocal) writeFile(target string, header *tar.Header, r io.Reader) error {
+	file, err := d.os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := applyHeader(file, header); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// applyDirHeader applies header to the directory at target, refusing to
+// follow a symlink that has taken its place.
+func (d *EfsVolToolsLocal) applyDirHeader(target string, header *tar.Header) error {
+	dir, err := d.os.OpenFile(target, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_DIRECTORY, 0)
+	if err != nil {
+		return fmt.Errorf("Refusing archive entry '%s': no longer a directory: %s", header.Name, err.Error())
+	}
+	if err := applyHeader(dir, header); err != nil {
+		dir.Close()
+		return err
+	}
+	return dir.Close()
+}
+
+// applyHeader sets the ownership, mode and modification time of header on an
+// open file, so that no path is resolved again on the way.
+func applyHeader(file *os.File, header *tar.Header) error {
+	if err := file.Chown(header.Uid, header.Gid); err != nil {
+		return err
+	}
+	if err := file.Chmod(header.FileInfo().Mode() & permBits); err != nil {
+		return err
+	}
+	modTime := syscall.NsecToTimeval(header.ModTime.UnixNano())
+	return syscall.Futimes(int(file.Fd()), []syscall.Timeval{modTime, modTime})
+}
+
+// archiveTarget maps an archive entry name onto the disk below root. The name
+// must be relative and stay inside root, and no directory on the way to it may
+// be a symlink, since following one could write outside of root.
+func (d *EfsVolToolsLocal) archiveTarget(root, name string) (string, error) {
+	if filepath.IsAbs(name) || strings.ContainsRune(name, 0) {
+		return "", fmt.Errorf("Refusing archive entry '%s': path must be relative", name)
+	}
+
+	cleaned := filepath.Clean(filepath.FromSlash(name))
+	if cleaned == "." {
+		return root, nil
+	}
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("Refusing archive entry '%s': path escapes the target directory", name)
+	}
+
+	target := root
+	elements := strings.Split(cleaned, string(filepath.Separator))
+	for _, element := range elements[:len(elements)-1] {
+		target = filepath.Join(target, element)
+		info, err := d.os.Lstat(target)
+		if os.IsNotExist(err) {
+			if err := d.os.Mkdir(target, 0755); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("Refusing archive entry '%s': path traverses a symlink", name)
+		}
+	}
+
+	return filepath.Join(root, cleaned), nil
+}
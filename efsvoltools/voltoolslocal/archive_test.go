@@ -0,0 +1,183 @@
+package voltoolslocal_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Archives", func() {
+	var (
+		env         dockerdriver.Env
+		fakeMounter *volumedriverfakes.FakeMounter
+		volTools    *voltoolslocal.EfsVolToolsLocal
+		tempDir     string
+		keptDir     string
+		opts        map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("archives"), context.TODO())
+		opts = map[string]interface{}{"ip": "1.1.1.1"}
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "archives")
+		Expect(err).NotTo(HaveOccurred())
+		keptDir = filepath.Join(tempDir, "kept")
+		Expect(os.MkdirAll(keptDir, 0755)).To(Succeed())
+
+		// the real file system stands in for EFS.
+		fakeMounter = persistentFakeMounter(keptDir)
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, filepath.Join(tempDir, "mounts"), fakeMounter, &efsdriverfakes.FakeACLBackend{})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	It("round trips a directory with its modes, ownership and symlinks", func() {
+		source := filepath.Join(keptDir, "source-volume", "instance")
+		Expect(os.MkdirAll(filepath.Join(source, "sub"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(source, "sub", "data"), []byte("some data"), 0600)).To(Succeed())
+		Expect(os.Chmod(filepath.Join(source, "sub"), 0750)).To(Succeed())
+		Expect(os.Symlink("sub/data", filepath.Join(source, "link"))).To(Succeed())
+		Expect(os.Lchown(filepath.Join(source, "sub", "data"), os.Getuid(), os.Getgid())).To(Succeed())
+
+		archive := &bytes.Buffer{}
+		response := volTools.Export(env, efsvoltools.ExportRequest{Name: "source-volume", Opts: opts, Path: "instance"}, archive)
+		Expect(response.Err).To(BeEmpty())
+
+		response = volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "copy"}, archive)
+		Expect(response.Err).To(BeEmpty())
+
+		restored := filepath.Join(keptDir, "target-volume")
+		data, err := ioutil.ReadFile(filepath.Join(restored, "copy", "sub", "data"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("some data"))
+
+		info, err := os.Stat(filepath.Join(restored, "copy", "sub", "data"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+
+		info, err = os.Stat(filepath.Join(restored, "copy", "sub"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0750)))
+
+		link, err := os.Readlink(filepath.Join(restored, "copy", "link"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal("sub/data"))
+	})
+
+	Context("when importing a hostile archive", func() {
+		var archive *bytes.Buffer
+
+		writeArchive := func(headers ...*tar.Header) *bytes.Buffer {
+			buffer := &bytes.Buffer{}
+			gzipWriter := gzip.NewWriter(buffer)
+			tarWriter := tar.NewWriter(gzipWriter)
+			for _, header := range headers {
+				Expect(tarWriter.WriteHeader(header)).To(Succeed())
+				if header.Typeflag == tar.TypeReg {
+					_, err := tarWriter.Write(make([]byte, header.Size))
+					Expect(err).NotTo(HaveOccurred())
+				}
+			}
+			Expect(tarWriter.Close()).To(Succeed())
+			Expect(gzipWriter.Close()).To(Succeed())
+			return buffer
+		}
+
+		It("refuses entries that climb out of the target directory", func() {
+			archive = writeArchive(&tar.Header{Name: "../../escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+
+			response := volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "copy"}, archive)
+			Expect(response.Err).To(ContainSubstring("escapes the target directory"))
+			Expect(filepath.Join(tempDir, "mounts", "escaped")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses absolute entries", func() {
+			archive = writeArchive(&tar.Header{Name: "/etc/escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+
+			response := volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "copy"}, archive)
+			Expect(response.Err).To(ContainSubstring("must be relative"))
+		})
+
+		It("refuses entries written through a symlink from the same archive", func() {
+			outside := filepath.Join(tempDir, "outside")
+			Expect(os.MkdirAll(outside, 0755)).To(Succeed())
+			archive = writeArchive(
+				&tar.Header{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside},
+				&tar.Header{Name: "evil/escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1},
+			)
+
+			response := volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "copy"}, archive)
+			Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+			Expect(filepath.Join(outside, "escaped")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses a symlink replacing a directory from the same archive", func() {
+			outside := filepath.Join(tempDir, "outside")
+			Expect(os.MkdirAll(outside, 0755)).To(Succeed())
+			archive = writeArchive(
+				&tar.Header{Name: "a/", Typeflag: tar.TypeDir, Mode: 0777},
+				&tar.Header{Name: "a", Typeflag: tar.TypeSymlink, Linkname: outside},
+			)
+
+			response := volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "copy"}, archive)
+			Expect(response.Err).To(ContainSubstring("would replace a directory"))
+
+			info, err := os.Stat(outside)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0755)))
+		})
+
+		It("refuses an import path that traverses a symlink already on the volume", func() {
+			outside := filepath.Join(tempDir, "outside")
+			Expect(os.MkdirAll(outside, 0755)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(keptDir, "target-volume"), 0755)).To(Succeed())
+			Expect(os.Symlink(outside, filepath.Join(keptDir, "target-volume", "planted"))).To(Succeed())
+			archive = writeArchive(&tar.Header{Name: "escaped", Typeflag: tar.TypeReg, Mode: 0644, Size: 1})
+
+			response := volTools.Import(env, efsvoltools.ImportRequest{Name: "target-volume", Opts: opts, Path: "planted/copy"}, archive)
+			Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(filepath.Join(outside, "copy")).NotTo(BeAnExistingFile())
+		})
+
+		It("refuses an export path that is a symlink already on the volume", func() {
+			outside := filepath.Join(tempDir, "outside")
+			Expect(os.MkdirAll(outside, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0600)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(keptDir, "source-volume"), 0755)).To(Succeed())
+			Expect(os.Symlink(outside, filepath.Join(keptDir, "source-volume", "planted"))).To(Succeed())
+
+			archive := &bytes.Buffer{}
+			response := volTools.Export(env, efsvoltools.ExportRequest{Name: "source-volume", Opts: opts, Path: "planted"}, archive)
+			Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+			Expect(archive.Len()).To(BeZero())
+		})
+
+		It("refuses an export path outside the volume", func() {
+			response := volTools.Export(env, efsvoltools.ExportRequest{Name: "source-volume", Opts: opts, Path: "../other"}, &bytes.Buffer{})
+			Expect(response.Err).To(ContainSubstring("must not contain '..'"))
+			Expect(fakeMounter.MountCallCount()).To(Equal(0))
+		})
+	})
+})
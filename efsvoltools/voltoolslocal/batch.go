@@ -0,0 +1,79 @@
+package voltoolslocal
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DefaultBatchParallelism = 8
+	MaxBatchParallelism     = 64
+)
+
+// BatchOpenPerms opens the permissions of every volume in the request. The
+// permissions belong to the root of the file system, so entries that share an
+// ip are served by a single mount and all get its result.
+func (d *EfsVolToolsLocal) BatchOpenPerms(env dockerdriver.Env, request efsvoltools.BatchOpenPermsRequest) efsvoltools.BatchOpenPermsResponse {
+	logger := env.Logger().Session("batch-open-perms", lager.Data{"entries": len(request.Entries)})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	parallelism := request.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultBatchParallelism
+	}
+	if parallelism > MaxBatchParallelism {
+		parallelism = MaxBatchParallelism
+	}
+
+	results := make([]efsvoltools.ErrorResponse, len(request.Entries))
+	entriesByIp := map[string][]int{}
+	ips := []string{}
+	for i, entry := range request.Entries {
+		ip, invalid := volumeRef{name: entry.Name, opts: entry.Opts}.validate()
+		if invalid.Err != "" {
+			results[i] = invalid
+			continue
+		}
+		if _, ok := entriesByIp[ip]; !ok {
+			ips = append(ips, ip)
+		}
+		entriesByIp[ip] = append(entriesByIp[ip], i)
+	}
+
+	fileSystems := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelism && i < len(ips); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ip := range fileSystems {
+				indexes := entriesByIp[ip]
+				entry := request.Entries[indexes[0]]
+				result := d.openPerms(driverhttp.EnvWithLogger(logger.Session("file-system", lager.Data{"ip": ip, "entries": len(indexes)}), env), volumeRef{name: entry.Name, opts: entry.Opts})
+				for _, index := range indexes {
+					results[index] = result
+				}
+			}
+		}()
+	}
+	for _, ip := range ips {
+		fileSystems <- ip
+	}
+	close(fileSystems)
+	wg.Wait()
+
+	response := efsvoltools.BatchOpenPermsResponse{Results: results}
+	for _, result := range results {
+		if result.Err != "" {
+			response.Failed++
+		}
+	}
+
+	logger.Info("opened", lager.Data{"file-systems": len(ips), "failed": response.Failed})
+	return response
+}
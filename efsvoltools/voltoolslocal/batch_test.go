@@ -0,0 +1,151 @@
+package voltoolslocal_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BatchOpenPerms", func() {
+	var (
+		env         dockerdriver.Env
+		fakeMounter *volumedriverfakes.FakeMounter
+		volTools    *voltoolslocal.EfsVolToolsLocal
+		tempDir     string
+		mountDir    string
+		request     efsvoltools.BatchOpenPermsRequest
+		response    efsvoltools.BatchOpenPermsResponse
+	)
+
+	entry := func(name, ip string) efsvoltools.OpenPermsRequest {
+		return efsvoltools.OpenPermsRequest{Name: name, Opts: map[string]interface{}{"ip": ip}}
+	}
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("batch"), context.TODO())
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "batch")
+		Expect(err).NotTo(HaveOccurred())
+		mountDir = filepath.Join(tempDir, "mounts")
+
+		fakeMounter = &volumedriverfakes.FakeMounter{}
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, mountDir, fakeMounter, &efsdriverfakes.FakeACLBackend{})
+
+		request = efsvoltools.BatchOpenPermsRequest{
+			Entries: []efsvoltools.OpenPermsRequest{
+				entry("volume-a", "1.1.1.1"),
+				entry("volume-b", "2.2.2.2"),
+				entry("volume-c", "1.1.1.1"),
+				{Name: "volume-d"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	JustBeforeEach(func() {
+		response = volTools.BatchOpenPerms(env, request)
+	})
+
+	It("mounts each file system once and unmounts it again", func() {
+		Expect(fakeMounter.MountCallCount()).To(Equal(2))
+		sources := []string{}
+		for i := 0; i < fakeMounter.MountCallCount(); i++ {
+			_, source, _, _ := fakeMounter.MountArgsForCall(i)
+			sources = append(sources, source)
+		}
+		Expect(sources).To(ConsistOf("1.1.1.1:/", "2.2.2.2:/"))
+
+		Expect(fakeMounter.UnmountCallCount()).To(Equal(2))
+		entries, err := ioutil.ReadDir(mountDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	It("returns a result for every entry, in order", func() {
+		Expect(response.Err).To(BeEmpty())
+		Expect(response.Results).To(HaveLen(4))
+		Expect(response.Results[0].Err).To(BeEmpty())
+		Expect(response.Results[1].Err).To(BeEmpty())
+		Expect(response.Results[2].Err).To(BeEmpty())
+		Expect(response.Results[3].Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+		Expect(response.Results[3].Details).To(Equal(map[string]string{"field": "ip"}))
+		Expect(response.Failed).To(Equal(int64(1)))
+	})
+
+	Context("when a file system cannot be mounted", func() {
+		BeforeEach(func() {
+			fakeMounter.MountStub = func(env dockerdriver.Env, source, target string, opts map[string]interface{}) error {
+				if source == "1.1.1.1:/" {
+					return errors.New("connection timed out")
+				}
+				return nil
+			}
+		})
+
+		It("fails every entry on it and carries on with the others", func() {
+			Expect(response.Results[0].Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+			Expect(response.Results[0].Retryable).To(BeTrue())
+			Expect(response.Results[2]).To(Equal(response.Results[0]))
+			Expect(response.Results[1].Err).To(BeEmpty())
+			Expect(response.Failed).To(Equal(int64(3)))
+		})
+	})
+
+	Context("with many file systems", func() {
+		var mounted, mostMounted int32
+
+		BeforeEach(func() {
+			mounted, mostMounted = 0, 0
+			request.Entries = nil
+			for i := 0; i < 10; i++ {
+				request.Entries = append(request.Entries, entry(fmt.Sprintf("volume-%d", i), fmt.Sprintf("10.0.0.%d", i)))
+			}
+			request.Parallelism = 3
+
+			fakeMounter.MountStub = func(env dockerdriver.Env, source, target string, opts map[string]interface{}) error {
+				now := atomic.AddInt32(&mounted, 1)
+				for {
+					most := atomic.LoadInt32(&mostMounted)
+					if now <= most || atomic.CompareAndSwapInt32(&mostMounted, most, now) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			}
+			fakeMounter.UnmountStub = func(env dockerdriver.Env, target string) error {
+				atomic.AddInt32(&mounted, -1)
+				return nil
+			}
+		})
+
+		It("handles them in parallel up to the limit", func() {
+			Expect(response.Failed).To(Equal(int64(0)))
+			Expect(fakeMounter.MountCallCount()).To(Equal(10))
+			Expect(atomic.LoadInt32(&mostMounted)).To(BeNumerically(">", 1))
+			Expect(atomic.LoadInt32(&mostMounted)).To(BeNumerically("<=", 3))
+		})
+	})
+})
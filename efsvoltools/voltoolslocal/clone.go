@@ -0,0 +1,314 @@
+package voltoolslocal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DefaultCloneParallelism = 8
+	MaxCloneParallelism     = 64
+
+	// files are copied under a temporary name and renamed into place once
+	// complete, so an interrupted clone never leaves a partial file that
+	// looks finished.
+	cloneTempPrefix = ".efsclone-"
+)
+
+// CloneDirectory copies request.Source.Path to request.Destination.Path,
+// which may live on a different file system. Files that already exist at the
+// destination with the same size and modification time are skipped, so an
+// interrupted clone can be resumed by issuing the same request again.
+func (d *EfsVolToolsLocal) CloneDirectory(env dockerdriver.Env, request efsvoltools.CloneDirectoryRequest) efsvoltools.CloneDirectoryResponse {
+	logger := env.Logger().Session("clone-directory", lager.Data{"source": request.Source, "destination": request.Destination})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	source, invalid := d.stage(driverhttp.EnvWithLogger(logger, env), volumeRef{name: request.Source.Name, opts: request.Source.Opts, path: request.Source.Path, role: "source"})
+	if invalid.Err != "" {
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: invalid}
+	}
+
+	// a volume named twice is mounted once, with both paths in it
+	sameVolume := request.Source.Name == request.Destination.Name
+	sharedMountPath := ""
+	if sameVolume {
+		sharedMountPath = source.mountPath
+	}
+	destination, invalid := d.stageOn(driverhttp.EnvWithLogger(logger, env), volumeRef{name: request.Destination.Name, opts: request.Destination.Opts, path: request.Destination.Path, role: "destination"}, sharedMountPath)
+	if invalid.Err != "" {
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: invalid}
+	}
+
+	if sameVolume && source.ip != destination.ip {
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, "'Source' and 'Destination' share a volume name but not an ip").WithDetail("field", "destination_ip")}
+	}
+	if sameVolume && (isWithin(source.root, destination.root) || isWithin(destination.root, source.root)) {
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, "'Source' and 'Destination' paths must not overlap").WithDetail("field", "destination_path")}
+	}
+
+	parallelism := request.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultCloneParallelism
+	}
+	if parallelism > MaxCloneParallelism {
+		parallelism = MaxCloneParallelism
+	}
+
+	var response efsvoltools.CloneDirectoryResponse
+	cloneTree := func() efsvoltools.ErrorResponse {
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), source, source.root, false); refused.Err != "" {
+			return refused
+		}
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), destination, filepath.Dir(destination.root), true); refused.Err != "" {
+			return refused
+		}
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), destination, destination.root, false); refused.Err != "" {
+			return refused
+		}
+		response = d.cloneTree(driverhttp.EnvWithLogger(logger, env), source.root, destination.root, parallelism)
+		return response.ErrorResponse
+	}
+
+	response.ErrorResponse = d.mounted(driverhttp.EnvWithLogger(logger, env), source, func() efsvoltools.ErrorResponse {
+		if sameVolume {
+			return cloneTree()
+		}
+		return d.mounted(driverhttp.EnvWithLogger(logger, env), destination, cloneTree)
+	})
+
+	logger.Info("cloned", lager.Data{"copied": response.Copied, "skipped": response.Skipped, "failed": response.Failed, "bytes": response.Bytes})
+	return response
+}
+
+type cloneEntry struct {
+	source      string
+	destination string
+	info        os.FileInfo
+}
+
+func (d *EfsVolToolsLocal) cloneTree(env dockerdriver.Env, sourceRoot, destinationRoot string, parallelism int) efsvoltools.CloneDirectoryResponse {
+	logger := env.Logger().Session("clone-tree", lager.Data{"source": sourceRoot, "destination": destinationRoot, "parallelism": parallelism})
+	ctx := env.Context()
+
+	info, err := d.os.Lstat(sourceRoot)
+	if err != nil {
+		logger.Error("failed-to-stat-source", err)
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error reading source path: %s", err.Error())).WithDetail("field", "source_path")}
+	}
+	if !info.IsDir() {
+		return efsvoltools.CloneDirectoryResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, "Source path is not a directory").WithDetail("field", "source_path")}
+	}
+
+	var copied, skipped, failed, bytes int64
+	report := func() {
+		done := atomic.LoadInt64(&copied) + atomic.LoadInt64(&skipped) + atomic.LoadInt64(&failed)
+		if done%progressInterval == 0 {
+			efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: done, Message: fmt.Sprintf("%d bytes copied", atomic.LoadInt64(&bytes))})
+		}
+	}
+
+	files := make(chan cloneEntry)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range files {
+				n, wasCopied, err := d.cloneFile(entry)
+				switch {
+				case err != nil:
+					logger.Error("failed-to-copy-file", err, lager.Data{"path": entry.source})
+					atomic.AddInt64(&failed, 1)
+				case wasCopied:
+					atomic.AddInt64(&copied, 1)
+					atomic.AddInt64(&bytes, n)
+				default:
+					atomic.AddInt64(&skipped, 1)
+				}
+				report()
+			}
+		}()
+	}
+
+	// directory metadata is applied once their contents are in place, deepest
+	// first, so that restrictive modes don't get in the way of the copy.
+	var dirs []cloneEntry
+	walkErr := d.filepath.Walk(sourceRoot, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			logger.Error("failed-to-walk-entry", err, lager.Data{"path": path})
+			atomic.AddInt64(&failed, 1)
+			return nil
+		}
+
+		relative, err := filepath.Rel(sourceRoot, path)
+		if err != nil {
+			return err
+		}
+		entry := cloneEntry{source: path, destination: filepath.Join(destinationRoot, relative), info: info}
+
+		switch {
+		case info.IsDir():
+			if err := d.cloneDir(entry); err != nil {
+				logger.Error("failed-to-create-directory", err, lager.Data{"path": entry.destination})
+				atomic.AddInt64(&failed, 1)
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, entry)
+		case info.Mode()&os.ModeSymlink != 0:
+			wasCopied, err := d.cloneSymlink(entry)
+			switch {
+			case err != nil:
+				logger.Error("failed-to-copy-symlink", err, lager.Data{"path": entry.source})
+				atomic.AddInt64(&failed, 1)
+			case wasCopied:
+				atomic.AddInt64(&copied, 1)
+			default:
+				atomic.AddInt64(&skipped, 1)
+			}
+		case info.Mode().IsRegular():
+			files <- entry
+		default:
+			logger.Info("skipping-special-file", lager.Data{"path": path, "mode": info.Mode().String()})
+		}
+		return nil
+	})
+	close(files)
+	wg.Wait()
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := d.applyMetadata(dirs[i].destination, dirs[i].info); err != nil {
+			logger.Error("failed-to-apply-directory-metadata", err, lager.Data{"path": dirs[i].destination})
+			failed++
+		}
+	}
+
+	response := efsvoltools.CloneDirectoryResponse{
+		Copied:  copied,
+		Skipped: skipped,
+		Failed:  failed,
+		Bytes:   bytes,
+	}
+	efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: copied + skipped + failed, Total: copied + skipped + failed, Message: fmt.Sprintf("%d bytes copied", bytes)})
+
+	if walkErr != nil {
+		logger.Error("walk-failed", walkErr)
+		response.ErrorResponse = errorResponse(efsvoltools.ErrorCodeInternal, walkErr, fmt.Sprintf("Error walking source directory: %s", walkErr.Error()))
+	}
+	return response
+}
+
+func (d *EfsVolToolsLocal) cloneDir(entry cloneEntry) error {
+	if err := d.os.Mkdir(entry.destination, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	// a directory left over from an earlier run must really be a directory;
+	// following a symlink here could write outside of the destination.
+	info, err := d.os.Lstat(entry.destination)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("'%s' exists and is not a directory", entry.destination)
+	}
+	return nil
+}
+
+func (d *EfsVolToolsLocal) cloneSymlink(entry cloneEntry) (bool, error) {
+	link, err := d.os.Readlink(entry.source)
+	if err != nil {
+		return false, err
+	}
+
+	if existing, err := d.os.Readlink(entry.destination); err == nil && existing == link {
+		return false, d.applyMetadata(entry.destination, entry.info)
+	}
+	if err := d.os.Remove(entry.destination); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	if err := d.os.Symlink(link, entry.destination); err != nil {
+		return false, err
+	}
+	return true, d.applyMetadata(entry.destination, entry.info)
+}
+
+// cloneFile copies a regular file unless an identical copy is already in
+// place, and returns the number of bytes copied.
+func (d *EfsVolToolsLocal) cloneFile(entry cloneEntry) (int64, bool, error) {
+	if existing, err := d.os.Lstat(entry.destination); err == nil &&
+		existing.Mode().IsRegular() &&
+		existing.Size() == entry.info.Size() &&
+		existing.ModTime().Equal(entry.info.ModTime()) {
+		return 0, false, nil
+	}
+
+	source, err := d.os.Open(entry.source)
+	if err != nil {
+		return 0, false, err
+	}
+	defer source.Close()
+
+	temp := filepath.Join(filepath.Dir(entry.destination), cloneTempPrefix+filepath.Base(entry.destination))
+	destination, err := d.os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return 0, false, err
+	}
+
+	n, err := io.Copy(destination, source)
+	if err != nil {
+		destination.Close()
+		d.os.Remove(temp)
+		return n, false, err
+	}
+	if err := destination.Close(); err != nil {
+		d.os.Remove(temp)
+		return n, false, err
+	}
+
+	if err := d.applyMetadata(temp, entry.info); err != nil {
+		d.os.Remove(temp)
+		return n, false, err
+	}
+	if err := d.os.Rename(temp, entry.destination); err != nil {
+		d.os.Remove(temp)
+		return n, false, err
+	}
+	return n, true, nil
+}
+
+// applyMetadata copies ownership, mode and modification time from info onto
+// path. Symlinks only get their ownership changed.
+func (d *EfsVolToolsLocal) applyMetadata(path string, info os.FileInfo) error {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := d.os.Lchown(path, int(stat.Uid), int(stat.Gid)); err != nil {
+			return err
+		}
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return nil
+	}
+	if err := d.os.Chmod(path, info.Mode()&permBits); err != nil {
+		return err
+	}
+	return d.os.Chtimes(path, info.ModTime(), info.ModTime())
+}
+
+func isWithin(parent, path string) bool {
+	relative, err := filepath.Rel(parent, path)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
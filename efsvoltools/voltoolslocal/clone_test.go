@@ -0,0 +1,201 @@
+package voltoolslocal_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("CloneDirectory", func() {
+	var (
+		env         dockerdriver.Env
+		fakeMounter *volumedriverfakes.FakeMounter
+		volTools    *voltoolslocal.EfsVolToolsLocal
+		tempDir     string
+		mountDir    string
+		keptDir     string
+		request     efsvoltools.CloneDirectoryRequest
+		response    efsvoltools.CloneDirectoryResponse
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("clone"), context.TODO())
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "clone")
+		Expect(err).NotTo(HaveOccurred())
+		mountDir = filepath.Join(tempDir, "mounts")
+		keptDir = filepath.Join(tempDir, "kept")
+		Expect(os.MkdirAll(keptDir, 0755)).To(Succeed())
+
+		fakeMounter = persistentFakeMounter(keptDir)
+
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, mountDir, fakeMounter, &efsdriverfakes.FakeACLBackend{})
+
+		source := filepath.Join(keptDir, "source-volume", "instance-a")
+		Expect(os.MkdirAll(filepath.Join(source, "sub"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(source, "top"), []byte("top data"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(source, "sub", "nested"), []byte("nested data"), 0600)).To(Succeed())
+		Expect(os.Symlink("sub/nested", filepath.Join(source, "link"))).To(Succeed())
+		Expect(os.Chmod(filepath.Join(source, "sub"), 0710)).To(Succeed())
+		past := time.Now().Add(-time.Hour).Truncate(time.Second)
+		Expect(os.Chtimes(filepath.Join(source, "top"), past, past)).To(Succeed())
+
+		request = efsvoltools.CloneDirectoryRequest{
+			Source:      efsvoltools.CloneLocation{Name: "source-volume", Opts: map[string]interface{}{"ip": "1.1.1.1"}, Path: "instance-a"},
+			Destination: efsvoltools.CloneLocation{Name: "target-volume", Opts: map[string]interface{}{"ip": "2.2.2.2"}, Path: "instance-b"},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	JustBeforeEach(func() {
+		response = volTools.CloneDirectory(env, request)
+	})
+
+	It("mounts both file systems and copies the tree with its metadata", func() {
+		Expect(response.Err).To(BeEmpty())
+		Expect(fakeMounter.MountCallCount()).To(Equal(2))
+		Expect(fakeMounter.UnmountCallCount()).To(Equal(2))
+		Expect(response.Copied).To(Equal(int64(3)))
+		Expect(response.Failed).To(Equal(int64(0)))
+		Expect(response.Bytes).To(Equal(int64(len("top data") + len("nested data"))))
+
+		destination := filepath.Join(keptDir, "target-volume", "instance-b")
+		data, err := ioutil.ReadFile(filepath.Join(destination, "sub", "nested"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(Equal("nested data"))
+
+		info, err := os.Stat(filepath.Join(destination, "sub"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0710)))
+
+		sourceInfo, err := os.Stat(filepath.Join(keptDir, "source-volume", "instance-a", "top"))
+		Expect(err).NotTo(HaveOccurred())
+		info, err = os.Stat(filepath.Join(destination, "top"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.ModTime()).To(Equal(sourceInfo.ModTime()))
+
+		link, err := os.Readlink(filepath.Join(destination, "link"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal("sub/nested"))
+	})
+
+	Context("when the clone is run again", func() {
+		It("skips the files that were already copied", func() {
+			Expect(response.Err).To(BeEmpty())
+
+			Expect(os.Remove(filepath.Join(keptDir, "target-volume", "instance-b", "sub", "nested"))).To(Succeed())
+
+			response = volTools.CloneDirectory(env, request)
+			Expect(response.Err).To(BeEmpty())
+			Expect(response.Skipped).To(Equal(int64(2)))
+			Expect(response.Copied).To(Equal(int64(1)))
+			Expect(filepath.Join(keptDir, "target-volume", "instance-b", "sub", "nested")).To(BeAnExistingFile())
+		})
+	})
+
+	Context("when source and destination are on the same volume", func() {
+		BeforeEach(func() {
+			request.Destination.Name = "source-volume"
+			request.Destination.Opts = request.Source.Opts
+		})
+
+		It("mounts it only once", func() {
+			Expect(response.Err).To(BeEmpty())
+			Expect(fakeMounter.MountCallCount()).To(Equal(1))
+			Expect(filepath.Join(keptDir, "source-volume", "instance-b", "top")).To(BeAnExistingFile())
+		})
+
+		Context("and the paths overlap", func() {
+			BeforeEach(func() {
+				request.Destination.Path = "instance-a/nested-copy"
+			})
+
+			It("refuses to clone", func() {
+				Expect(response.Err).To(ContainSubstring("must not overlap"))
+				Expect(fakeMounter.MountCallCount()).To(Equal(0))
+			})
+		})
+	})
+
+	Context("when a symlink on the volume leads out of it", func() {
+		var outside string
+
+		BeforeEach(func() {
+			outside = filepath.Join(tempDir, "outside")
+			Expect(os.MkdirAll(outside, 0755)).To(Succeed())
+		})
+
+		Context("on the destination path", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Join(keptDir, "target-volume"), 0755)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(keptDir, "target-volume", "planted"))).To(Succeed())
+				request.Destination.Path = "planted/instance-b"
+			})
+
+			It("refuses to write through it", func() {
+				Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+				Expect(response.Details).To(Equal(map[string]string{"field": "destination_path"}))
+				Expect(filepath.Join(outside, "instance-b")).NotTo(BeAnExistingFile())
+			})
+		})
+
+		Context("on the source path", func() {
+			BeforeEach(func() {
+				Expect(os.MkdirAll(filepath.Join(outside, "sub"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(outside, "sub", "secret"), []byte("secret"), 0600)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(keptDir, "source-volume", "planted"))).To(Succeed())
+				request.Source.Path = "planted/sub"
+			})
+
+			It("refuses to read through it", func() {
+				Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+				Expect(response.Details).To(Equal(map[string]string{"field": "source_path"}))
+				Expect(filepath.Join(keptDir, "target-volume", "instance-b", "secret")).NotTo(BeAnExistingFile())
+			})
+		})
+	})
+
+	Context("when the destination is missing its volume name", func() {
+		BeforeEach(func() {
+			request.Destination.Name = ""
+		})
+
+		It("refuses to clone without mounting anything", func() {
+			Expect(response.Err).To(Equal("Missing mandatory 'volume_name' in 'Destination'"))
+			Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+			Expect(response.Details).To(Equal(map[string]string{"field": "destination_volume_name"}))
+			Expect(fakeMounter.MountCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the destination cannot be mounted", func() {
+		BeforeEach(func() {
+			fakeMounter.MountStub = nil
+			fakeMounter.MountReturnsOnCall(1, os.ErrPermission)
+		})
+
+		It("reports the error and unmounts the source", func() {
+			Expect(response.Err).To(ContainSubstring("Error mounting destination volume"))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		})
+	})
+})
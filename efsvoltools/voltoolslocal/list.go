@@ -0,0 +1,202 @@
+package voltoolslocal
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DefaultListPageSize = 100
+	MaxListPageSize     = 1000
+)
+
+var errPageFull = errors.New("page full")
+
+// ListDirectories lists the entries below request.Path down to request.Depth
+// levels. Entries come back in a stable depth-first order, one page at a
+// time; the NextPageToken of a response picks up where that page ended.
+func (d *EfsVolToolsLocal) ListDirectories(env dockerdriver.Env, request efsvoltools.ListDirectoriesRequest) efsvoltools.ListDirectoriesResponse {
+	logger := env.Logger().Session("list-directories", lager.Data{"opts": request.Opts, "path": request.Path, "depth": request.Depth})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	depth := request.Depth
+	if depth <= 0 {
+		depth = 1
+	}
+	pageSize := request.PageSize
+	if pageSize <= 0 {
+		pageSize = DefaultListPageSize
+	}
+	if pageSize > MaxListPageSize {
+		pageSize = MaxListPageSize
+	}
+
+	after, err := decodePageToken(request.PageToken)
+	if err != nil {
+		return efsvoltools.ListDirectoriesResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()).WithDetail("field", "page_token")}
+	}
+
+	response := efsvoltools.ListDirectoriesResponse{Entries: []efsvoltools.DirectoryEntry{}}
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	response.ErrorResponse = d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), v, v.root, false); refused.Err != "" {
+			return refused
+		}
+
+		lister := &directoryLister{
+			tools:        d,
+			env:          driverhttp.EnvWithLogger(logger, env),
+			root:         v.root,
+			depth:        depth,
+			pageSize:     pageSize,
+			after:        after,
+			includeUsage: request.IncludeUsage,
+		}
+		switch err := lister.list("", 1); err {
+		case nil:
+			response.Entries = lister.entries
+		case errPageFull:
+			response.Entries = lister.entries
+			response.NextPageToken = encodePageToken(lister.entries[len(lister.entries)-1].Path)
+		default:
+			logger.Error("list-failed", err)
+			return errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error listing volume: %s", err.Error()))
+		}
+		return efsvoltools.ErrorResponse{}
+	})
+	return response
+}
+
+type directoryLister struct {
+	tools        *EfsVolToolsLocal
+	env          dockerdriver.Env
+	root         string
+	depth        int
+	pageSize     int
+	after        string
+	includeUsage bool
+
+	entries []efsvoltools.DirectoryEntry
+}
+
+// list visits the children of relative, which sits level-1 levels below the
+// root, in name order.
+func (l *directoryLister) list(relative string, level int) error {
+	if err := l.env.Context().Err(); err != nil {
+		return err
+	}
+
+	children, err := l.tools.ioutil.ReadDir(filepath.Join(l.root, relative))
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		path := filepath.Join(relative, child.Name())
+
+		if l.after != "" && comparePaths(path, l.after) <= 0 {
+			// everything below path sorts before the token as well, unless
+			// the token is path itself or lies within it.
+			if child.IsDir() && level < l.depth && isWithin(path, l.after) {
+				if err := l.list(path, level+1); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(l.entries) == l.pageSize {
+			return errPageFull
+		}
+
+		entry, err := l.entry(path, child)
+		if err != nil {
+			return err
+		}
+		l.entries = append(l.entries, entry)
+
+		if child.IsDir() && level < l.depth {
+			if err := l.list(path, level+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *directoryLister) entry(path string, info os.FileInfo) (efsvoltools.DirectoryEntry, error) {
+	entry := efsvoltools.DirectoryEntry{
+		Path:    filepath.ToSlash(path),
+		IsDir:   info.IsDir(),
+		Size:    info.Size(),
+		Mode:    fmt.Sprintf("%04o", uint32(info.Mode()&permBits)),
+		ModTime: info.ModTime(),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		entry.Uid = stat.Uid
+		entry.Gid = stat.Gid
+	}
+
+	if l.includeUsage && info.IsDir() {
+		usage, err := l.tools.usage(l.env, filepath.Join(l.root, path))
+		if err != nil {
+			return entry, err
+		}
+		entry.Usage = &usage
+	}
+	return entry, nil
+}
+
+// usage adds up the size of every regular file below path.
+func (d *EfsVolToolsLocal) usage(env dockerdriver.Env, path string) (int64, error) {
+	var total int64
+	err := d.filepath.Walk(path, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctxErr := env.Context().Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if info.Mode().IsRegular() {
+			total += info.Size()
+		}
+		return nil
+	})
+	return total, err
+}
+
+// comparePaths orders paths the way a depth-first walk with sorted children
+// visits them: element by element, with a parent before its children.
+func comparePaths(a, b string) int {
+	aElements := strings.Split(a, string(filepath.Separator))
+	bElements := strings.Split(b, string(filepath.Separator))
+	for i := 0; i < len(aElements) && i < len(bElements); i++ {
+		if c := strings.Compare(aElements[i], bElements[i]); c != 0 {
+			return c
+		}
+	}
+	return len(aElements) - len(bElements)
+}
+
+func encodePageToken(path string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(path))
+}
+
+func decodePageToken(token string) (string, error) {
+	path, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", fmt.Errorf("Invalid 'PageToken': %s", err.Error())
+	}
+	return filepath.FromSlash(string(path)), nil
+}
@@ -0,0 +1,132 @@
+package voltoolslocal_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ListDirectories", func() {
+	var (
+		env      dockerdriver.Env
+		volTools *voltoolslocal.EfsVolToolsLocal
+		tempDir  string
+		volume   string
+		request  efsvoltools.ListDirectoriesRequest
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("list"), context.TODO())
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "list")
+		Expect(err).NotTo(HaveOccurred())
+		keptDir := filepath.Join(tempDir, "kept")
+
+		volume = filepath.Join(keptDir, "some-volume")
+		for _, dir := range []string{"instance-a/data", "instance-a-b", "instance-c"} {
+			Expect(os.MkdirAll(filepath.Join(volume, dir), 0755)).To(Succeed())
+		}
+		Expect(ioutil.WriteFile(filepath.Join(volume, "instance-a", "data", "file"), []byte("12345"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(volume, "instance-c", "file"), []byte("123"), 0600)).To(Succeed())
+
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, filepath.Join(tempDir, "mounts"), persistentFakeMounter(keptDir), &efsdriverfakes.FakeACLBackend{})
+		request = efsvoltools.ListDirectoriesRequest{Name: "some-volume", Opts: map[string]interface{}{"ip": "1.1.1.1"}}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	paths := func(entries []efsvoltools.DirectoryEntry) []string {
+		result := []string{}
+		for _, entry := range entries {
+			result = append(result, entry.Path)
+		}
+		return result
+	}
+
+	It("lists the top level entries with their metadata", func() {
+		response := volTools.ListDirectories(env, request)
+		Expect(response.Err).To(BeEmpty())
+		Expect(paths(response.Entries)).To(Equal([]string{"instance-a", "instance-a-b", "instance-c"}))
+		Expect(response.NextPageToken).To(BeEmpty())
+
+		Expect(response.Entries[0].IsDir).To(BeTrue())
+		Expect(response.Entries[0].Mode).To(Equal("0755"))
+		Expect(response.Entries[0].Uid).To(Equal(uint32(os.Getuid())))
+		Expect(response.Entries[0].ModTime).NotTo(BeZero())
+		Expect(response.Entries[0].Usage).To(BeNil())
+	})
+
+	It("lists deeper levels depth first", func() {
+		request.Depth = 3
+		response := volTools.ListDirectories(env, request)
+		Expect(response.Err).To(BeEmpty())
+		Expect(paths(response.Entries)).To(Equal([]string{
+			"instance-a", "instance-a/data", "instance-a/data/file", "instance-a-b", "instance-c", "instance-c/file",
+		}))
+	})
+
+	It("pages through the entries", func() {
+		request.Depth = 3
+		request.PageSize = 2
+
+		var listed []string
+		for pages := 0; pages < 10; pages++ {
+			response := volTools.ListDirectories(env, request)
+			Expect(response.Err).To(BeEmpty())
+			Expect(len(response.Entries)).To(BeNumerically("<=", 2))
+			listed = append(listed, paths(response.Entries)...)
+
+			if response.NextPageToken == "" {
+				break
+			}
+			request.PageToken = response.NextPageToken
+		}
+
+		Expect(listed).To(Equal([]string{
+			"instance-a", "instance-a/data", "instance-a/data/file", "instance-a-b", "instance-c", "instance-c/file",
+		}))
+	})
+
+	It("adds up the usage of each directory when asked", func() {
+		request.IncludeUsage = true
+		response := volTools.ListDirectories(env, request)
+		Expect(response.Err).To(BeEmpty())
+		Expect(*response.Entries[0].Usage).To(Equal(int64(5)))
+		Expect(*response.Entries[1].Usage).To(Equal(int64(0)))
+		Expect(*response.Entries[2].Usage).To(Equal(int64(3)))
+	})
+
+	It("refuses to list through a symlink leading out of the volume", func() {
+		outside := filepath.Join(tempDir, "outside")
+		Expect(os.MkdirAll(filepath.Join(outside, "secret"), 0700)).To(Succeed())
+		Expect(os.Symlink(outside, filepath.Join(volume, "instance-c", "escape"))).To(Succeed())
+
+		request.Path = "instance-c/escape"
+		response := volTools.ListDirectories(env, request)
+		Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+		Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+		Expect(response.Entries).To(BeEmpty())
+	})
+
+	It("rejects a malformed page token", func() {
+		request.PageToken = "not base64!"
+		response := volTools.ListDirectories(env, request)
+		Expect(response.Err).To(ContainSubstring("Invalid 'PageToken'"))
+	})
+})
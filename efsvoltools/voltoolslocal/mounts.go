@@ -0,0 +1,343 @@
+package voltoolslocal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efstracing"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// Every temporary mount has a marker file next to its mountpoint for as long
+// as it may exist, so that Sweep can tell it apart from the driver's own
+// mounts once the process that made it is gone.
+const mountMarkerSuffix = ".voltools-mount"
+
+// CleanupTimeout bounds the unmount that cleans up after an operation whose
+// context was cancelled or ran past its deadline.
+const CleanupTimeout = 30 * time.Second
+
+// DefaultSweepInterval is how often NewSweeper looks for leftover mounts.
+const DefaultSweepInterval = 10 * time.Minute
+
+// ValidateStagingRoot checks that the staging root voltools mounts volumes
+// under neither contains nor lies within the driver's mount directory, where
+// a temporary mount could shadow an application's volume, or be mistaken for
+// one.
+func ValidateStagingRoot(stagingRoot, driverMountDir string) error {
+	staging, err := filepath.Abs(stagingRoot)
+	if err != nil {
+		return err
+	}
+	driver, err := filepath.Abs(driverMountDir)
+	if err != nil {
+		return err
+	}
+	if isWithin(staging, driver) || isWithin(driver, staging) {
+		return fmt.Errorf("voltools staging directory '%s' must not overlap the driver mount directory '%s'", staging, driver)
+	}
+	return nil
+}
+
+// withMount mounts the volume at ip on mountPath and runs work against it. The
+// volume is unmounted again once work returns, and also when it panics, in
+// which case the panic is passed on after unmounting. work is not run when
+// mounting fails.
+func (d *EfsVolToolsLocal) withMount(env dockerdriver.Env, name, ip, mountPath string, work func()) (mountErr, unmountErr error) {
+	if err := d.mount(env, ip, mountPath); err != nil {
+		return err, nil
+	}
+
+	defer func() {
+		unmountErr = d.unmount(env, name, mountPath)
+	}()
+
+	work()
+	return nil, nil
+}
+
+func (d *EfsVolToolsLocal) mount(env dockerdriver.Env, ip, mountPath string) (err error) {
+	logger := env.Logger().Session("mount", lager.Data{"ip": ip, "target": mountPath})
+	logger.Info("start")
+	defer logger.Info("end")
+
+	ctx, span := efstracing.Start(env.Context(), "voltools.mount", efstracing.String("efs.target", mountPath))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	env = driverhttp.EnvWithContext(ctx, env)
+
+	if err := env.Context().Err(); err != nil {
+		logger.Error("mount-cancelled", err)
+		return err
+	}
+
+	source, err := efsmounter.Source(ip, "/")
+	if err != nil {
+		logger.Error("invalid-mount-source", err)
+		return err
+	}
+
+	d.track(mountPath)
+	if err := d.ioutil.WriteFile(mountPath+mountMarkerSuffix, []byte(ip), 0644); err != nil {
+		logger.Error("create-mount-marker-failed", err)
+		d.untrack(mountPath)
+		return err
+	}
+
+	err = d.os.MkdirAll(mountPath, os.ModePerm)
+	if err != nil {
+		logger.Error("create-mountdir-failed", err)
+		d.discard(logger, env, mountPath, false)
+		return err
+	}
+	if err := d.os.Chmod(mountPath, os.ModePerm); err != nil {
+		logger.Error("chmod-mountdir-failed", err)
+		d.discard(logger, env, mountPath, false)
+		return err
+	}
+
+	// TODO--permissions & flags?
+	err = d.mounter.Mount(driverhttp.EnvWithLogger(logger, env), source, mountPath, nil)
+	if ctxErr := env.Context().Err(); ctxErr != nil {
+		// the mount may have completed before it was interrupted
+		logger.Error("mount-cancelled", ctxErr)
+		d.discard(logger, env, mountPath, true)
+		return ctxErr
+	}
+	if err != nil {
+		logger.Error("mount-failed", err)
+		d.discard(logger, env, mountPath, false)
+	}
+	return err
+}
+
+// discard undoes a mount that failed or was interrupted part way. The
+// mountpoint is only removed while empty, so that the contents of a volume
+// that could not be unmounted are never deleted; the marker is then kept for
+// Sweep to try again.
+func (d *EfsVolToolsLocal) discard(logger lager.Logger, env dockerdriver.Env, mountPath string, mayBeMounted bool) {
+	defer d.untrack(mountPath)
+
+	if mayBeMounted {
+		cleanupEnv, cancel := detachedEnv(logger, env)
+		defer cancel()
+		if err := d.mounter.Unmount(cleanupEnv, mountPath); err != nil {
+			logger.Info("cleanup-unmount-failed", lager.Data{"err": err.Error()})
+		}
+	}
+
+	if err := d.os.Remove(mountPath); err != nil && !os.IsNotExist(err) {
+		logger.Error("cleanup-mountdir-failed", err)
+		return
+	}
+	d.removeMarker(logger, mountPath)
+}
+
+// detachedEnv returns env itself while its context is still live, and
+// otherwise a copy with a fresh context, so that cleanup is not cut short
+// by the cancellation that caused it. The copy stays in env's trace.
+func detachedEnv(logger lager.Logger, env dockerdriver.Env) (dockerdriver.Env, context.CancelFunc) {
+	if env.Context().Err() == nil {
+		return driverhttp.EnvWithLogger(logger, env), func() {}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), CleanupTimeout)
+	ctx = efstracing.ContextWithSpan(ctx, efstracing.SpanFromContext(env.Context()))
+	return driverhttp.NewHttpDriverEnv(logger, ctx), cancel
+}
+
+func (d *EfsVolToolsLocal) unmount(env dockerdriver.Env, name string, mountPath string) (err error) {
+	logger := env.Logger().Session("unmount")
+	logger.Info("start")
+	defer logger.Info("end")
+	defer d.untrack(mountPath)
+
+	ctx, span := efstracing.Start(env.Context(), "voltools.unmount", efstracing.String("efs.target", mountPath))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+	env = driverhttp.EnvWithContext(ctx, env)
+
+	exists, err := d.exists(mountPath)
+	if err != nil {
+		logger.Error("failed-retrieving-mount-info", err, lager.Data{"mountpoint": mountPath})
+		return errors.New("Error establishing whether volume exists")
+	}
+
+	if !exists {
+		errText := fmt.Sprintf("Volume %s does not exist (path: %s), nothing to do!", name, mountPath)
+		logger.Error("failed-mountpoint-not-found", errors.New(errText))
+		d.removeMarker(logger, mountPath)
+		return errors.New(errText)
+	}
+
+	logger.Info("unmount-volume-folder", lager.Data{"mountpath": mountPath})
+
+	unmountEnv, cancel := detachedEnv(logger, env)
+	defer cancel()
+	err = d.mounter.Unmount(unmountEnv, mountPath)
+	if err != nil && env.Context().Err() != nil {
+		// the deadline passed while unmounting; the mount must not be left behind
+		logger.Info("retrying-unmount-after-cancel", lager.Data{"err": err.Error()})
+		unmountEnv, cancel := detachedEnv(logger, env)
+		defer cancel()
+		err = d.mounter.Unmount(unmountEnv, mountPath)
+	}
+	if err != nil {
+		logger.Error("unmount-failed", err)
+		return fmt.Errorf("Error unmounting volume: %s", err.Error())
+	}
+	err = d.os.RemoveAll(mountPath)
+	if err != nil {
+		logger.Error("create-mountdir-failed", err)
+		return fmt.Errorf("Error creating mountpoint: %s", err.Error())
+	}
+	d.removeMarker(logger, mountPath)
+
+	logger.Info("unmounted-volume")
+
+	return nil
+}
+
+func (d *EfsVolToolsLocal) removeMarker(logger lager.Logger, mountPath string) {
+	if err := d.os.Remove(mountPath + mountMarkerSuffix); err != nil && !os.IsNotExist(err) {
+		logger.Error("remove-mount-marker-failed", err)
+	}
+}
+
+// track records that an operation is using mountPath, which Sweep must then
+// leave alone.
+func (d *EfsVolToolsLocal) track(mountPath string) {
+	d.mountsLock.Lock()
+	defer d.mountsLock.Unlock()
+	d.activeMounts[mountPath]++
+}
+
+func (d *EfsVolToolsLocal) untrack(mountPath string) {
+	d.mountsLock.Lock()
+	defer d.mountsLock.Unlock()
+	if d.activeMounts[mountPath] <= 1 {
+		delete(d.activeMounts, mountPath)
+	} else {
+		d.activeMounts[mountPath]--
+	}
+}
+
+// claim tracks mountPath for Sweep, unless an operation or another sweep is
+// already using it.
+func (d *EfsVolToolsLocal) claim(mountPath string) bool {
+	d.mountsLock.Lock()
+	defer d.mountsLock.Unlock()
+	if d.activeMounts[mountPath] > 0 {
+		return false
+	}
+	d.activeMounts[mountPath]++
+	return true
+}
+
+// Sweep unmounts and removes the temporary mounts under the mount root that no
+// operation in this process is using, such as those left behind by a crash.
+// Mounts it cannot clean up are left for the next sweep. It returns the number
+// of mounts removed.
+func (d *EfsVolToolsLocal) Sweep(env dockerdriver.Env) int {
+	logger := env.Logger().Session("sweep")
+	logger.Debug("start")
+	defer logger.Debug("end")
+
+	root, err := d.filepath.Abs(d.mountPathRoot)
+	if err != nil {
+		logger.Error("abs-failed", err)
+		return 0
+	}
+
+	entries, err := d.ioutil.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("read-mount-root-failed", err)
+		}
+		return 0
+	}
+
+	swept := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), mountMarkerSuffix) {
+			continue
+		}
+		if d.sweepMount(logger, env, filepath.Join(root, strings.TrimSuffix(entry.Name(), mountMarkerSuffix))) {
+			swept++
+		}
+	}
+
+	if swept > 0 {
+		logger.Info("swept", lager.Data{"mounts": swept})
+	}
+	return swept
+}
+
+// sweepMount claims mountPath while it is cleaned up, rather than holding the
+// lock, so that operations are not held up by a slow unmount.
+func (d *EfsVolToolsLocal) sweepMount(logger lager.Logger, env dockerdriver.Env, mountPath string) bool {
+	if !d.claim(mountPath) {
+		return false
+	}
+	defer d.untrack(mountPath)
+
+	logger = logger.Session("sweep-mount", lager.Data{"mountpath": mountPath})
+	ctx, cancel := context.WithTimeout(env.Context(), CleanupTimeout)
+	defer cancel()
+	env = driverhttp.EnvWithContext(ctx, driverhttp.EnvWithLogger(logger, env))
+
+	if d.mounter.Check(env, filepath.Base(mountPath), mountPath) {
+		if err := d.mounter.Unmount(env, mountPath); err != nil {
+			logger.Error("unmount-failed", err)
+			return false
+		}
+	}
+
+	if err := d.os.Remove(mountPath); err != nil && !os.IsNotExist(err) {
+		logger.Error("remove-mountdir-failed", err)
+		return false
+	}
+	if err := d.os.Remove(mountPath + mountMarkerSuffix); err != nil && !os.IsNotExist(err) {
+		logger.Error("remove-mount-marker-failed", err)
+		return false
+	}
+
+	logger.Info("swept")
+	return true
+}
+
+// NewSweeper runs Sweep when started, before reporting ready, and then every
+// interval until signalled.
+func NewSweeper(logger lager.Logger, tools *EfsVolToolsLocal, clock clock.Clock, interval time.Duration) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		logger := logger.Session("mount-sweeper", lager.Data{"interval": interval.String()})
+		env := driverhttp.NewHttpDriverEnv(logger, context.Background())
+
+		tools.Sweep(env)
+		close(ready)
+
+		ticker := clock.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				tools.Sweep(env)
+			case <-signals:
+				return nil
+			}
+		}
+	})
+}
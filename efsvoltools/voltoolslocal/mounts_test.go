@@ -0,0 +1,212 @@
+package voltoolslocal_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
+	"code.cloudfoundry.org/goshims/ioutilshim"
+	"code.cloudfoundry.org/goshims/osshim"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Temporary mounts", func() {
+	var (
+		env            dockerdriver.Env
+		fakeMounter    *volumedriverfakes.FakeMounter
+		fakeACLBackend *efsdriverfakes.FakeACLBackend
+		volTools       *voltoolslocal.EfsVolToolsLocal
+		tempDir        string
+		mountDir       string
+		opts           map[string]interface{}
+	)
+
+	BeforeEach(func() {
+		env = driverhttp.NewHttpDriverEnv(lagertest.NewTestLogger("mounts"), context.TODO())
+		opts = map[string]interface{}{"ip": "1.1.1.1"}
+
+		var err error
+		tempDir, err = ioutil.TempDir("", "mounts")
+		Expect(err).NotTo(HaveOccurred())
+		mountDir = filepath.Join(tempDir, "mounts")
+
+		fakeMounter = &volumedriverfakes.FakeMounter{}
+		fakeACLBackend = &efsdriverfakes.FakeACLBackend{}
+		volTools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, mountDir, fakeMounter, fakeACLBackend)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tempDir)
+	})
+
+	mountDirContents := func() []string {
+		entries, err := ioutil.ReadDir(mountDir)
+		Expect(err).NotTo(HaveOccurred())
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		return names
+	}
+
+	It("leaves nothing behind after a successful operation", func() {
+		response := volTools.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume", Opts: opts})
+		Expect(response.Err).To(BeEmpty())
+		Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		Expect(mountDirContents()).To(BeEmpty())
+	})
+
+	It("unmounts when the operation fails", func() {
+		response := volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts, Path: "missing"})
+		Expect(response.Err).NotTo(BeEmpty())
+		Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		Expect(mountDirContents()).To(BeEmpty())
+	})
+
+	It("unmounts and passes the panic on when an operation panics", func() {
+		fakeACLBackend.GetACLStub = func(dockerdriver.Env, string) ([]efsvoltools.ACE, error) {
+			panic("badness")
+		}
+
+		Expect(func() {
+			volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts})
+		}).To(PanicWith("badness"))
+		Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+		Expect(mountDirContents()).To(BeEmpty())
+	})
+
+	It("removes the mountpoint when mounting fails", func() {
+		fakeMounter.MountReturns(errors.New("mount.nfs: Connection timed out"))
+
+		response := volTools.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume", Opts: opts})
+		Expect(response.Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+		Expect(mountDirContents()).To(BeEmpty())
+	})
+
+	Context("when sweeping", func() {
+		BeforeEach(func() {
+			Expect(os.MkdirAll(filepath.Join(mountDir, "leftover"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(mountDir, "leftover.voltools-mount"), []byte("1.1.1.1"), 0644)).To(Succeed())
+			Expect(os.MkdirAll(filepath.Join(mountDir, "app-volume"), 0755)).To(Succeed())
+		})
+
+		It("unmounts and removes temporary mounts left behind", func() {
+			fakeMounter.CheckReturns(true)
+
+			Expect(volTools.Sweep(env)).To(Equal(1))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+			_, target := fakeMounter.UnmountArgsForCall(0)
+			Expect(target).To(Equal(filepath.Join(mountDir, "leftover")))
+
+			By("leaving the driver's own mounts alone")
+			Expect(mountDirContents()).To(Equal([]string{"app-volume"}))
+		})
+
+		It("keeps a mount it cannot unmount for the next sweep", func() {
+			fakeMounter.CheckReturns(true)
+			fakeMounter.UnmountReturns(errors.New("device is busy"))
+
+			Expect(volTools.Sweep(env)).To(Equal(0))
+			Expect(mountDirContents()).To(ConsistOf("app-volume", "leftover", "leftover.voltools-mount"))
+		})
+
+		It("never deletes the contents of a mountpoint", func() {
+			Expect(ioutil.WriteFile(filepath.Join(mountDir, "leftover", "data"), []byte("precious"), 0644)).To(Succeed())
+
+			Expect(volTools.Sweep(env)).To(Equal(0))
+			Expect(filepath.Join(mountDir, "leftover", "data")).To(BeAnExistingFile())
+		})
+
+		It("leaves mounts in use alone", func() {
+			fakeACLBackend.GetACLStub = func(dockerdriver.Env, string) ([]efsvoltools.ACE, error) {
+				Expect(volTools.Sweep(env)).To(Equal(1))
+				return nil, nil
+			}
+			volTools.GetACL(env, efsvoltools.GetACLRequest{Name: "some-volume", Opts: opts})
+			Expect(fakeACLBackend.GetACLCallCount()).To(Equal(1))
+			Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+			_, target := fakeMounter.UnmountArgsForCall(0)
+			Expect(target).To(HavePrefix(filepath.Join(mountDir, "some-volume-")))
+		})
+
+		It("bounds the cleanup of each mount by the cleanup timeout", func() {
+			fakeMounter.CheckReturns(true)
+			start := time.Now()
+
+			Expect(volTools.Sweep(env)).To(Equal(1))
+			unmountEnv, _ := fakeMounter.UnmountArgsForCall(0)
+			deadline, ok := unmountEnv.Context().Deadline()
+			Expect(ok).To(BeTrue())
+			Expect(deadline).To(BeTemporally("~", start.Add(voltoolslocal.CleanupTimeout), time.Second))
+		})
+
+		It("does not hold up operations while unmounting", func() {
+			release := make(chan struct{})
+			defer close(release)
+			fakeMounter.CheckReturns(true)
+			fakeMounter.UnmountStub = func(env dockerdriver.Env, target string) error {
+				if target == filepath.Join(mountDir, "leftover") {
+					<-release
+				}
+				return nil
+			}
+
+			swept := make(chan int, 1)
+			go func() { swept <- volTools.Sweep(env) }()
+			Eventually(fakeMounter.UnmountCallCount).Should(Equal(1))
+
+			response := volTools.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: "some-volume", Opts: opts})
+			Expect(response.Err).To(BeEmpty())
+
+			By("leaving the mount being swept to the sweep already at it")
+			Expect(volTools.Sweep(env)).To(Equal(0))
+
+			release <- struct{}{}
+			Eventually(swept).Should(Receive(Equal(1)))
+		})
+
+		It("sweeps periodically once started", func() {
+			fakeClock := fakeclock.NewFakeClock(time.Now())
+			process := ifrit.Invoke(voltoolslocal.NewSweeper(lagertest.NewTestLogger("sweeper"), volTools, fakeClock, time.Minute))
+			defer process.Signal(os.Interrupt)
+			Expect(mountDirContents()).To(Equal([]string{"app-volume"}))
+
+			Expect(os.MkdirAll(filepath.Join(mountDir, "later"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(mountDir, "later.voltools-mount"), nil, 0644)).To(Succeed())
+			fakeClock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(mountDirContents).Should(Equal([]string{"app-volume"}))
+		})
+	})
+
+	Describe("ValidateStagingRoot", func() {
+		It("accepts a directory beside the driver's", func() {
+			Expect(voltoolslocal.ValidateStagingRoot("/var/vcap/data/voltools", "/var/vcap/data/volumes")).To(Succeed())
+		})
+
+		It("refuses the driver's own directory", func() {
+			Expect(voltoolslocal.ValidateStagingRoot("/var/vcap/data/volumes/", "/var/vcap/data/volumes")).To(MatchError(ContainSubstring("must not overlap")))
+		})
+
+		It("refuses a directory within the driver's", func() {
+			Expect(voltoolslocal.ValidateStagingRoot("/var/vcap/data/volumes/voltools", "/var/vcap/data/volumes")).NotTo(Succeed())
+		})
+
+		It("refuses a directory containing the driver's", func() {
+			Expect(voltoolslocal.ValidateStagingRoot("/var/vcap/data", "/var/vcap/data/volumes")).NotTo(Succeed())
+		})
+	})
+})
@@ -0,0 +1,177 @@
+package voltoolslocal
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/dockerdriver/invoker"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+)
+
+//go:generate counterfeiter -o ../../efsdriverfakes/fake_acl_backend.go . ACLBackend
+type ACLBackend interface {
+	GetACL(env dockerdriver.Env, path string) ([]efsvoltools.ACE, error)
+	SetACL(env dockerdriver.Env, path string, aces []efsvoltools.ACE) error
+}
+
+type nfs4ACLBackend struct {
+	invoker invoker.Invoker
+}
+
+// NewNfs4ACLBackend reads and writes ACLs with the nfs4_getfacl and
+// nfs4_setfacl tools from nfs4-acl-tools.
+func NewNfs4ACLBackend(invoker invoker.Invoker) ACLBackend {
+	return &nfs4ACLBackend{invoker}
+}
+
+func (b *nfs4ACLBackend) GetACL(env dockerdriver.Env, path string) ([]efsvoltools.ACE, error) {
+	output, err := b.invoker.Invoke(env, "nfs4_getfacl", []string{path})
+	if err != nil {
+		return nil, fmt.Errorf("nfs4_getfacl failed: %s (%s)", err.Error(), strings.TrimSpace(string(output)))
+	}
+
+	aces := []efsvoltools.ACE{}
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ace, err := parseNfs4ACE(line)
+		if err != nil {
+			return nil, err
+		}
+		aces = append(aces, ace)
+	}
+	return aces, nil
+}
+
+func (b *nfs4ACLBackend) SetACL(env dockerdriver.Env, path string, aces []efsvoltools.ACE) error {
+	spec := make([]string, len(aces))
+	for i, ace := range aces {
+		text, err := formatNfs4ACE(ace)
+		if err != nil {
+			return err
+		}
+		spec[i] = text
+	}
+
+	output, err := b.invoker.Invoke(env, "nfs4_setfacl", []string{"-s", strings.Join(spec, ","), path})
+	if err != nil {
+		return fmt.Errorf("nfs4_setfacl failed: %s (%s)", err.Error(), strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// the single letter codes nfs4-acl-tools use in "type:flags:principal:perms"
+var (
+	nfs4ACETypes = map[string]string{
+		"A": efsvoltools.ACETypeAllow,
+		"D": efsvoltools.ACETypeDeny,
+		"U": efsvoltools.ACETypeAudit,
+		"L": efsvoltools.ACETypeAlarm,
+	}
+	nfs4ACEFlags = []letterName{
+		{'f', efsvoltools.ACEFlagFileInherit},
+		{'d', efsvoltools.ACEFlagDirectoryInherit},
+		{'n', efsvoltools.ACEFlagNoPropagate},
+		{'i', efsvoltools.ACEFlagInheritOnly},
+		{'S', efsvoltools.ACEFlagSuccessfulAccess},
+		{'F', efsvoltools.ACEFlagFailedAccess},
+		{'g', efsvoltools.ACEFlagGroup},
+	}
+	nfs4ACEPermissions = []letterName{
+		{'r', efsvoltools.ACEPermReadData},
+		{'w', efsvoltools.ACEPermWriteData},
+		{'a', efsvoltools.ACEPermAppendData},
+		{'x', efsvoltools.ACEPermExecute},
+		{'d', efsvoltools.ACEPermDelete},
+		{'D', efsvoltools.ACEPermDeleteChild},
+		{'t', efsvoltools.ACEPermReadAttributes},
+		{'T', efsvoltools.ACEPermWriteAttributes},
+		{'n', efsvoltools.ACEPermReadNamedAttrs},
+		{'N', efsvoltools.ACEPermWriteNamedAttrs},
+		{'c', efsvoltools.ACEPermReadACL},
+		{'C', efsvoltools.ACEPermWriteACL},
+		{'o', efsvoltools.ACEPermWriteOwner},
+		{'y', efsvoltools.ACEPermSynchronize},
+	}
+)
+
+type letterName struct {
+	letter rune
+	name   string
+}
+
+func parseNfs4ACE(text string) (efsvoltools.ACE, error) {
+	fields := strings.Split(text, ":")
+	if len(fields) != 4 {
+		return efsvoltools.ACE{}, fmt.Errorf("malformed ACE '%s'", text)
+	}
+
+	aceType, ok := nfs4ACETypes[fields[0]]
+	if !ok {
+		return efsvoltools.ACE{}, fmt.Errorf("unknown type in ACE '%s'", text)
+	}
+	flags, err := lettersToNames(fields[1], nfs4ACEFlags)
+	if err != nil {
+		return efsvoltools.ACE{}, fmt.Errorf("%s in ACE '%s'", err.Error(), text)
+	}
+	permissions, err := lettersToNames(fields[3], nfs4ACEPermissions)
+	if err != nil {
+		return efsvoltools.ACE{}, fmt.Errorf("%s in ACE '%s'", err.Error(), text)
+	}
+
+	return efsvoltools.ACE{Type: aceType, Flags: flags, Principal: fields[2], Permissions: permissions}, nil
+}
+
+func formatNfs4ACE(ace efsvoltools.ACE) (string, error) {
+	if err := ace.Validate(); err != nil {
+		return "", err
+	}
+
+	aceType := ""
+	for letter, name := range nfs4ACETypes {
+		if name == ace.Type {
+			aceType = letter
+		}
+	}
+	return strings.Join([]string{
+		aceType,
+		namesToLetters(ace.Flags, nfs4ACEFlags),
+		ace.Principal,
+		namesToLetters(ace.Permissions, nfs4ACEPermissions),
+	}, ":"), nil
+}
+
+func lettersToNames(letters string, table []letterName) ([]string, error) {
+	names := []string{}
+	for _, letter := range letters {
+		found := false
+		for _, entry := range table {
+			if entry.letter == letter {
+				names = append(names, entry.name)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown code '%c'", letter)
+		}
+	}
+	return names, nil
+}
+
+// namesToLetters writes the codes in table order, once each.
+func namesToLetters(names []string, table []letterName) string {
+	letters := ""
+	for _, entry := range table {
+		for _, name := range names {
+			if name == entry.name {
+				letters += string(entry.letter)
+				break
+			}
+		}
+	}
+	return letters
+}
@@ -1,16 +1,23 @@
 package voltoolslocal
 
 import (
-	"errors"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"syscall"
 
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsmounter"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/goshims/filepathshim"
 	"code.cloudfoundry.org/goshims/ioutilshim"
@@ -27,16 +34,27 @@ type EfsVolumeInfo struct {
 type EfsVolToolsLocal struct {
 	os            osshim.Os
 	filepath      filepathshim.Filepath
+	ioutil        ioutilshim.Ioutil
 	mountPathRoot string
 	mounter       volumedriver.Mounter
+	aclBackend    ACLBackend
+
+	mountsLock   sync.Mutex
+	activeMounts map[string]int
 }
 
-func NewEfsVolToolsLocal(os osshim.Os, filepath filepathshim.Filepath, ioutil ioutilshim.Ioutil, mountPathRoot string, mounter volumedriver.Mounter) *EfsVolToolsLocal {
+// NewEfsVolToolsLocal creates the volume tools. Volumes are mounted
+// temporarily under mountPathRoot, which must be kept apart from the
+// driver's own mount directory (see ValidateStagingRoot).
+func NewEfsVolToolsLocal(os osshim.Os, filepath filepathshim.Filepath, ioutil ioutilshim.Ioutil, mountPathRoot string, mounter volumedriver.Mounter, aclBackend ACLBackend) *EfsVolToolsLocal {
 	d := &EfsVolToolsLocal{
 		os:            os,
 		filepath:      filepath,
+		ioutil:        ioutil,
 		mountPathRoot: mountPathRoot,
 		mounter:       mounter,
+		aclBackend:    aclBackend,
+		activeMounts:  map[string]int{},
 	}
 
 	return d
@@ -47,126 +65,310 @@ func (d *EfsVolToolsLocal) OpenPerms(env dockerdriver.Env, request efsvoltools.O
 	logger := env.Logger().Session("open-perms", lager.Data{"opts": request.Opts})
 	logger.Info("start")
 	defer logger.Info("end")
-	orig := syscall.Umask(000)
-	defer syscall.Umask(orig)
 
-	if request.Name == "" {
-		return efsvoltools.ErrorResponse{Err: "Missing mandatory 'volume_name'"}
+	return d.openPerms(driverhttp.EnvWithLogger(logger, env), volumeRef{name: request.Name, opts: request.Opts})
+}
+
+// mountIp returns the address of the file system given by the "ip" field of
+// opts, normalized so that it can be mounted. field names opts in errors.
+func mountIp(opts map[string]interface{}, field string) (string, error) {
+	ip, ok := opts["ip"].(string)
+	if !ok {
+		return "", fmt.Errorf("Missing mandatory 'ip' field in '%s'", field)
 	}
+	address, err := efsmounter.ParseAddress(ip)
+	if err != nil {
+		return "", fmt.Errorf("Invalid 'ip' field in '%s': %s", field, err.Error())
+	}
+	return address, nil
+}
 
-	var ip string
-	var ok bool
-	if ip, ok = request.Opts["ip"].(string); !ok {
-		logger.Info("mount-config-missing-ip", lager.Data{"volume_name": request.Name})
-		return efsvoltools.ErrorResponse{Err: `Missing mandatory 'ip' field in 'Opts'`}
+func (d *EfsVolToolsLocal) openPerms(env dockerdriver.Env, v volumeRef) efsvoltools.ErrorResponse {
+	logger := env.Logger()
+
+	return d.onVolume(env, v, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if err := env.Context().Err(); err != nil {
+			logger.Error("volume-chmod-cancelled", err)
+			return errorResponse(efsvoltools.ErrorCodeChmodFailed, err, fmt.Sprintf("Error chmoding volume: %s", err.Error()))
+		}
+		if err := d.chmod(env, v.mountPath); err != nil {
+			logger.Error("volume-chmod-failed", err)
+			return efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeChmodFailed, fmt.Sprintf("Error chmoding volume: %s", err.Error()))
+		}
+		logger.Info("volume-mounted", lager.Data{"name": v.name})
+		return efsvoltools.ErrorResponse{}
+	})
+}
+
+func (d *EfsVolToolsLocal) chmod(env dockerdriver.Env, mountPath string) error {
+	_, span := efstracing.Start(env.Context(), "voltools.chmod", efstracing.String("efs.target", mountPath))
+	defer span.End()
+
+	err := d.os.Chmod(mountPath, os.ModePerm)
+	span.RecordError(err)
+	return err
+}
+
+func (d *EfsVolToolsLocal) Capabilities(env dockerdriver.Env) efsvoltools.CapabilitiesResponse {
+	return efsvoltools.CapabilitiesResponse{
+		APIVersion: efsvoltools.APIVersion,
+		Operations: efsvoltools.Operations,
 	}
+}
 
-	mountPath := d.mountPath(driverhttp.EnvWithLogger(logger, env), request.Name)
-	logger.Info("mounting-volume", lager.Data{"id": request.Name, "mountpoint": mountPath})
+func (d *EfsVolToolsLocal) RepairPerms(env dockerdriver.Env, request efsvoltools.RepairPermsRequest) efsvoltools.RepairPermsResponse {
+	logger := env.Logger().Session("repair-perms", lager.Data{"opts": request.Opts, "path": request.Path})
+	logger.Info("start")
+	defer logger.Info("end")
 
-	err := d.mount(driverhttp.EnvWithLogger(logger, env), ip, mountPath)
-	if err != nil {
-		logger.Error("mount-volume-failed", err)
-		return efsvoltools.ErrorResponse{Err: fmt.Sprintf("Error mounting volume: %s", err.Error())}
+	if request.Uid == nil && request.Gid == nil && request.FileMode == nil && request.DirMode == nil {
+		return efsvoltools.RepairPermsResponse{ErrorResponse: efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, "Nothing to repair: none of 'Uid', 'Gid', 'FileMode' or 'DirMode' given")}
 	}
 
-	err = d.os.Chmod(mountPath, os.ModePerm)
-	if err != nil {
-		logger.Error("volume-chmod-failed", err)
-		return efsvoltools.ErrorResponse{Err: fmt.Sprintf("Error chmoding volume: %s", err.Error())}
+	parallelism := request.Parallelism
+	if parallelism <= 0 {
+		parallelism = DefaultRepairParallelism
+	}
+	if parallelism > MaxRepairParallelism {
+		parallelism = MaxRepairParallelism
 	}
 
-	logger.Info("volume-mounted", lager.Data{"name": request.Name})
+	var response efsvoltools.RepairPermsResponse
+	volume := volumeRef{name: request.Name, opts: request.Opts, path: request.Path}
+	response.ErrorResponse = d.onVolume(driverhttp.EnvWithLogger(logger, env), volume, func(v stagedVolume) efsvoltools.ErrorResponse {
+		if refused := d.refuseSymlinks(driverhttp.EnvWithLogger(logger, env), v, v.root, false); refused.Err != "" {
+			return refused
+		}
+		response = d.repairTree(driverhttp.EnvWithLogger(logger, env), v.root, request, parallelism)
+		return response.ErrorResponse
+	})
+
+	logger.Info("repaired", lager.Data{"changed": response.Changed, "unchanged": response.Unchanged, "skipped": response.Skipped, "failed": response.Failed})
+	return response
+}
 
-	if err := d.unmount(driverhttp.EnvWithLogger(logger, env), request.Name, mountPath); err != nil {
-		return efsvoltools.ErrorResponse{Err: err.Error()}
-	}
+const (
+	DefaultRepairParallelism = 8
+	MaxRepairParallelism     = 64
 
-	// TODO - delete mount folder
-	return efsvoltools.ErrorResponse{}
+	progressInterval = 1000
+)
+
+type repairEntry struct {
+	path string
+	info os.FileInfo
 }
 
-func (d *EfsVolToolsLocal) exists(path string) (bool, error) {
-	_, err := d.os.Stat(path)
-	if err == nil {
-		return true, nil
+func (d *EfsVolToolsLocal) repairTree(env dockerdriver.Env, root string, request efsvoltools.RepairPermsRequest, parallelism int) efsvoltools.RepairPermsResponse {
+	logger := env.Logger().Session("repair-tree", lager.Data{"root": root, "parallelism": parallelism})
+	ctx := env.Context()
+
+	if _, err := d.os.Lstat(root); err != nil {
+		logger.Error("failed-to-stat-root", err)
+		return efsvoltools.RepairPermsResponse{ErrorResponse: errorResponse(efsvoltools.ErrorCodeInternal, err, fmt.Sprintf("Error reading path '%s': %s", request.Path, err.Error()))}
 	}
-	if os.IsNotExist(err) {
-		return false, nil
+
+	var changed, unchanged, skipped, failed int64
+	processed := func() int64 {
+		return atomic.LoadInt64(&changed) + atomic.LoadInt64(&unchanged) + atomic.LoadInt64(&skipped) + atomic.LoadInt64(&failed)
 	}
-	return true, err
+
+	entries := make(chan repairEntry)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range entries {
+				wasChanged, err := d.repairEntry(entry, request)
+				switch {
+				case err != nil:
+					logger.Error("failed-to-repair-entry", err, lager.Data{"path": entry.path})
+					atomic.AddInt64(&failed, 1)
+				case wasChanged:
+					atomic.AddInt64(&changed, 1)
+				default:
+					atomic.AddInt64(&unchanged, 1)
+				}
+
+				if done := processed(); done%progressInterval == 0 {
+					efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: done})
+				}
+			}
+		}()
+	}
+
+	walkErr := d.filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			logger.Error("failed-to-walk-entry", err, lager.Data{"path": path})
+			atomic.AddInt64(&failed, 1)
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			atomic.AddInt64(&skipped, 1)
+			return nil
+		}
+
+		entries <- repairEntry{path: path, info: info}
+		return nil
+	})
+	close(entries)
+	wg.Wait()
+
+	response := efsvoltools.RepairPermsResponse{
+		Changed:   changed,
+		Unchanged: unchanged,
+		Skipped:   skipped,
+		Failed:    failed,
+	}
+	efsvoltools.ReportProgress(env, efsvoltools.Progress{Done: processed(), Total: processed()})
+
+	if walkErr != nil {
+		logger.Error("walk-failed", walkErr)
+		response.ErrorResponse = errorResponse(efsvoltools.ErrorCodeInternal, walkErr, fmt.Sprintf("Error walking volume: %s", walkErr.Error()))
+	}
+	return response
 }
 
-func (d *EfsVolToolsLocal) mountPath(env dockerdriver.Env, volumeId string) string {
-	logger := env.Logger().Session("mount-path")
-	orig := syscall.Umask(000)
-	defer syscall.Umask(orig)
+// repairEntry applies the requested ownership and mode to a single file or
+// directory, and reports whether anything had to change.
+func (d *EfsVolToolsLocal) repairEntry(entry repairEntry, request efsvoltools.RepairPermsRequest) (bool, error) {
+	changed := false
+
+	if stat, ok := entry.info.Sys().(*syscall.Stat_t); ok && (request.Uid != nil || request.Gid != nil) {
+		uid, gid := int(stat.Uid), int(stat.Gid)
+		if request.Uid != nil {
+			uid = *request.Uid
+		}
+		if request.Gid != nil {
+			gid = *request.Gid
+		}
+
+		if uid != int(stat.Uid) || gid != int(stat.Gid) {
+			if err := d.os.Lchown(entry.path, uid, gid); err != nil {
+				return changed, err
+			}
+			changed = true
+		}
+	}
 
-	dir, err := d.filepath.Abs(d.mountPathRoot)
-	if err != nil {
-		logger.Fatal("abs-failed", err)
+	mode := request.FileMode
+	if entry.info.IsDir() {
+		mode = request.DirMode
 	}
 
-	if err := d.os.MkdirAll(dir, os.ModePerm); err != nil {
-		logger.Fatal("mkdir-rootpath-failed", err)
+	// chown may have cleared the setuid and setgid bits, so the mode is
+	// reapplied whenever the ownership changed.
+	if mode != nil && (changed || entry.info.Mode()&permBits != *mode&permBits) {
+		if err := d.os.Chmod(entry.path, *mode&permBits); err != nil {
+			return changed, err
+		}
+		changed = true
 	}
 
-	return filepath.Join(dir, volumeId)
+	return changed, nil
 }
 
-func (d *EfsVolToolsLocal) mount(env dockerdriver.Env, ip, mountPath string) error {
-	logger := env.Logger().Session("mount", lager.Data{"ip": ip, "target": mountPath})
-	logger.Info("start")
-	defer logger.Info("end")
+const permBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// subPath resolves a path relative to the root of a mounted volume, refusing
+// anything that would escape it.
+func subPath(mountPath, relative string) (string, error) {
+	if strings.ContainsRune(relative, 0) {
+		return "", fmt.Errorf("Invalid path '%s'", relative)
+	}
+	for _, element := range strings.Split(relative, "/") {
+		if element == ".." {
+			return "", fmt.Errorf("Invalid path '%s': must not contain '..'", relative)
+		}
+	}
+	return filepath.Join(mountPath, filepath.Clean("/"+relative)), nil
+}
 
-	orig := syscall.Umask(000)
-	defer syscall.Umask(orig)
+// errorResponse reports message under code, unless err says more precisely what
+// went wrong.
+func errorResponse(code efsvoltools.ErrorCode, err error, message string) efsvoltools.ErrorResponse {
+	switch {
+	case os.IsNotExist(err):
+		code = efsvoltools.ErrorCodeNotFound
+	case err == context.DeadlineExceeded:
+		code = efsvoltools.ErrorCodeTimeout
+	}
+	return efsvoltools.NewErrorResponse(code, message)
+}
 
-	err := d.os.MkdirAll(mountPath, os.ModePerm)
+// noSymlinksBelow checks that no element of path below base, path itself
+// included, is a symlink; following one could lead outside of the volume
+// mounted at base. With create, missing directories are made on the way,
+// otherwise the check stops at the first one missing.
+func (d *EfsVolToolsLocal) noSymlinksBelow(base, path string, create bool) error {
+	relative, err := filepath.Rel(base, path)
 	if err != nil {
-		logger.Error("create-mountdir-failed", err)
 		return err
 	}
+	if relative == "." {
+		return nil
+	}
 
-	// TODO--permissions & flags?
-	err = d.mounter.Mount(driverhttp.EnvWithLogger(logger, env), ip+":/", mountPath, nil)
-	if err != nil {
-		logger.Error("mount-failed", err)
+	current := base
+	for _, element := range strings.Split(relative, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+		info, err := d.os.Lstat(current)
+		if os.IsNotExist(err) {
+			if !create {
+				return nil
+			}
+			if err := d.os.Mkdir(current, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("Invalid path '%s': traverses a symlink", relative)
+		}
 	}
-	return err
+	return nil
 }
 
-func (d *EfsVolToolsLocal) unmount(env dockerdriver.Env, name string, mountPath string) error {
-	logger := env.Logger().Session("unmount")
-	logger.Info("start")
-	defer logger.Info("end")
-
-	exists, err := d.exists(mountPath)
-	if err != nil {
-		logger.Error("failed-retrieving-mount-info", err, lager.Data{"mountpoint": mountPath})
-		return errors.New("Error establishing whether volume exists")
+func (d *EfsVolToolsLocal) exists(path string) (bool, error) {
+	_, err := d.os.Stat(path)
+	if err == nil {
+		return true, nil
 	}
-
-	if !exists {
-		errText := fmt.Sprintf("Volume %s does not exist (path: %s), nothing to do!", name, mountPath)
-		logger.Error("failed-mountpoint-not-found", errors.New(errText))
-		return errors.New(errText)
+	if os.IsNotExist(err) {
+		return false, nil
 	}
+	return true, err
+}
 
-	logger.Info("unmount-volume-folder", lager.Data{"mountpath": mountPath})
+// mountPath returns a fresh temporary mountpoint for volumeId under the
+// staging root. Every call gets a different path, so concurrent operations
+// on the same volume never share a mountpoint.
+func (d *EfsVolToolsLocal) mountPath(env dockerdriver.Env, volumeId string) string {
+	logger := env.Logger().Session("mount-path")
 
-	err = d.mounter.Unmount(driverhttp.EnvWithLogger(logger, env), mountPath)
+	dir, err := d.filepath.Abs(d.mountPathRoot)
 	if err != nil {
-		logger.Error("unmount-failed", err)
-		return fmt.Errorf("Error unmounting volume: %s", err.Error())
+		logger.Fatal("abs-failed", err)
 	}
-	err = d.os.RemoveAll(mountPath)
-	if err != nil {
-		logger.Error("create-mountdir-failed", err)
-		return fmt.Errorf("Error creating mountpoint: %s", err.Error())
+
+	if err := d.os.MkdirAll(dir, os.ModePerm); err != nil {
+		logger.Fatal("mkdir-rootpath-failed", err)
+	}
+	if err := d.os.Chmod(dir, os.ModePerm); err != nil {
+		logger.Fatal("chmod-rootpath-failed", err)
 	}
 
-	logger.Info("unmounted-volume")
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		logger.Fatal("random-suffix-failed", err)
+	}
 
-	return nil
+	return filepath.Join(dir, volumeId+"-"+hex.EncodeToString(suffix))
 }
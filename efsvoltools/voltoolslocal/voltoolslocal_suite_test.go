@@ -1,6 +1,12 @@
 package voltoolslocal_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -11,3 +17,30 @@ func TestLocalDriver(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Efs Volume Tools Local Suite")
 }
+
+// persistentFakeMounter leaves mountpoints as plain directories. Unmounting
+// removes them, so their contents are moved aside into keptDir and moved back
+// on the next mount, which makes keptDir/<volume> behave like the volume.
+// Mountpoints are named after their volume plus a unique suffix, which is
+// dropped to find the kept directory.
+func persistentFakeMounter(keptDir string) *volumedriverfakes.FakeMounter {
+	fakeMounter := &volumedriverfakes.FakeMounter{}
+	fakeMounter.MountStub = func(env dockerdriver.Env, source, target string, opts map[string]interface{}) error {
+		kept := filepath.Join(keptDir, volumeOf(target))
+		if _, err := os.Stat(kept); err == nil {
+			Expect(os.Remove(target)).To(Succeed())
+			return os.Rename(kept, target)
+		}
+		return nil
+	}
+	fakeMounter.UnmountStub = func(env dockerdriver.Env, target string) error {
+		return os.Rename(target, filepath.Join(keptDir, volumeOf(target)))
+	}
+	return fakeMounter
+}
+
+// volumeOf returns the name of the volume mounted on a temporary mountpoint.
+func volumeOf(mountPath string) string {
+	base := filepath.Base(mountPath)
+	return base[:strings.LastIndex(base, "-")]
+}
@@ -2,19 +2,32 @@ package voltoolslocal_test
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
 
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/dockerdriver"
 	"code.cloudfoundry.org/dockerdriver/driverhttp"
+	"code.cloudfoundry.org/efsdriver/efsdriverfakes"
+	"code.cloudfoundry.org/efsdriver/efstracing"
 	"code.cloudfoundry.org/efsdriver/efsvoltools"
 	"code.cloudfoundry.org/efsdriver/efsvoltools/voltoolslocal"
+	"code.cloudfoundry.org/goshims/filepathshim"
 	"code.cloudfoundry.org/goshims/filepathshim/filepath_fake"
+	"code.cloudfoundry.org/goshims/ioutilshim"
 	"code.cloudfoundry.org/goshims/ioutilshim/ioutil_fake"
+	"code.cloudfoundry.org/goshims/osshim"
 	"code.cloudfoundry.org/goshims/osshim/os_fake"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/volumedriver/volumedriverfakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
 )
 
 var _ = Describe("Efs Driver", func() {
@@ -44,7 +57,7 @@ var _ = Describe("Efs Driver", func() {
 
 	Context("created", func() {
 		BeforeEach(func() {
-			efsDriver = voltoolslocal.NewEfsVolToolsLocal(fakeOs, fakeFilepath, fakeIoutil, mountDir, fakeMounter)
+			efsDriver = voltoolslocal.NewEfsVolToolsLocal(fakeOs, fakeFilepath, fakeIoutil, mountDir, fakeMounter, &efsdriverfakes.FakeACLBackend{})
 		})
 
 		Describe("OpenPerms", func() {
@@ -59,13 +72,369 @@ var _ = Describe("Efs Driver", func() {
 					Expect(fakeMounter.MountCallCount()).To(Equal(1))
 					_, from, to, _ := fakeMounter.MountArgsForCall(0)
 					Expect(from).To(Equal("1.1.1.1:/"))
-					Expect(to).To(Equal("/path/to/mount/" + volumeName))
+					Expect(to).To(beATemporaryMountpointFor(volumeName))
+				})
+
+				It("should use a different mountpoint each time", func() {
+					openPermsSuccessful(env, efsDriver, fakeFilepath, volumeName, "")
+
+					_, _, first, _ := fakeMounter.MountArgsForCall(0)
+					_, _, second, _ := fakeMounter.MountArgsForCall(1)
+					Expect(second).To(beATemporaryMountpointFor(volumeName))
+					Expect(second).NotTo(Equal(first))
+				})
+			})
+
+			Context("when tracing is enabled", func() {
+				var (
+					fakeExporter *efsdriverfakes.FakeExporter
+					tracer       *efstracing.Tracer
+				)
+
+				BeforeEach(func() {
+					fakeExporter = &efsdriverfakes.FakeExporter{}
+					tracer = efstracing.NewTracer(clock.NewClock(), fakeExporter)
+					efstracing.SetTracer(tracer)
+				})
+
+				AfterEach(func() {
+					efstracing.SetTracer(nil)
+				})
+
+				It("should trace the mount, chmod and unmount within the caller's span", func() {
+					traced, parent := tracer.Start(ctx, efstracing.KindServer, "request")
+					openPermsSuccessful(driverhttp.NewHttpDriverEnv(logger, traced), efsDriver, fakeFilepath, volumeName, "")
+					parent.End()
+
+					_, _, err := tracer.Flush(ctx)
+					Expect(err).NotTo(HaveOccurred())
+					_, spans := fakeExporter.ExportArgsForCall(0)
+
+					var names []string
+					for _, span := range spans {
+						names = append(names, span.Name)
+						Expect(span.SpanContext.TraceID).To(Equal(parent.SpanContext().TraceID))
+					}
+					Expect(names).To(Equal([]string{"voltools.mount", "voltools.chmod", "voltools.unmount", "request"}))
+					for _, span := range spans[:3] {
+						Expect(span.Parent).To(Equal(parent.SpanContext().SpanID))
+						Expect(span.Failed).To(BeFalse())
+					}
+				})
+			})
+
+			Context("when the ip is missing", func() {
+				It("should report an invalid request naming the field", func() {
+					response := efsDriver.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: volumeName})
+					Expect(response.Err).To(Equal(`Missing mandatory 'ip' field in 'Opts'`))
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+					Expect(response.Details).To(Equal(map[string]string{"field": "ip"}))
+					Expect(response.Retryable).To(BeFalse())
+				})
+			})
+
+			Context("when the ip is not a valid address", func() {
+				It("should report an invalid request without mounting", func() {
+					response := efsDriver.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "1.1.1.1:/etc"}})
+					Expect(response.Err).To(HavePrefix(`Invalid 'ip' field in 'Opts'`))
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+					Expect(response.Details).To(Equal(map[string]string{"field": "ip"}))
+					Expect(fakeMounter.MountCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the ip is an IPv6 address", func() {
+				It("should mount it in brackets", func() {
+					fakeFilepath.AbsReturns("/path/to/mount/", nil)
+					response := efsDriver.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "fd00::1"}})
+					Expect(response.Err).To(BeEmpty())
+
+					_, from, _, _ := fakeMounter.MountArgsForCall(0)
+					Expect(from).To(Equal("[fd00::1]:/"))
+				})
+			})
+
+			Context("when mounting fails", func() {
+				BeforeEach(func() {
+					fakeFilepath.AbsReturns("/path/to/mount/", nil)
+					fakeMounter.MountReturns(errors.New("connection timed out"))
+				})
+
+				It("should report a retryable mount failure", func() {
+					response := efsDriver.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "1.1.1.1"}})
+					Expect(response.Err).To(Equal("Error mounting volume: connection timed out"))
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+					Expect(response.Retryable).To(BeTrue())
+				})
+			})
+
+			Context("when the deadline has already passed", func() {
+				It("should give up without mounting", func() {
+					expired, cancel := context.WithDeadline(ctx, time.Now().Add(-time.Second))
+					defer cancel()
+
+					response := efsDriver.OpenPerms(driverhttp.NewHttpDriverEnv(logger, expired), efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "1.1.1.1"}})
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeTimeout))
+					Expect(fakeMounter.MountCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when the request is cancelled while mounting", func() {
+				var (
+					cancellable   context.Context
+					unmountCtxErr error
+				)
+
+				BeforeEach(func() {
+					fakeFilepath.AbsReturns("/path/to/mount/", nil)
+
+					var cancel context.CancelFunc
+					cancellable, cancel = context.WithCancel(ctx)
+					fakeMounter.MountStub = func(dockerdriver.Env, string, string, map[string]interface{}) error {
+						cancel()
+						return nil
+					}
+					fakeMounter.UnmountStub = func(env dockerdriver.Env, _ string) error {
+						unmountCtxErr = env.Context().Err()
+						return nil
+					}
+				})
+
+				It("should unmount the partial mount with a live context and skip chmod", func() {
+					response := efsDriver.OpenPerms(driverhttp.NewHttpDriverEnv(logger, cancellable), efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "1.1.1.1"}})
+					Expect(response.Err).To(Equal("Error mounting volume: context canceled"))
+
+					Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+					_, target := fakeMounter.UnmountArgsForCall(0)
+					Expect(target).To(beATemporaryMountpointFor(volumeName))
+
+					By("only opening up the staging root and the mountpoint")
+					Expect(fakeOs.ChmodCallCount()).To(Equal(2))
+					path, mode := fakeOs.ChmodArgsForCall(0)
+					Expect(path).To(Equal("/path/to/mount/"))
+					Expect(mode).To(Equal(os.ModePerm))
+					path, mode = fakeOs.ChmodArgsForCall(1)
+					Expect(path).To(Equal(target))
+					Expect(mode).To(Equal(os.ModePerm))
+					Expect(unmountCtxErr).NotTo(HaveOccurred())
+
+					By("removing the mountpoint only if it is empty")
+					Expect(fakeOs.RemoveAllCallCount()).To(Equal(0))
+					Expect(fakeOs.RemoveArgsForCall(0)).To(Equal(target))
+				})
+			})
+
+			Context("when chmod fails", func() {
+				BeforeEach(func() {
+					fakeFilepath.AbsReturns("/path/to/mount/", nil)
+					fakeOs.ChmodReturnsOnCall(2, errors.New("read-only file system"))
+				})
+
+				It("should report a chmod failure", func() {
+					response := efsDriver.OpenPerms(env, efsvoltools.OpenPermsRequest{Name: volumeName, Opts: map[string]interface{}{"ip": "1.1.1.1"}})
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeChmodFailed))
+					Expect(response.Retryable).To(BeFalse())
 				})
 			})
 		})
+
+		Describe("Capabilities", func() {
+			It("should report every operation", func() {
+				capabilities := efsDriver.Capabilities(env)
+				Expect(capabilities.APIVersion).To(Equal(efsvoltools.APIVersion))
+				Expect(capabilities.Operations).To(Equal(efsvoltools.Operations))
+			})
+		})
+
+		Describe("RepairPerms", func() {
+			var (
+				request  efsvoltools.RepairPermsRequest
+				response efsvoltools.RepairPermsResponse
+				entries  map[string]os.FileInfo
+			)
+
+			intPtr := func(i int) *int { return &i }
+			modePtr := func(m os.FileMode) *os.FileMode { return &m }
+
+			BeforeEach(func() {
+				fakeFilepath.AbsReturns("/path/to/mount/", nil)
+				request = efsvoltools.RepairPermsRequest{
+					Name:     volumeName,
+					Opts:     map[string]interface{}{"ip": "1.1.1.1"},
+					Path:     "instance-dir",
+					Uid:      intPtr(2000),
+					Gid:      intPtr(2000),
+					FileMode: modePtr(0640),
+					DirMode:  modePtr(0750),
+				}
+
+				entries = map[string]os.FileInfo{
+					"":          fakeFileInfo{name: "instance-dir", mode: os.ModeDir | 0750, uid: 2000, gid: 2000},
+					"wrong":     fakeFileInfo{name: "wrong", mode: 0777, uid: 0, gid: 0},
+					"right":     fakeFileInfo{name: "right", mode: 0640, uid: 2000, gid: 2000},
+					"wrong-dir": fakeFileInfo{name: "wrong-dir", mode: os.ModeDir | 0700, uid: 2000, gid: 2000},
+					"link":      fakeFileInfo{name: "link", mode: os.ModeSymlink | 0777, uid: 0, gid: 0},
+				}
+				fakeOs.LstatStub = func(name string) (os.FileInfo, error) {
+					return fakeFileInfo{name: filepath.Base(name), mode: os.ModeDir | 0750, uid: 2000, gid: 2000}, nil
+				}
+				fakeFilepath.WalkStub = func(walkRoot string, walkFn filepath.WalkFunc) error {
+					Expect(filepath.Dir(walkRoot)).To(beATemporaryMountpointFor(volumeName))
+					Expect(filepath.Base(walkRoot)).To(Equal("instance-dir"))
+					for relative, info := range entries {
+						if err := walkFn(filepath.Join(walkRoot, relative), info, nil); err != nil {
+							return err
+						}
+					}
+					return nil
+				}
+			})
+
+			JustBeforeEach(func() {
+				response = efsDriver.RepairPerms(env, request)
+			})
+
+			It("should mount and then unmount the volume", func() {
+				Expect(response.Err).To(BeEmpty())
+				Expect(fakeMounter.MountCallCount()).To(Equal(1))
+				Expect(fakeMounter.UnmountCallCount()).To(Equal(1))
+			})
+
+			It("should only change entries that differ from the request", func() {
+				Expect(response.Changed).To(Equal(int64(2)))
+				Expect(response.Unchanged).To(Equal(int64(2)))
+				Expect(response.Failed).To(Equal(int64(0)))
+
+				Expect(fakeOs.LchownCallCount()).To(Equal(1))
+				path, uid, gid := fakeOs.LchownArgsForCall(0)
+				Expect(path).To(HaveSuffix("/wrong"))
+				Expect(uid).To(Equal(2000))
+				Expect(gid).To(Equal(2000))
+
+				modes := map[string]os.FileMode{}
+				for i := 0; i < fakeOs.ChmodCallCount(); i++ {
+					path, mode := fakeOs.ChmodArgsForCall(i)
+					if filepath.Base(filepath.Dir(path)) == "instance-dir" {
+						modes[filepath.Base(path)] = mode
+					}
+				}
+				Expect(modes).To(Equal(map[string]os.FileMode{"wrong": 0640, "wrong-dir": 0750}))
+			})
+
+			It("should skip symlinks", func() {
+				Expect(response.Skipped).To(Equal(int64(1)))
+			})
+
+			Context("when changing an entry fails", func() {
+				BeforeEach(func() {
+					fakeOs.LchownReturns(errors.New("badness"))
+				})
+
+				It("should count the failure and carry on", func() {
+					Expect(response.Err).To(BeEmpty())
+					Expect(response.Failed).To(Equal(int64(1)))
+					Expect(response.Changed).To(Equal(int64(1)))
+				})
+			})
+
+			Context("when the path escapes the volume", func() {
+				BeforeEach(func() {
+					request.Path = "../other-volume"
+				})
+
+				It("should refuse without mounting", func() {
+					Expect(response.Err).To(ContainSubstring("must not contain '..'"))
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+					Expect(response.Details).To(Equal(map[string]string{"field": "path"}))
+					Expect(fakeMounter.MountCallCount()).To(Equal(0))
+				})
+			})
+
+			Context("when mounting fails", func() {
+				BeforeEach(func() {
+					fakeMounter.MountReturns(errors.New("badness"))
+				})
+
+				It("should report a retryable mount failure", func() {
+					Expect(response.Err).To(ContainSubstring("badness"))
+					Expect(response.Code).To(Equal(efsvoltools.ErrorCodeMountFailed))
+					Expect(response.Retryable).To(BeTrue())
+				})
+			})
+
+			Context("when nothing is requested", func() {
+				BeforeEach(func() {
+					request.Uid, request.Gid, request.FileMode, request.DirMode = nil, nil, nil, nil
+				})
+
+				It("should refuse without mounting", func() {
+					Expect(response.Err).To(ContainSubstring("Nothing to repair"))
+					Expect(fakeMounter.MountCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Describe("RepairPerms through a symlink", func() {
+			var (
+				tempDir string
+				outside string
+				tools   *voltoolslocal.EfsVolToolsLocal
+			)
+
+			BeforeEach(func() {
+				var err error
+				tempDir, err = ioutil.TempDir("", "repair")
+				Expect(err).NotTo(HaveOccurred())
+				keptDir := filepath.Join(tempDir, "kept")
+				outside = filepath.Join(tempDir, "outside")
+				Expect(os.MkdirAll(filepath.Join(outside, "etc"), 0755)).To(Succeed())
+				Expect(ioutil.WriteFile(filepath.Join(outside, "etc", "passwd"), nil, 0600)).To(Succeed())
+				Expect(os.MkdirAll(filepath.Join(keptDir, volumeName), 0755)).To(Succeed())
+				Expect(os.Symlink(outside, filepath.Join(keptDir, volumeName, "link"))).To(Succeed())
+
+				tools = voltoolslocal.NewEfsVolToolsLocal(&osshim.OsShim{}, &filepathshim.FilepathShim{}, &ioutilshim.IoutilShim{}, filepath.Join(tempDir, "mounts"), persistentFakeMounter(keptDir), &efsdriverfakes.FakeACLBackend{})
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(tempDir)
+			})
+
+			It("should refuse to repair anything outside of the volume", func() {
+				fileMode := os.FileMode(0644)
+				response := tools.RepairPerms(env, efsvoltools.RepairPermsRequest{
+					Name:     volumeName,
+					Opts:     map[string]interface{}{"ip": "1.1.1.1"},
+					Path:     "link/etc",
+					FileMode: &fileMode,
+				})
+				Expect(response.Err).To(ContainSubstring("traverses a symlink"))
+				Expect(response.Code).To(Equal(efsvoltools.ErrorCodeInvalidRequest))
+
+				info, err := os.Stat(filepath.Join(outside, "etc", "passwd"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+			})
+		})
 	})
 })
 
+type fakeFileInfo struct {
+	name     string
+	mode     os.FileMode
+	uid, gid uint32
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeFileInfo) Sys() interface{}   { return &syscall.Stat_t{Uid: f.uid, Gid: f.gid} }
+
+func beATemporaryMountpointFor(volumeName string) types.GomegaMatcher {
+	return MatchRegexp("^/path/to/mount/" + volumeName + "-[0-9a-f]{16}$")
+}
+
 func openPermsSuccessful(env dockerdriver.Env, tools efsvoltools.VolTools, fakeFilepath *filepath_fake.FakeFilepath, volumeName string, passcode string) {
 	fakeFilepath.AbsReturns("/path/to/mount/", nil)
 	opts := map[string]interface{}{"ip": "1.1.1.1"}
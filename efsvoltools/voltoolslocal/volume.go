@@ -0,0 +1,138 @@
+package voltoolslocal
+
+import (
+	"fmt"
+	"strings"
+
+	"code.cloudfoundry.org/dockerdriver"
+	"code.cloudfoundry.org/efsdriver/efsvoltools"
+	"code.cloudfoundry.org/lager"
+)
+
+// volumeRef is a volume as a request names it, along with the path inside it
+// that the operation works on. role sets the volumes of an operation on more
+// than one apart, such as "source" and "destination": it names them in
+// messages and prefixes the detail fields of their errors.
+type volumeRef struct {
+	name string
+	opts map[string]interface{}
+	path string
+	role string
+}
+
+// stagedVolume is a volumeRef that passed validation, with the temporary
+// mountpoint it gets mounted on and where its path lands below that.
+type stagedVolume struct {
+	volumeRef
+	ip        string
+	mountPath string
+	root      string
+}
+
+// validate checks that v names a volume and the ip of its file system, and
+// returns that ip.
+func (v volumeRef) validate() (string, efsvoltools.ErrorResponse) {
+	if v.name == "" {
+		message := "Missing mandatory 'volume_name'"
+		if v.role != "" {
+			message += fmt.Sprintf(" in '%s'", v.title())
+		}
+		return "", efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, message).WithDetail("field", v.field("volume_name"))
+	}
+
+	optsField := "Opts"
+	if v.role != "" {
+		optsField = v.title() + ".Opts"
+	}
+	ip, err := mountIp(v.opts, optsField)
+	if err != nil {
+		return "", efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()).WithDetail("field", v.field("ip"))
+	}
+	return ip, efsvoltools.ErrorResponse{}
+}
+
+// field is the detail field that reports an error in the named field of v.
+func (v volumeRef) field(name string) string {
+	if v.role == "" {
+		return name
+	}
+	return v.role + "_" + name
+}
+
+func (v volumeRef) title() string {
+	return strings.ToUpper(v.role[:1]) + v.role[1:]
+}
+
+func (v volumeRef) description() string {
+	if v.role == "" {
+		return "volume"
+	}
+	return v.role + " volume"
+}
+
+// stage validates v and picks the mountpoint it is to be mounted on.
+func (d *EfsVolToolsLocal) stage(env dockerdriver.Env, v volumeRef) (stagedVolume, efsvoltools.ErrorResponse) {
+	return d.stageOn(env, v, "")
+}
+
+// stageOn is stage, except that a mountPath given is used instead of a
+// mountpoint of its own, for a volume that an operation names twice.
+func (d *EfsVolToolsLocal) stageOn(env dockerdriver.Env, v volumeRef, mountPath string) (stagedVolume, efsvoltools.ErrorResponse) {
+	ip, invalid := v.validate()
+	if invalid.Err != "" {
+		env.Logger().Info("invalid-request", lager.Data{"volume_name": v.name, "err": invalid.Err})
+		return stagedVolume{}, invalid
+	}
+
+	if mountPath == "" {
+		mountPath = d.mountPath(env, v.name)
+	}
+	root, err := subPath(mountPath, v.path)
+	if err != nil {
+		env.Logger().Info("invalid-request", lager.Data{"volume_name": v.name, "err": err.Error()})
+		return stagedVolume{}, efsvoltools.NewErrorResponse(efsvoltools.ErrorCodeInvalidRequest, err.Error()).WithDetail("field", v.field("path"))
+	}
+	return stagedVolume{volumeRef: v, ip: ip, mountPath: mountPath, root: root}, efsvoltools.ErrorResponse{}
+}
+
+// mounted mounts v for as long as work runs and returns the response of work,
+// unless mounting or unmounting v failed.
+func (d *EfsVolToolsLocal) mounted(env dockerdriver.Env, v stagedVolume, work func() efsvoltools.ErrorResponse) efsvoltools.ErrorResponse {
+	logger := env.Logger()
+
+	var response efsvoltools.ErrorResponse
+	mountErr, unmountErr := d.withMount(env, v.name, v.ip, v.mountPath, func() {
+		response = work()
+	})
+	if mountErr != nil {
+		logger.Error("mount-volume-failed", mountErr, lager.Data{"volume_name": v.name})
+		return errorResponse(efsvoltools.ErrorCodeMountFailed, mountErr, fmt.Sprintf("Error mounting %s: %s", v.description(), mountErr.Error()))
+	}
+	if unmountErr != nil && response.Err == "" {
+		logger.Error("unmount-volume-failed", unmountErr, lager.Data{"volume_name": v.name})
+		return errorResponse(efsvoltools.ErrorCodeInternal, unmountErr, unmountErr.Error())
+	}
+	return response
+}
+
+// onVolume validates the volume a request names and runs work with it
+// mounted. It is all the bookkeeping of an operation on a single volume.
+func (d *EfsVolToolsLocal) onVolume(env dockerdriver.Env, v volumeRef, work func(v stagedVolume) efsvoltools.ErrorResponse) efsvoltools.ErrorResponse {
+	staged, invalid := d.stage(env, v)
+	if invalid.Err != "" {
+		return invalid
+	}
+	return d.mounted(env, staged, func() efsvoltools.ErrorResponse {
+		return work(staged)
+	})
+}
+
+// refuseSymlinks checks path below the mountpoint of v with noSymlinksBelow,
+// reporting a symlink on the way as an invalid path.
+func (d *EfsVolToolsLocal) refuseSymlinks(env dockerdriver.Env, v stagedVolume, path string, create bool) efsvoltools.ErrorResponse {
+	if err := d.noSymlinksBelow(v.mountPath, path, create); err != nil {
+		env.Logger().Info("path-refused", lager.Data{"volume_name": v.name, "err": err.Error()})
+		return errorResponse(efsvoltools.ErrorCodeInvalidRequest, err, err.Error()).WithDetail("field", v.field("path"))
+	}
+	return efsvoltools.ErrorResponse{}
+}